@@ -580,6 +580,9 @@ func zopfliIterate(num_bytes uint, position uint, ringbuffer []byte, ringbuffer_
 	nodes[0].u.cost = 0
 	initStartPosQueue(&queue)
 	for i = 0; i+3 < num_bytes; i++ {
+		if i&0xFFF == 0 && canceled(params) {
+			return 0
+		}
 		var skip uint = updateNodes(num_bytes, position, i, ringbuffer, ringbuffer_mask, params, max_backward_limit, dist_cache, uint(num_matches[i]), matches[cur_match_pos:], model, &queue, nodes)
 		if skip < longCopyQuickStep {
 			skip = 0
@@ -642,6 +645,10 @@ func zopfliComputeShortestPath(num_bytes uint, position uint, ringbuffer []byte,
 	zopfliCostModelSetFromLiteralCosts(&model, position, ringbuffer, ringbuffer_mask)
 	initStartPosQueue(&queue)
 	for i = 0; i+hasher.HashTypeLength()-1 < num_bytes; i++ {
+		if i&0xFFF == 0 && canceled(params) {
+			cleanupZopfliCostModel(&model)
+			return 0
+		}
 		var pos uint = position + i
 		var max_distance uint = brotli_min_size_t(pos, max_backward_limit)
 		var skip uint
@@ -685,6 +692,9 @@ func createZopfliBackwardReferences(num_bytes uint, position uint, ringbuffer []
 	nodes = make([]zopfliNode, (num_bytes + 1))
 	initZopfliNodes(nodes, num_bytes+1)
 	zopfliComputeShortestPath(num_bytes, position, ringbuffer, ringbuffer_mask, params, dist_cache, hasher, nodes)
+	if canceled(params) {
+		return
+	}
 	zopfliCreateCommands(num_bytes, position, nodes, dist_cache, last_insert_len, params, commands, num_literals)
 	nodes = nil
 }
@@ -712,6 +722,9 @@ func createHqZopfliBackwardReferences(num_bytes uint, position uint, ringbuffer
 	var shadow_matches uint = 0
 	var new_array []backwardMatch
 	for i = 0; i+hasher.HashTypeLength()-1 < num_bytes; i++ {
+		if i&0xFFF == 0 && canceled(params) {
+			return
+		}
 		var pos uint = position + i
 		var max_distance uint = brotli_min_size_t(pos, max_backward_limit)
 		var max_length uint = num_bytes - i
@@ -786,6 +799,9 @@ func createHqZopfliBackwardReferences(num_bytes uint, position uint, ringbuffer
 		*last_insert_len = orig_last_insert_len
 		copy(dist_cache, orig_dist_cache[:4])
 		zopfliIterate(num_bytes, position, ringbuffer, ringbuffer_mask, params, gap, dist_cache, &model, num_matches, matches, nodes)
+		if canceled(params) {
+			break
+		}
 		zopfliCreateCommands(num_bytes, position, nodes, dist_cache, last_insert_len, params, commands, num_literals)
 	}
 
@@ -6,7 +6,13 @@ package brotli
    See file LICENSE for detail or copy at https://opensource.org/licenses/MIT
 */
 
-/* Functions to estimate the bit cost of Huffman trees. */
+/* Functions to estimate the bit cost of Huffman trees.
+
+   The explicit float64 conversions around products keep the compiler from
+   fusing them with the following addition or subtraction (as it does on
+   arm64 and other architectures with FMA instructions), so that the
+   estimates, and therefore the compressed output, are the same on every
+   platform. */
 func shannonEntropy(population []uint32, size uint, total *uint) float64 {
 	var sum uint = 0
 	var retval float64 = 0
@@ -16,11 +22,11 @@ func shannonEntropy(population []uint32, size uint, total *uint) float64 {
 		p = uint(population[0])
 		population = population[1:]
 		sum += p
-		retval -= float64(p) * fastLog2(p)
+		retval -= float64(float64(p) * fastLog2(p))
 	}
 
 	if sum != 0 {
-		retval += float64(sum) * fastLog2(sum)
+		retval += float64(float64(sum) * fastLog2(sum))
 	}
 	*total = sum
 	return retval
@@ -118,7 +124,7 @@ func populationCostLiteral(histogram *histogramLiteral) float64 {
 
 				var depth uint = uint(log2p + 0.5)
 				/* Approximate the bit depth by round(-log2(P(symbol))) */
-				bits += float64(histogram.data_[i]) * log2p
+				bits += float64(float64(histogram.data_[i]) * log2p)
 
 				if depth > 15 {
 					depth = 15
@@ -249,7 +255,7 @@ func populationCostCommand(histogram *histogramCommand) float64 {
 
 				var depth uint = uint(log2p + 0.5)
 				/* Approximate the bit depth by round(-log2(P(symbol))) */
-				bits += float64(histogram.data_[i]) * log2p
+				bits += float64(float64(histogram.data_[i]) * log2p)
 
 				if depth > 15 {
 					depth = 15
@@ -380,7 +386,7 @@ func populationCostDistance(histogram *histogramDistance) float64 {
 
 				var depth uint = uint(log2p + 0.5)
 				/* Approximate the bit depth by round(-log2(P(symbol))) */
-				bits += float64(histogram.data_[i]) * log2p
+				bits += float64(float64(histogram.data_[i]) * log2p)
 
 				if depth > 15 {
 					depth = 15
@@ -418,6 +418,9 @@ func splitByteVectorCommand(data []uint16, literals_per_histogram uint, max_hist
 
 		var i uint
 		for i = 0; i < iters; i++ {
+			if i > 0 && canceled(params) {
+				break
+			}
 			num_blocks = findBlocksCommand(data, length, block_switch_cost, num_histograms, histograms, insert_cost, cost, switch_signal, block_ids)
 			num_histograms = remapBlockIdsCommand(block_ids, length, new_id, num_histograms)
 			buildBlockHistogramsCommand(data, length, block_ids, num_histograms, histograms)
@@ -417,6 +417,9 @@ func splitByteVectorDistance(data []uint16, length uint, literals_per_histogram
 
 		var i uint
 		for i = 0; i < iters; i++ {
+			if i > 0 && canceled(params) {
+				break
+			}
 			num_blocks = findBlocksDistance(data, length, block_switch_cost, num_histograms, histograms, insert_cost, cost, switch_signal, block_ids)
 			num_histograms = remapBlockIdsDistance(block_ids, length, new_id, num_histograms)
 			buildBlockHistogramsDistance(data, length, block_ids, num_histograms, histograms)
@@ -417,6 +417,9 @@ func splitByteVectorLiteral(data []byte, length uint, literals_per_histogram uin
 
 		var i uint
 		for i = 0; i < iters; i++ {
+			if i > 0 && canceled(params) {
+				break
+			}
 			num_blocks = findBlocksLiteral(data, length, block_switch_cost, num_histograms, histograms, insert_cost, cost, switch_signal, block_ids)
 			num_histograms = remapBlockIdsLiteral(block_ids, length, new_id, num_histograms)
 			buildBlockHistogramsLiteral(data, length, block_ids, num_histograms, histograms)
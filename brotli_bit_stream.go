@@ -568,7 +568,9 @@ func chooseBitDepths(histogram []uint32, depth []byte, maxBits int) {
 			totalCount += n
 		}
 	}
-	slices.SortFunc(symbols, func(a, b symbolAndCount) int {
+	// Symbols with equal counts are kept in order by symbol value, so that
+	// the result doesn't depend on the sorting algorithm.
+	slices.SortStableFunc(symbols, func(a, b symbolAndCount) int {
 		return int(b.count) - int(a.count)
 	})
 
@@ -8,18 +8,33 @@ package brotli
 import (
 	"bytes"
 	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"flag"
 	"fmt"
+	"hash/crc32"
 	"io"
 	"io/ioutil"
 	"math"
+	"math/bits"
 	"math/rand"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"runtime"
 	"strconv"
+	"strings"
 	"testing"
+	"testing/iotest"
 	"time"
 
+	"github.com/andybalholm/brotli/flate"
 	"github.com/andybalholm/brotli/matchfinder"
 	"github.com/xyproto/randomstring"
+	"golang.org/x/text/transform"
 )
 
 func checkCompressedData(compressedData, wantOriginalData []byte) error {
@@ -601,6 +616,27 @@ func BenchmarkEncodeLevelsResetV2(b *testing.B) {
 	}
 }
 
+func BenchmarkEncodeLevelsPipelineV2(b *testing.B) {
+	opticks, err := ioutil.ReadFile("testdata/Isaac.Newton-Opticks.txt")
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for level := BestSpeed; level <= 9; level++ {
+		w := NewWriterV2(nil, level)
+		w.Pipeline = 2
+		b.Run(fmt.Sprintf("%d", level), func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(opticks)))
+			for i := 0; i < b.N; i++ {
+				w.Reset(ioutil.Discard)
+				w.Write(opticks)
+				w.Close()
+			}
+		})
+	}
+}
+
 func BenchmarkDecodeLevels(b *testing.B) {
 	opticks, err := ioutil.ReadFile("testdata/Isaac.Newton-Opticks.txt")
 	if err != nil {
@@ -787,6 +823,10 @@ func TestEncodePathfinderChain256(t *testing.T) {
 	test(t, "testdata/Isaac.Newton-Opticks.txt", &matchfinder.Pathfinder{MaxDistance: 1 << 18, ChainLength: 256}, 1<<16)
 }
 
+func TestEncodePathfinderCostModel(t *testing.T) {
+	test(t, "testdata/Isaac.Newton-Opticks.txt", &matchfinder.Pathfinder{MaxDistance: 1 << 18, CostModel: CostModel{}}, 1<<16)
+}
+
 func BenchmarkEncodePathfinder(b *testing.B) {
 	benchmark(b, "testdata/Isaac.Newton-Opticks.txt", &matchfinder.Pathfinder{MaxDistance: 1 << 20}, 1<<16)
 }
@@ -931,6 +971,284 @@ func BenchmarkEncodeBargain1Skip(b *testing.B) {
 	benchmark(b, "testdata/Isaac.Newton-Opticks.txt", &matchfinder.Bargain1{MaxDistance: 1 << 20, Skip: true}, 1<<16)
 }
 
+func TestEncodeBinaryTree(t *testing.T) {
+	test(t, "testdata/Isaac.Newton-Opticks.txt", &matchfinder.BinaryTree{MaxDistance: 1 << 20}, 1<<16)
+}
+
+func BenchmarkEncodeBinaryTree(b *testing.B) {
+	benchmark(b, "testdata/Isaac.Newton-Opticks.txt", &matchfinder.BinaryTree{MaxDistance: 1 << 20}, 1<<16)
+}
+
+func TestEncodeBinaryTreeSmallWindow(t *testing.T) {
+	// A window smaller than the block size exercises history trimming, and
+	// the runs of zeros exercise long matches.
+	text, err := ioutil.ReadFile("testdata/Isaac.Newton-Opticks.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var data []byte
+	for i := 0; i < len(text); i += 10000 {
+		data = append(data, text[i:min(i+10000, len(text))]...)
+		data = append(data, make([]byte, i%3000)...)
+	}
+
+	b := new(bytes.Buffer)
+	w := &matchfinder.Writer{
+		Dest:        b,
+		MatchFinder: &matchfinder.BinaryTree{MaxDistance: 1000},
+		Encoder:     &Encoder{},
+		BlockSize:   4096,
+	}
+	w.Write(data)
+	w.Close()
+	if err := checkCompressedData(b.Bytes(), data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMatchStreamReplay(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/Isaac.Newton-Opticks.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Record the matches from Bargain3.
+	recording := new(bytes.Buffer)
+	w := &matchfinder.Writer{
+		Dest:        recording,
+		MatchFinder: &matchfinder.Bargain3{MaxDistance: 1 << 20},
+		Encoder:     matchfinder.MatchStreamEncoder{},
+		BlockSize:   1 << 16,
+	}
+	w.Write(data)
+	w.Close()
+
+	// Replaying them should give the same output as using Bargain3 directly.
+	direct := new(bytes.Buffer)
+	w = &matchfinder.Writer{
+		Dest:        direct,
+		MatchFinder: &matchfinder.Bargain3{MaxDistance: 1 << 20},
+		Encoder:     &Encoder{},
+		BlockSize:   1 << 16,
+	}
+	w.Write(data)
+	w.Close()
+
+	replay := &matchfinder.ReplayMatchFinder{Stream: recording.Bytes()}
+	replayed := new(bytes.Buffer)
+	w = &matchfinder.Writer{
+		Dest:        replayed,
+		MatchFinder: replay,
+		Encoder:     &Encoder{},
+		BlockSize:   1 << 16,
+	}
+	w.Write(data)
+	w.Close()
+	if replay.Err != nil {
+		t.Fatal(replay.Err)
+	}
+	if !bytes.Equal(direct.Bytes(), replayed.Bytes()) {
+		t.Fatal("replayed output doesn't match")
+	}
+
+	// Read the blocks back without the original data, and encode them
+	// directly.
+	r := matchfinder.NewMatchStreamReader(recording.Bytes())
+	e := &Encoder{}
+	var compressed, decoded []byte
+	for {
+		src, matches, lastBlock, err := r.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded = append(decoded, src...)
+		compressed = e.Encode(compressed, src, matches, lastBlock)
+	}
+	if !bytes.Equal(decoded, data) {
+		t.Fatal("data read from match stream doesn't match")
+	}
+	if !bytes.Equal(compressed, direct.Bytes()) {
+		t.Fatal("output from match stream reader doesn't match")
+	}
+
+	// Replaying with different data should fail, but still produce valid
+	// output.
+	changed := bytes.ToUpper(data)
+	replay.Reset()
+	replayed.Reset()
+	w.Reset(replayed)
+	w.Write(changed)
+	w.Close()
+	if replay.Err == nil {
+		t.Fatal("no error replaying with the wrong data")
+	}
+	if err := checkCompressedData(replayed.Bytes(), changed); err != nil {
+		t.Fatal(err)
+	}
+
+	// So should changing a byte that is covered by a match, where the
+	// literals still agree.
+	r = matchfinder.NewMatchStreamReader(recording.Bytes())
+	matchPos := -1
+	for pos := 0; matchPos < 0; {
+		src, matches, _, err := r.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		p := pos
+		for _, m := range matches {
+			p += m.Unmatched
+			if m.Length > 0 {
+				matchPos = p + m.Length/2
+				break
+			}
+			p += m.Length
+		}
+		pos += len(src)
+	}
+	changed = bytes.Clone(data)
+	changed[matchPos] ^= 0x20
+	replay.Reset()
+	replayed.Reset()
+	w.Reset(replayed)
+	w.Write(changed)
+	w.Close()
+	if replay.Err == nil {
+		t.Fatal("no error replaying with a byte changed inside a match")
+	}
+	if err := checkCompressedData(replayed.Bytes(), changed); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheckedMatchFinders(t *testing.T) {
+	text, err := ioutil.ReadFile("testdata/Isaac.Newton-Opticks.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	random := make([]byte, 100000)
+	rand.New(rand.NewSource(1)).Read(random)
+	var mixed []byte
+	mixed = append(mixed, text[:50000]...)
+	mixed = append(mixed, make([]byte, 70000)...)
+	mixed = append(mixed, random...)
+	mixed = append(mixed, text[:50000]...)
+
+	for _, data := range [][]byte{text, mixed, make([]byte, 100000)} {
+		for _, blockSize := range []int{1 << 16, 1000} {
+			for _, m := range []matchfinder.MatchFinder{
+				&matchfinder.M4{MaxDistance: 1 << 16, ChainLength: 16},
+				&matchfinder.ZFast{MaxDistance: 1 << 16},
+				&matchfinder.ZDFast{MaxDistance: 1 << 16},
+				&matchfinder.ZM{MaxDistance: 1 << 16},
+				&matchfinder.Trio{MaxDistance: 1 << 16},
+				&matchfinder.Pathfinder{MaxDistance: 1 << 16, ChainLength: 16},
+				&matchfinder.Bargain1{MaxDistance: 1 << 16},
+				&matchfinder.Bargain2{MaxDistance: 1 << 16},
+				&matchfinder.Bargain3{MaxDistance: 1 << 16},
+				&matchfinder.BinaryTree{MaxDistance: 1 << 16},
+				&matchfinder.LDM{MatchFinder: &matchfinder.ZFast{MaxDistance: 1 << 16}, MaxDistance: 1 << 16},
+			} {
+				c := &matchfinder.Checked{MatchFinder: m, MaxDistance: 1 << 16}
+				w := &matchfinder.Writer{
+					Dest:        io.Discard,
+					MatchFinder: c,
+					Encoder:     &Encoder{},
+					BlockSize:   blockSize,
+				}
+				w.Write(data)
+				w.Close()
+				if c.Err != nil {
+					t.Errorf("%T with block size %d: %v", m, blockSize, c.Err)
+				}
+			}
+		}
+	}
+}
+
+func TestMatchFinderHistory(t *testing.T) {
+	// With a small MaxDistance, the history is trimmed many times. Some of
+	// the blocks are too short to search for matches, but they still need
+	// to be added to the history.
+	text, err := ioutil.ReadFile("testdata/Isaac.Newton-Opticks.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	blockSizes := []int{3, 4096, 15, 700, 19, 1 << 13}
+
+	for _, m := range []matchfinder.MatchFinder{
+		&matchfinder.M4{MaxDistance: 1 << 12, ChainLength: 16},
+		&matchfinder.ZM{MaxDistance: 1 << 12},
+		&matchfinder.Trio{MaxDistance: 1 << 12},
+		&matchfinder.Pathfinder{MaxDistance: 1 << 12, ChainLength: 16},
+		&matchfinder.Bargain1{MaxDistance: 1 << 12},
+		&matchfinder.Bargain2{MaxDistance: 1 << 12},
+		&matchfinder.Bargain3{MaxDistance: 1 << 12},
+	} {
+		c := &matchfinder.Checked{MatchFinder: m, MaxDistance: 1 << 12}
+		for pass := 0; pass < 2; pass++ {
+			var matches []matchfinder.Match
+			for i, rest := 0, text; len(rest) > 0; i++ {
+				n := min(blockSizes[i%len(blockSizes)], len(rest))
+				matches = c.FindMatches(matches[:0], rest[:n])
+				rest = rest[n:]
+			}
+			if c.Err != nil {
+				t.Errorf("%T (pass %d): %v", m, pass, c.Err)
+			}
+			c.Reset()
+		}
+	}
+}
+
+// badMatchFinder returns the matches from ZFast, with the distance of the
+// second match in the third block changed.
+type badMatchFinder struct {
+	matchfinder.ZFast
+	blocks int
+}
+
+func (b *badMatchFinder) FindMatches(dst []matchfinder.Match, src []byte) []matchfinder.Match {
+	start := len(dst)
+	dst = b.ZFast.FindMatches(dst, src)
+	if b.blocks == 2 {
+		dst[start+1].Distance++
+	}
+	b.blocks++
+	return dst
+}
+
+func TestCheckedBadMatch(t *testing.T) {
+	data, err := ioutil.ReadFile("testdata/Isaac.Newton-Opticks.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &matchfinder.Checked{MatchFinder: &badMatchFinder{}}
+	b := new(bytes.Buffer)
+	w := &matchfinder.Writer{
+		Dest:        b,
+		MatchFinder: c,
+		Encoder:     &Encoder{},
+		BlockSize:   1 << 16,
+	}
+	w.Write(data)
+	w.Close()
+
+	var me *matchfinder.MatchError
+	if !errors.As(c.Err, &me) {
+		t.Fatalf("got error %v, want a *MatchError", c.Err)
+	}
+	if me.Block != 2 || me.Index != 1 {
+		t.Errorf("error is for match %d in block %d; want match 1 in block 2", me.Index, me.Block)
+	}
+	if err := checkCompressedData(b.Bytes(), data); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func TestEncodeZDFast(t *testing.T) {
 	test(t, "testdata/Isaac.Newton-Opticks.txt", &matchfinder.ZDFast{MaxDistance: 1 << 20}, 1<<16)
 }
@@ -1037,3 +1355,1586 @@ func TestV2FalseMatchZeroVal(t *testing.T) {
 		}
 	}
 }
+
+func TestFastEncoderIncompressible(t *testing.T) {
+	data := make([]byte, 1<<18)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	var buf bytes.Buffer
+	w := NewWriterV2(&buf, 0)
+	w.Write(data)
+	w.Close()
+
+	// Each 64 KB block should be stored with just a few bytes of overhead.
+	if buf.Len() > len(data)+32 {
+		t.Errorf("compressed size = %d, want <= %d", buf.Len(), len(data)+32)
+	}
+	if err := checkCompressedData(buf.Bytes(), data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEncoderIncompressible(t *testing.T) {
+	data := make([]byte, 1<<18)
+	rand.New(rand.NewSource(1)).Read(data)
+
+	for level := 1; level <= 9; level++ {
+		var buf bytes.Buffer
+		w := NewWriterV2(&buf, level)
+		w.Write(data)
+		w.Close()
+
+		if buf.Len() > len(data)+32 {
+			t.Errorf("level %d: compressed size = %d, want <= %d", level, buf.Len(), len(data)+32)
+		}
+		if err := checkCompressedData(buf.Bytes(), data); err != nil {
+			t.Fatalf("level %d: %v", level, err)
+		}
+	}
+}
+
+func TestWriterStats(t *testing.T) {
+	data, err := os.ReadFile("testdata/Isaac.Newton-Opticks.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for level := BestSpeed; level <= BestCompression; level++ {
+		var buf bytes.Buffer
+		w := NewWriterLevel(&buf, level)
+		w.Write(data)
+		w.Close()
+
+		s := w.Stats()
+		if s.BytesIn != int64(len(data)) || s.BytesOut != int64(buf.Len()) {
+			t.Errorf("level %d: BytesIn, BytesOut = %d, %d; want %d, %d", level, s.BytesIn, s.BytesOut, len(data), buf.Len())
+		}
+		if s.Blocks == 0 {
+			t.Errorf("level %d: no blocks counted", level)
+		}
+		if level > 1 {
+			if s.Literals+s.MatchLength != int64(len(data)) {
+				t.Errorf("level %d: Literals + MatchLength = %d, want %d", level, s.Literals+s.MatchLength, len(data))
+			}
+			if s.AverageMatchDistance() < 1 || s.AverageMatchLength() < 2 {
+				t.Errorf("level %d: implausible averages: length %v, distance %v", level, s.AverageMatchLength(), s.AverageMatchDistance())
+			}
+		}
+	}
+
+	for level := 0; level <= 9; level++ {
+		var buf bytes.Buffer
+		w := NewWriterV2(&buf, level)
+		w.Write(data)
+		w.Close()
+
+		s := w.Stats()
+		if s.BytesIn != int64(len(data)) || s.BytesOut != int64(buf.Len()) {
+			t.Errorf("V2 level %d: BytesIn, BytesOut = %d, %d; want %d, %d", level, s.BytesIn, s.BytesOut, len(data), buf.Len())
+		}
+		if s.Literals+s.MatchLength != int64(len(data)) {
+			t.Errorf("V2 level %d: Literals + MatchLength = %d, want %d", level, s.Literals+s.MatchLength, len(data))
+		}
+		if want := int64(len(data)+(1<<16)-1) >> 16; s.Blocks != want {
+			t.Errorf("V2 level %d: Blocks = %d, want %d", level, s.Blocks, want)
+		}
+	}
+}
+
+func TestWriterContext(t *testing.T) {
+	data, err := os.ReadFile("testdata/Isaac.Newton-Opticks.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, level := range []int{0, 1, 5, 10, 11} {
+		var buf bytes.Buffer
+		var lastProcessed, lastWritten int64
+		w := NewWriterContext(context.Background(), &buf, WriterOptions{
+			Quality: level,
+			Progress: func(processed, written int64) {
+				if processed < lastProcessed || written < lastWritten {
+					t.Errorf("level %d: progress went backward: (%d, %d) after (%d, %d)", level, processed, written, lastProcessed, lastWritten)
+				}
+				lastProcessed, lastWritten = processed, written
+			},
+		})
+		if _, err := w.Write(data); err != nil {
+			t.Fatalf("level %d: Write: %v", level, err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatalf("level %d: Close: %v", level, err)
+		}
+		if lastProcessed != int64(len(data)) || lastWritten != int64(buf.Len()) {
+			t.Errorf("level %d: final progress = (%d, %d), want (%d, %d)", level, lastProcessed, lastWritten, len(data), buf.Len())
+		}
+		if err := checkCompressedData(buf.Bytes(), data); err != nil {
+			t.Errorf("level %d: %v", level, err)
+		}
+	}
+
+	for _, level := range []int{0, 1, 5, 10, 11} {
+		ctx, cancel := context.WithCancel(context.Background())
+		w := NewWriterContext(ctx, io.Discard, WriterOptions{
+			Quality: level,
+			LGWin:   16,
+			Progress: func(processed, written int64) {
+				cancel()
+			},
+		})
+		_, err := w.Write(data)
+		if err == nil {
+			err = w.Close()
+		}
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("level %d: got error %v, want %v", level, err, context.Canceled)
+		}
+		if _, err := w.Write(data); !errors.Is(err, context.Canceled) {
+			t.Errorf("level %d: Write after cancellation returned %v", level, err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	w := NewWriterContext(ctx, io.Discard, WriterOptions{Quality: 11})
+	if n, err := w.Write(data); n != 0 || !errors.Is(err, context.Canceled) {
+		t.Errorf("Write with canceled context = %d, %v", n, err)
+	}
+}
+
+func TestWriterContextDeadline(t *testing.T) {
+	data, err := os.ReadFile("testdata/Isaac.Newton-Opticks.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A single Write of the whole file at quality 10 or 11 is one long
+	// meta-block, so the deadline must be noticed inside it.
+	for _, level := range []int{10, 11} {
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		w := NewWriterContext(ctx, io.Discard, WriterOptions{Quality: level})
+		start := time.Now()
+		_, err = w.Write(data)
+		if err == nil {
+			err = w.Close()
+		}
+		cancel()
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("level %d: got error %v, want %v", level, err, context.DeadlineExceeded)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("level %d: canceled compression took %v", level, elapsed)
+		}
+	}
+}
+
+func TestWriterMaxMemory(t *testing.T) {
+	data, err := os.ReadFile("testdata/Isaac.Newton-Opticks.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, level := range []int{0, 2, 4, 6, 9, 11} {
+		unlimited := EstimateMemory(WriterOptions{Quality: level})
+		if got := EstimateMemory(WriterOptions{Quality: level, MaxMemory: unlimited}); got != unlimited {
+			t.Errorf("level %d: EstimateMemory with a budget of %d = %d", level, unlimited, got)
+		}
+
+		prev := unlimited
+		for _, budget := range []int{64 << 20, 16 << 20, 4 << 20, 1 << 20} {
+			options := WriterOptions{Quality: level, MaxMemory: budget}
+			estimate := EstimateMemory(options)
+			if estimate > budget || estimate > prev {
+				t.Errorf("level %d: EstimateMemory with a budget of %d = %d", level, budget, estimate)
+			}
+			prev = estimate
+
+			var buf bytes.Buffer
+			w := NewWriterOptions(&buf, options)
+			w.Write(data)
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if err := checkCompressedData(buf.Bytes(), data); err != nil {
+				t.Errorf("level %d, budget %d: %v", level, budget, err)
+			}
+		}
+	}
+}
+
+func TestWriterV2MaxMemory(t *testing.T) {
+	data, err := os.ReadFile("testdata/Isaac.Newton-Opticks.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, level := range []int{0, 2, 4, 6, 9} {
+		unlimited := EstimateMemoryV2(WriterV2Options{Level: level, WindowBits: 22, BlockSize: 1 << 20})
+		if got := EstimateMemoryV2(WriterV2Options{Level: level, WindowBits: 22, BlockSize: 1 << 20, MaxMemory: unlimited}); got != unlimited {
+			t.Errorf("level %d: EstimateMemoryV2 with a budget of %d = %d", level, unlimited, got)
+		}
+
+		prev := unlimited
+		for _, budget := range []int{16 << 20, 8 << 20, 4 << 20, 2 << 20} {
+			options := WriterV2Options{Level: level, WindowBits: 22, BlockSize: 1 << 20, MaxMemory: budget}
+			estimate := EstimateMemoryV2(options)
+			if estimate > budget || estimate > prev {
+				t.Errorf("level %d: EstimateMemoryV2 with a budget of %d = %d", level, budget, estimate)
+			}
+			prev = estimate
+
+			var buf bytes.Buffer
+			w := NewWriterV2Options(&buf, options)
+			w.Write(data)
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if err := checkCompressedData(buf.Bytes(), data); err != nil {
+				t.Errorf("level %d, budget %d: %v", level, budget, err)
+			}
+		}
+	}
+}
+
+// longDistanceTestData returns 1 MB of text, followed by 17 MB of random
+// data, and then the text again, beyond the range of a standard Brotli
+// window.
+func longDistanceTestData(t *testing.T) []byte {
+	text, err := os.ReadFile("testdata/Isaac.Newton-Opticks.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := make([]byte, 19<<20)
+	for i := 0; i < 1<<20; i += len(text) {
+		copy(data[i:1<<20], text)
+	}
+	rand.New(rand.NewSource(1)).Read(data[1<<20 : 18<<20])
+	copy(data[18<<20:], data[:1<<20])
+	return data
+}
+
+func TestLDM(t *testing.T) {
+	data := longDistanceTestData(t)
+
+	compress := func(data []byte) []byte {
+		var buf bytes.Buffer
+		w := &matchfinder.Writer{
+			Dest: &buf,
+			MatchFinder: &matchfinder.LDM{
+				MatchFinder: &matchfinder.ZFast{MaxDistance: 1 << 20},
+				MaxDistance: 1 << 26,
+			},
+			Encoder:   &Encoder{WindowBits: 26},
+			BlockSize: 1 << 16,
+		}
+		w.Write(data)
+		w.Close()
+		return buf.Bytes()
+	}
+
+	// With the long-distance match, the repeated text should cost almost
+	// nothing.
+	compressed := compress(data)
+	withoutRepeat := compress(data[:18<<20])
+	if len(compressed) > len(withoutRepeat)+1<<10 {
+		t.Errorf("compressed size = %d, want about %d", len(compressed), len(withoutRepeat))
+	}
+	buf := bytes.NewBuffer(compressed)
+
+	if _, err := io.ReadAll(NewReader(bytes.NewReader(buf.Bytes()))); err == nil {
+		t.Error("large-window stream decoded without ReaderOptions.LargeWindow")
+	}
+	decoded, err := io.ReadAll(NewReaderOptions(bytes.NewReader(buf.Bytes()), ReaderOptions{LargeWindow: true}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decoded, data) {
+		t.Fatal("decoded output does not match original input")
+	}
+}
+
+func TestWriterLargeWindow(t *testing.T) {
+	data := longDistanceTestData(t)
+
+	var buf bytes.Buffer
+	w := NewWriterOptions(&buf, WriterOptions{Quality: 5, LGWin: 26, LargeWindow: true})
+	w.Write(data)
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The repeated text should cost much less than the first copy of it.
+	var textOnly bytes.Buffer
+	w = NewWriterOptions(&textOnly, WriterOptions{Quality: 5})
+	w.Write(data[:1<<20])
+	w.Close()
+	if buf.Len() > 17<<20+textOnly.Len()*5/4 {
+		t.Errorf("compressed size = %d; the long-distance match was not found", buf.Len())
+	}
+
+	decoded, err := io.ReadAll(NewReaderOptions(bytes.NewReader(buf.Bytes()), ReaderOptions{LargeWindow: true}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decoded, data) {
+		t.Fatal("decoded output does not match original input")
+	}
+}
+
+func TestWriterV2Options(t *testing.T) {
+	data, err := os.ReadFile("testdata/Isaac.Newton-Opticks.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, windowBits := range []int{10, 16, 17, 22} {
+		for _, level := range []int{0, 5} {
+			var buf bytes.Buffer
+			w := NewWriterV2Options(&buf, WriterV2Options{Level: level, WindowBits: windowBits, BlockSize: 1 << 18})
+			w.Write(data)
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+
+			// The stream header should declare the window size.
+			var header uint16
+			var headerBits byte
+			encodeWindowBits(windowBits, false, &header, &headerBits)
+			compressed := buf.Bytes()
+			got := (uint16(compressed[0]) | uint16(compressed[1])<<8) & (1<<headerBits - 1)
+			if got != header {
+				t.Errorf("level %d, WindowBits %d: header = %#x, want %#x", level, windowBits, got, header)
+			}
+			if err := checkCompressedData(compressed, data); err != nil {
+				t.Errorf("level %d, WindowBits %d: %v", level, windowBits, err)
+			}
+		}
+	}
+
+	// A window over 24 bits makes a large-window stream.
+	var buf bytes.Buffer
+	w := NewWriterV2Options(&buf, WriterV2Options{Level: 4, WindowBits: 25})
+	w.Write(data)
+	w.Close()
+	if _, err := io.ReadAll(NewReader(bytes.NewReader(buf.Bytes()))); err == nil {
+		t.Error("large-window stream decoded without ReaderOptions.LargeWindow")
+	}
+	decoded, err := io.ReadAll(NewReaderOptions(bytes.NewReader(buf.Bytes()), ReaderOptions{LargeWindow: true}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decoded, data) {
+		t.Fatal("decoded output does not match original input")
+	}
+
+	// The Encoder option overrides the one chosen by Level.
+	buf.Reset()
+	e := &FastEncoder{WindowBits: 18}
+	w = NewWriterV2Options(&buf, WriterV2Options{Level: 6, WindowBits: 18, Encoder: e})
+	if w.Encoder != e {
+		t.Error("Encoder option was not used")
+	}
+	w.Write(data)
+	w.Close()
+	if err := checkCompressedData(buf.Bytes(), data); err != nil {
+		t.Error(err)
+	}
+}
+
+// limitedWriter returns an error once more than n bytes have been written
+// to it.
+type limitedWriter struct {
+	n int
+}
+
+var errLimitedWriter = errors.New("limitedWriter: limit reached")
+
+func (w *limitedWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		n := w.n
+		w.n = 0
+		return n, errLimitedWriter
+	}
+	w.n -= len(p)
+	return len(p), nil
+}
+
+func TestWriterPipeline(t *testing.T) {
+	text, err := os.ReadFile("testdata/Isaac.Newton-Opticks.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	random := make([]byte, 300000)
+	rand.New(rand.NewSource(1)).Read(random)
+	data := append(append(text[:300000:300000], random...), text...)
+
+	// compress writes data to w in chunks, reusing the same buffer for each
+	// chunk, so that the Writer can't keep a reference to it.
+	compress := func(w *matchfinder.Writer, dst io.Writer, data []byte) error {
+		w.Reset(dst)
+		chunk := make([]byte, 12345)
+		for len(data) > 0 {
+			n := copy(chunk, data)
+			data = data[n:]
+			if _, err := w.Write(chunk[:n]); err != nil {
+				return err
+			}
+		}
+		return w.Close()
+	}
+
+	for _, blockSize := range []int{0, 1 << 16} {
+		for _, level := range []int{0, 4, 7} {
+			var want bytes.Buffer
+			w := NewWriterV2(nil, level)
+			w.BlockSize = blockSize
+			if err := compress(w, &want, data); err != nil {
+				t.Fatal(err)
+			}
+			wantStats := w.Stats()
+
+			for _, pipeline := range []int{1, 4} {
+				w := NewWriterV2(nil, level)
+				w.BlockSize = blockSize
+				w.Pipeline = pipeline
+
+				// Abandon a stream partway through, to check that Reset
+				// stops the pipeline cleanly.
+				w.Reset(io.Discard)
+				w.Write(data[:200000])
+
+				var got bytes.Buffer
+				if err := compress(w, &got, data); err != nil {
+					t.Fatal(err)
+				}
+				if !bytes.Equal(got.Bytes(), want.Bytes()) {
+					t.Errorf("level %d, BlockSize %d, Pipeline %d: output differs from unpipelined Writer", level, blockSize, pipeline)
+				}
+				gotStats := w.Stats()
+				gotStats.MatchFindingTime, gotStats.EncodingTime = wantStats.MatchFindingTime, wantStats.EncodingTime
+				if gotStats != wantStats {
+					t.Errorf("level %d, BlockSize %d, Pipeline %d: Stats() = %+v, want %+v", level, blockSize, pipeline, gotStats, wantStats)
+				}
+
+				if err := compress(w, &limitedWriter{n: 50000}, data); err != errLimitedWriter {
+					t.Errorf("level %d, BlockSize %d, Pipeline %d: got error %v, want %v", level, blockSize, pipeline, err, errLimitedWriter)
+				}
+			}
+		}
+	}
+}
+
+// mixedContent returns data that alternates between text, base64, and
+// binary data, like a multipart message with attachments.
+func mixedContent(t *testing.T) []byte {
+	text, err := os.ReadFile("testdata/Isaac.Newton-Opticks.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := rand.New(rand.NewSource(1))
+	random := make([]byte, 30000)
+	r.Read(random)
+	var data []byte
+	for i := 0; i < 4; i++ {
+		data = append(data, text[i*20000:i*20000+25000]...)
+		data = base64.StdEncoding.AppendEncode(data, random[:20000+i*1000])
+		for j := 0; j < 15000; j++ {
+			data = append(data, byte(r.Intn(16))*3)
+		}
+	}
+	return data
+}
+
+func TestWriterAdaptiveBlocks(t *testing.T) {
+	data := mixedContent(t)
+	for _, level := range []int{1, 5} {
+		var fixed, adaptive bytes.Buffer
+		w := NewWriterV2Options(&fixed, WriterV2Options{Level: level})
+		w.Write(data)
+		w.Close()
+		w = NewWriterV2Options(&adaptive, WriterV2Options{Level: level, AdaptiveBlocks: true})
+		w.Write(data)
+		w.Close()
+		if err := checkCompressedData(adaptive.Bytes(), data); err != nil {
+			t.Fatalf("level %d: %v", level, err)
+		}
+		if adaptive.Len() >= fixed.Len()*97/100 {
+			t.Errorf("level %d: adaptive blocks = %d bytes, fixed blocks = %d bytes", level, adaptive.Len(), fixed.Len())
+		}
+
+		// Writing in small pieces, or with pipelining, shouldn't change
+		// where the blocks end.
+		var pieces bytes.Buffer
+		w = NewWriterV2Options(&pieces, WriterV2Options{Level: level, AdaptiveBlocks: true})
+		w.Pipeline = 2
+		for rest := data; len(rest) > 0; {
+			n := min(len(rest), 1000)
+			w.Write(rest[:n])
+			rest = rest[n:]
+		}
+		w.Close()
+		if !bytes.Equal(pieces.Bytes(), adaptive.Bytes()) {
+			t.Errorf("level %d: output changed when writing in small pieces", level)
+		}
+	}
+
+	// Homogeneous text shouldn't be split.
+	text, err := os.ReadFile("testdata/Isaac.Newton-Opticks.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &matchfinder.EntropySplitter{}
+	for pos := 0; pos+1<<16 <= len(text); pos += 1 << 16 {
+		if n := s.Split(text[pos : pos+1<<16]); n != 1<<16 {
+			t.Errorf("text at %d split at %d", pos, n)
+		}
+	}
+	if n := s.Split(data[:1<<16]); n < 24000 || n > 26000 {
+		t.Errorf("mixed content split at %d, want about 25000", n)
+	}
+}
+
+// structuredRecords returns a table of fixed-size binary records.
+func structuredRecords(recordSize int) []byte {
+	r := rand.New(rand.NewSource(1))
+	var data []byte
+	for i := 0; len(data) < 300000; i++ {
+		data = binary.LittleEndian.AppendUint32(data, uint32(i))
+		data = binary.LittleEndian.AppendUint32(data, uint32(r.Intn(50))*1000)
+		for len(data)%recordSize != 0 {
+			data = append(data, byte(r.Intn(8)))
+		}
+	}
+	return data
+}
+
+func TestDistanceParams(t *testing.T) {
+	for d := 1; d < 100000; d += 1 + d/8 {
+		if got, want := getDistanceCodeWithParams(d, 0, 0), getDistanceCode(d); got != want {
+			t.Fatalf("distance %d: getDistanceCodeWithParams = %+v, getDistanceCode = %+v", d, got, want)
+		}
+	}
+
+	// When all the distances are multiples of 8, postfix bits should help.
+	r := rand.New(rand.NewSource(1))
+	distances := make([]int, 5000)
+	for i := range distances {
+		distances[i] = 8 * (1 + r.Intn(2000))
+	}
+	if npostfix, _ := optimizeDistanceParams(distances, make([]uint32, numDistanceShortCodes), maxDistanceBits); npostfix != 3 {
+		t.Errorf("NPOSTFIX = %d, want 3", npostfix)
+	}
+
+	for _, recordSize := range []int{8, 16} {
+		data := structuredRecords(recordSize)
+		for _, windowBits := range []int{22, 26} {
+			for _, level := range []int{1, 5, 9} {
+				var buf bytes.Buffer
+				w := NewWriterV2Options(&buf, WriterV2Options{Level: level, WindowBits: windowBits})
+				w.Write(data)
+				w.Close()
+				decoded, err := io.ReadAll(NewReaderOptions(&buf, ReaderOptions{LargeWindow: windowBits > 24}))
+				if err != nil {
+					t.Fatalf("records of %d bytes, WindowBits %d, level %d: %v", recordSize, windowBits, level, err)
+				}
+				if !bytes.Equal(decoded, data) {
+					t.Fatalf("records of %d bytes, WindowBits %d, level %d: decoded output doesn't match", recordSize, windowBits, level)
+				}
+			}
+		}
+	}
+}
+
+// sampledData returns 16-bit samples of a smooth signal with some noise.
+func sampledData() []byte {
+	r := rand.New(rand.NewSource(1))
+	var data []byte
+	for i := 0; i < 150000; i++ {
+		v := 8000*math.Sin(float64(i)/50) + 3000*math.Sin(float64(i)/7.3) + float64(r.Intn(16))
+		data = binary.LittleEndian.AppendUint16(data, uint16(int16(v)))
+	}
+	return data
+}
+
+func TestFilters(t *testing.T) {
+	text, err := os.ReadFile("testdata/Isaac.Newton-Opticks.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	random := make([]byte, 100000)
+	rand.New(rand.NewSource(1)).Read(random)
+	// Lots of overlapping things that look like CALL instructions.
+	calls := make([]byte, 100000)
+	for i := range calls {
+		calls[i] = []byte{0xe8, 0xe9, 0, 0xff, byte(i)}[random[i]%5]
+	}
+	exe, err := os.ReadFile(os.Args[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	exe = exe[:min(len(exe), 1<<20)]
+
+	for _, c := range []struct {
+		name      string
+		newFilter func() matchfinder.Filter
+		data      []byte // data that the filter should make more compressible
+	}{
+		{"delta", func() matchfinder.Filter { return &matchfinder.DeltaFilter{Distance: 2} }, sampledData()},
+		{"stride", func() matchfinder.Filter { return &matchfinder.StrideFilter{Width: 16} }, structuredRecords(16)},
+		{"x86", func() matchfinder.Filter { return &matchfinder.X86Filter{} }, exe},
+	} {
+		for _, data := range [][]byte{c.data, text, random, calls, text[:3], nil} {
+			// Filtering in pieces should give the same result as all at
+			// once, and decoding should reverse it.
+			f := c.newFilter()
+			whole := f.Encode(nil, data, true)
+			f.Reset()
+			var pieces []byte
+			for rest := data; len(rest) > 0; {
+				n := min(len(rest), 1+len(rest)%1777)
+				pieces = f.Encode(pieces, rest[:n], false)
+				rest = rest[n:]
+			}
+			pieces = f.Encode(pieces, nil, true)
+			if !bytes.Equal(pieces, whole) {
+				t.Fatalf("%s: output changed when filtering in pieces", c.name)
+			}
+			f.Reset()
+			var decoded []byte
+			for rest := whole; len(rest) > 0; {
+				n := min(len(rest), 1+len(rest)%1231)
+				decoded = f.Decode(decoded, rest[:n], false)
+				rest = rest[n:]
+			}
+			decoded = f.Decode(decoded, nil, true)
+			if !bytes.Equal(decoded, data) {
+				t.Fatalf("%s: decoded data doesn't match", c.name)
+			}
+
+			for _, level := range []int{0, 5} {
+				var buf bytes.Buffer
+				w := NewWriterV2Options(&buf, WriterV2Options{Level: level, Filter: c.newFilter()})
+				for rest := data; len(rest) > 0; {
+					n := min(len(rest), 1+len(rest)%5003)
+					w.Write(rest[:n])
+					rest = rest[n:]
+				}
+				if err := w.Close(); err != nil {
+					t.Fatal(err)
+				}
+				// A plain Reader returns the filtered data.
+				if err := checkCompressedData(buf.Bytes(), whole); err != nil {
+					t.Fatalf("%s, level %d: %v", c.name, level, err)
+				}
+				got, err := io.ReadAll(NewFilterReader(NewReader(bytes.NewReader(buf.Bytes()))))
+				if err != nil {
+					t.Fatalf("%s, level %d: %v", c.name, level, err)
+				}
+				if !bytes.Equal(got, data) {
+					t.Fatalf("%s, level %d: FilterReader output doesn't match", c.name, level)
+				}
+			}
+		}
+
+		if c.name == "x86" && runtime.GOARCH != "amd64" && runtime.GOARCH != "386" {
+			// The test binary isn't x86 code.
+			continue
+		}
+		var plain, filtered bytes.Buffer
+		w := NewWriterV2Options(&plain, WriterV2Options{Level: 5})
+		w.Write(c.data)
+		w.Close()
+		w = NewWriterV2Options(&filtered, WriterV2Options{Level: 5, Filter: c.newFilter()})
+		w.Write(c.data)
+		w.Close()
+		if filtered.Len() >= plain.Len()*99/100 {
+			t.Errorf("%s: filtered = %d bytes, unfiltered = %d bytes", c.name, filtered.Len(), plain.Len())
+		}
+	}
+
+	// A FilterReader should read streams without a filter too.
+	var buf bytes.Buffer
+	w := NewWriterV2(&buf, 5)
+	w.Write(text)
+	w.Close()
+	r := NewFilterReader(NewReader(&buf))
+	got, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, text) {
+		t.Fatal("FilterReader output doesn't match for a stream without a filter")
+	}
+
+	// Unknown filters should be reported.
+	var bw bitWriter
+	var header uint16
+	var headerBits byte
+	encodeWindowBits(16, false, &header, &headerBits)
+	bw.writeBits(uint(headerBits), uint64(header))
+	storeMetadataBW([]byte(filterMagic+"\x7f\x00"), &bw)
+	bw.writeBits(2, 3)
+	bw.jumpToByteBoundary()
+	r.Reset(bytes.NewReader(bw.dst))
+	if _, err := io.ReadAll(r); err != errUnknownFilter {
+		t.Errorf("reading stream with unknown filter: got %v, want %v", err, errUnknownFilter)
+	}
+
+	// Other metadata blocks before the filter's should be skipped, not
+	// saved.
+	bw = bitWriter{}
+	bw.writeBits(uint(headerBits), uint64(header))
+	for range 100000 {
+		storeMetadataBW(nil, &bw)
+	}
+	for range 1000 {
+		storeMetadataBW(bytes.Repeat([]byte(filterMagic[:3]), 100), &bw)
+	}
+	delta := &matchfinder.DeltaFilter{Distance: 1}
+	storeMetadataBW(filterMetadata(delta), &bw)
+	filtered := delta.Encode(nil, text[:50000], true)
+	e := new(Encoder)
+	e.UnmarshalBinary([]byte{1, 0, 0, 0, 0, 0}) // The header has been written.
+	stream := e.Encode(bw.dst, filtered, []matchfinder.Match{{Unmatched: len(filtered)}}, true)
+	dr := NewReader(bytes.NewReader(stream))
+	got, err = io.ReadAll(NewFilterReader(dr))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, text[:50000]) {
+		t.Fatal("FilterReader output doesn't match after other metadata blocks")
+	}
+	if string(dr.metadata) != string(filterMetadata(delta)) {
+		t.Errorf("saved metadata is %q, want %q", dr.metadata, filterMetadata(delta))
+	}
+
+	// A user-defined filter is recorded as unknown, so a FilterReader
+	// reports an error, and a plain Reader returns the filtered data.
+	whole := (&customFilter{matchfinder.DeltaFilter{Distance: 1}}).Encode(nil, text, true)
+	for _, level := range []int{0, 5} {
+		buf.Reset()
+		w := NewWriterV2Options(&buf, WriterV2Options{Level: level, Filter: &customFilter{matchfinder.DeltaFilter{Distance: 1}}})
+		w.Write(text)
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.ReadAll(NewFilterReader(NewReader(bytes.NewReader(buf.Bytes())))); err != errUnknownFilter {
+			t.Errorf("custom filter, level %d: got error %v, want %v", level, err, errUnknownFilter)
+		}
+		if err := checkCompressedData(buf.Bytes(), whole); err != nil {
+			t.Fatalf("custom filter, level %d: %v", level, err)
+		}
+	}
+}
+
+// customFilter is a Filter that the brotli package doesn't know about.
+type customFilter struct {
+	matchfinder.DeltaFilter
+}
+
+func TestTranscode(t *testing.T) {
+	text, err := os.ReadFile("testdata/Isaac.Newton-Opticks.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	issue22, err := os.ReadFile("testdata/issue22.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	random := make([]byte, 100000)
+	rand.New(rand.NewSource(1)).Read(random)
+
+	var streams [][]byte
+	for _, data := range [][]byte{text, random, nil} {
+		for _, level := range []int{1, 9} {
+			b := new(bytes.Buffer)
+			w, _ := gzip.NewWriterLevel(b, level)
+			w.Write(data)
+			w.Close()
+			streams = append(streams, b.Bytes())
+		}
+	}
+	// Two members in one file.
+	streams = append(streams, append(bytes.Clone(streams[0]), streams[1]...))
+	streams = append(streams, issue22)
+
+	for i, gz := range streams {
+		want, err := flate.DecodeGZIP(nil, gz)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, options := range []TranscodeOptions{{}, {Refine: true}, {WindowBits: 16, Refine: true}, {WindowBits: 26}} {
+			zr, err := flate.NewGZIPReader(bytes.NewReader(gz))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var b bytes.Buffer
+			if err := Transcode(&b, zr, options); err != nil {
+				t.Fatalf("stream %d, %+v: %v", i, options, err)
+			}
+			got, err := io.ReadAll(NewReaderOptions(&b, ReaderOptions{LargeWindow: options.WindowBits > 24}))
+			if err != nil {
+				t.Fatalf("stream %d, %+v: %v", i, options, err)
+			}
+			if !bytes.Equal(got, want) {
+				t.Fatalf("stream %d, %+v: decompressed output doesn't match", i, options)
+			}
+		}
+	}
+
+	// The extra matches from Refine should help a lot with issue22.gz, which
+	// has a lot of repetition at distances greater than 32 KB.
+	sizes := make(map[bool]int)
+	for _, refine := range []bool{false, true} {
+		zr, err := flate.NewGZIPReader(bytes.NewReader(issue22))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var b bytes.Buffer
+		Transcode(&b, zr, TranscodeOptions{Refine: refine})
+		sizes[refine] = b.Len()
+	}
+	if sizes[true] > sizes[false]/2 {
+		t.Errorf("transcoding issue22.gz: %d bytes with Refine, %d bytes without", sizes[true], sizes[false])
+	}
+
+	// Errors from the source should be returned.
+	corrupted := bytes.Clone(streams[0])
+	corrupted[len(corrupted)-5]++
+	zr, err := flate.NewGZIPReader(bytes.NewReader(corrupted))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Transcode(io.Discard, zr, TranscodeOptions{}); err != flate.ErrChecksum {
+		t.Errorf("transcoding corrupted data: got %v, want %v", err, flate.ErrChecksum)
+	}
+}
+
+func TestReaderReadMatches(t *testing.T) {
+	text, err := os.ReadFile("testdata/Isaac.Newton-Opticks.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := append(bytes.Clone(text), text[:100000]...)
+
+	var streams [][]byte
+	compress := func(w io.WriteCloser, b *bytes.Buffer) {
+		w.Write(data)
+		w.Close()
+		streams = append(streams, b.Bytes())
+	}
+	for _, quality := range []int{1, 6, 11} {
+		b := new(bytes.Buffer)
+		compress(NewWriterOptions(b, WriterOptions{Quality: quality, LGWin: 18}), b)
+	}
+	b := new(bytes.Buffer)
+	compress(NewWriterOptions(b, WriterOptions{Quality: 5, LGWin: 26, LargeWindow: true}), b)
+	b = new(bytes.Buffer)
+	compress(NewWriterV2Options(b, WriterV2Options{Level: 5, WindowBits: 22}), b)
+
+	for i, stream := range streams {
+		r := NewReaderOptions(bytes.NewReader(stream), ReaderOptions{LargeWindow: true})
+		var decoded []byte
+		var matches []matchfinder.Match
+		for err = nil; err == nil; {
+			n := len(matches)
+			start := len(decoded)
+			decoded, matches, err = r.ReadMatches(decoded, matches)
+			covered := 0
+			for _, m := range matches[n:] {
+				covered += m.Unmatched + m.Length
+			}
+			if covered != len(decoded)-start {
+				t.Fatalf("stream %d: matches cover %d bytes, got %d bytes of data", i, covered, len(decoded)-start)
+			}
+		}
+		if err != io.EOF {
+			t.Fatalf("stream %d: %v", i, err)
+		}
+		if !bytes.Equal(decoded, data) {
+			t.Fatalf("stream %d: decoded data doesn't match", i)
+		}
+
+		// Replaying the matches should reproduce the data.
+		var replayed []byte
+		for _, m := range matches {
+			replayed = append(replayed, data[len(replayed):len(replayed)+m.Unmatched]...)
+			if m.Length > 0 && (m.Distance < 1 || m.Distance > len(replayed)) {
+				t.Fatalf("stream %d: invalid match %+v at position %d", i, m, len(replayed))
+			}
+			for range m.Length {
+				replayed = append(replayed, replayed[len(replayed)-m.Distance])
+			}
+		}
+		if !bytes.Equal(replayed, data) {
+			t.Fatalf("stream %d: replaying the matches doesn't give the same data", i)
+		}
+
+		// Convert the stream to gzip.
+		r.Reset(bytes.NewReader(stream))
+		var gz bytes.Buffer
+		tc := &matchfinder.Transcoder{
+			Dest:        &gz,
+			Encoder:     flate.NewGZIPEncoder(),
+			MaxDistance: 1 << 15,
+			MinLength:   3,
+		}
+		if err := tc.Transcode(r); err != nil {
+			t.Fatalf("stream %d: %v", i, err)
+		}
+		zr, err := gzip.NewReader(&gz)
+		if err != nil {
+			t.Fatalf("stream %d: %v", i, err)
+		}
+		got, err := io.ReadAll(zr)
+		if err != nil {
+			t.Fatalf("stream %d: error decompressing gzip: %v", i, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Fatalf("stream %d: gzip output doesn't match", i)
+		}
+
+		// Convert it to raw deflate, leaving it to the Encoder to deal with
+		// brotli's 2-byte copies.
+		r.Reset(bytes.NewReader(stream))
+		var deflated bytes.Buffer
+		tc = &matchfinder.Transcoder{
+			Dest:        &deflated,
+			Encoder:     flate.NewEncoder(),
+			MaxDistance: 1 << 15,
+		}
+		if err := tc.Transcode(r); err != nil {
+			t.Fatalf("stream %d: %v", i, err)
+		}
+		got, err = io.ReadAll(flate.NewReader(&deflated))
+		if err != nil {
+			t.Fatalf("stream %d: error decompressing deflate: %v", i, err)
+		}
+		if !bytes.Equal(got, data) {
+			t.Fatalf("stream %d: deflate output doesn't match", i)
+		}
+	}
+
+	// After Read, ReadMatches reports only the rest of the data.
+	r := NewReader(bytes.NewReader(streams[2]))
+	start := make([]byte, 12345)
+	if _, err := io.ReadFull(r, start); err != nil {
+		t.Fatal(err)
+	}
+	rest, matches, err := r.ReadMatches(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadFull(r, start); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.copies) != 0 {
+		t.Fatalf("Read after ReadMatches left %d copies recorded", len(r.copies))
+	}
+	for err == nil {
+		rest, matches, err = r.ReadMatches(rest, matches)
+	}
+	if err != io.EOF {
+		t.Fatal(err)
+	}
+	covered := 0
+	for _, m := range matches {
+		covered += m.Unmatched + m.Length
+	}
+	if len(rest) != len(data)-2*len(start) || covered != len(rest) {
+		t.Fatalf("after Read: got %d bytes of data, and matches for %d bytes", len(rest), covered)
+	}
+}
+
+func TestReaderCheckpoint(t *testing.T) {
+	text, err := os.ReadFile("testdata/Isaac.Newton-Opticks.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	random := make([]byte, 50000)
+	rand.New(rand.NewSource(1)).Read(random)
+	data := append(bytes.Clone(text), random...)
+
+	var streams [][]byte
+	for _, quality := range []int{0, 1, 5, 11} {
+		compressed, _ := Encode(data, WriterOptions{Quality: quality, LGWin: 18})
+		streams = append(streams, compressed)
+	}
+	compressed, _ := Encode(data, WriterOptions{Quality: 5, LGWin: 26, LargeWindow: true})
+	streams = append(streams, compressed)
+	b := new(bytes.Buffer)
+	w := NewWriterV2Options(b, WriterV2Options{Level: 5, WindowBits: 22})
+	w.Write(data)
+	w.Close()
+	streams = append(streams, b.Bytes())
+
+	for i, stream := range streams {
+		for _, n := range []int{0, 1, 1000, 100000, len(data) / 2, len(data) - 1, len(data)} {
+			for _, oneByte := range []bool{false, true} {
+				var src io.Reader = bytes.NewReader(stream)
+				if oneByte {
+					src = iotest.OneByteReader(src)
+				}
+				r := NewReaderOptions(src, ReaderOptions{LargeWindow: true})
+				if _, err := io.ReadFull(r, make([]byte, n)); err != nil {
+					t.Fatalf("stream %d, offset %d: %v", i, n, err)
+				}
+				c, err := r.Checkpoint()
+				if err != nil {
+					t.Fatalf("stream %d, offset %d: Checkpoint: %v", i, n, err)
+				}
+				if c.OutputOffset != int64(n) {
+					t.Fatalf("stream %d, offset %d: checkpoint has OutputOffset %d", i, n, c.OutputOffset)
+				}
+				marshaled, _ := c.MarshalBinary()
+
+				// The original Reader should be able to continue.
+				rest, err := io.ReadAll(r)
+				if err != nil || !bytes.Equal(rest, data[n:]) {
+					t.Fatalf("stream %d, offset %d: after Checkpoint: got %d bytes, error %v", i, n, len(rest), err)
+				}
+
+				c = new(ReaderCheckpoint)
+				if err := c.UnmarshalBinary(marshaled); err != nil {
+					t.Fatalf("stream %d, offset %d: UnmarshalBinary: %v", i, n, err)
+				}
+				r2 := NewReaderOptions(nil, ReaderOptions{LargeWindow: true})
+				if err := r2.Restore(c, bytes.NewReader(stream[c.InputOffset:])); err != nil {
+					t.Fatalf("stream %d, offset %d: Restore: %v", i, n, err)
+				}
+				rest, err = io.ReadAll(r2)
+				if err != nil || !bytes.Equal(rest, data[n:]) {
+					t.Fatalf("stream %d, offset %d: after Restore: got %d bytes, error %v", i, n, len(rest), err)
+				}
+			}
+		}
+	}
+
+	r := NewReader(bytes.NewReader(streams[2]))
+	io.ReadFull(r, make([]byte, 1000))
+	c, _ := r.Checkpoint()
+	marshaled, _ := c.MarshalBinary()
+	marshaled[len(marshaled)/2] ^= 1
+	if err := new(ReaderCheckpoint).UnmarshalBinary(marshaled); err != errInvalidCheckpoint {
+		t.Errorf("corrupted checkpoint: got error %v, want %v", err, errInvalidCheckpoint)
+	}
+	if err := r.Restore(&ReaderCheckpoint{}, nil); err != errInvalidCheckpoint {
+		t.Errorf("empty checkpoint: got error %v, want %v", err, errInvalidCheckpoint)
+	}
+}
+
+func FuzzReaderRestore(f *testing.F) {
+	text, err := os.ReadFile("testdata/Isaac.Newton-Opticks.txt")
+	if err != nil {
+		f.Fatal(err)
+	}
+	text = text[:20000]
+	var streams [][]byte
+	for _, quality := range []int{0, 5, 11} {
+		compressed, _ := Encode(text, WriterOptions{Quality: quality, LGWin: 10})
+		streams = append(streams, compressed)
+	}
+	for i, stream := range streams {
+		for _, n := range []int{0, 1000, 15000} {
+			r := NewReader(bytes.NewReader(stream))
+			io.ReadFull(r, make([]byte, n))
+			c, err := r.Checkpoint()
+			if err != nil {
+				f.Fatal(err)
+			}
+			marshaled, _ := c.MarshalBinary()
+			f.Add(uint8(i), marshaled)
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, i uint8, marshaled []byte) {
+		stream := streams[int(i)%len(streams)]
+		// Fix up the CRC, so that the checkpoint gets as far as Restore.
+		if len(marshaled) < 4 {
+			return
+		}
+		body := marshaled[:len(marshaled)-4]
+		marshaled = binary.LittleEndian.AppendUint32(body[:len(body):len(body)], crc32.ChecksumIEEE(body))
+		c := new(ReaderCheckpoint)
+		if err := c.UnmarshalBinary(marshaled); err != nil || c.InputOffset > int64(len(stream)) {
+			return
+		}
+		r := NewReader(nil)
+		if err := r.Restore(c, bytes.NewReader(stream[c.InputOffset:])); err != nil {
+			return
+		}
+		// The data may be garbage, but reading it mustn't panic.
+		io.Copy(io.Discard, r)
+	})
+}
+
+func TestWriterCheckpoint(t *testing.T) {
+	text, err := os.ReadFile("testdata/Isaac.Newton-Opticks.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	half := len(text) / 2
+
+	for _, test := range []struct {
+		options   WriterV2Options
+		newFilter func() matchfinder.Filter
+	}{
+		{options: WriterV2Options{Level: 0}},
+		{options: WriterV2Options{Level: 5}},
+		{options: WriterV2Options{Level: 5, BlockSize: 1 << 20}},
+		{options: WriterV2Options{Level: 3, AdaptiveBlocks: true}},
+		{WriterV2Options{Level: 4}, func() matchfinder.Filter { return &matchfinder.DeltaFilter{Distance: 2} }},
+		{WriterV2Options{Level: 1}, func() matchfinder.Filter { return &matchfinder.StrideFilter{Width: 4} }},
+		{WriterV2Options{Level: 2}, func() matchfinder.Filter { return &matchfinder.X86Filter{} }},
+	} {
+		for _, pipeline := range []int{0, 2} {
+			options := test.options
+			if test.newFilter != nil {
+				options.Filter = test.newFilter()
+			}
+			name := fmt.Sprintf("level %d, filter %T, pipeline %d", options.Level, options.Filter, pipeline)
+			first := new(bytes.Buffer)
+			w := NewWriterV2Options(first, options)
+			w.Pipeline = pipeline
+			w.Write(text[:half/3])
+			w.Write(text[half/3 : half])
+			c, err := w.Checkpoint()
+			if err != nil {
+				t.Fatalf("%s: Checkpoint: %v", name, err)
+			}
+			if c.InputOffset != int64(half) || c.OutputOffset != int64(first.Len()) {
+				t.Fatalf("%s: checkpoint has offsets %d and %d, want %d and %d", name, c.InputOffset, c.OutputOffset, half, first.Len())
+			}
+			marshaled, _ := c.MarshalBinary()
+
+			// The original Writer should be able to continue.
+			w.Write(text[half:])
+			if err := w.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if got := decodeFiltered(t, first.Bytes()); !bytes.Equal(got, text) {
+				t.Fatalf("%s: after Checkpoint: decoded data doesn't match", name)
+			}
+
+			// Restore the checkpoint in a new Writer with the same settings.
+			if test.newFilter != nil {
+				options.Filter = test.newFilter()
+			}
+			c = new(matchfinder.WriterCheckpoint)
+			if err := c.UnmarshalBinary(marshaled); err != nil {
+				t.Fatalf("%s: UnmarshalBinary: %v", name, err)
+			}
+			second := new(bytes.Buffer)
+			w2 := NewWriterV2Options(nil, options)
+			w2.Pipeline = pipeline
+			if err := w2.Restore(c, second); err != nil {
+				t.Fatalf("%s: Restore: %v", name, err)
+			}
+			w2.Write(text[c.InputOffset:])
+			if err := w2.Close(); err != nil {
+				t.Fatal(err)
+			}
+			resumed := append(first.Bytes()[:c.OutputOffset:c.OutputOffset], second.Bytes()...)
+			if got := decodeFiltered(t, resumed); !bytes.Equal(got, text) {
+				t.Fatalf("%s: after Restore: decoded data doesn't match", name)
+			}
+		}
+	}
+
+	w := &matchfinder.Writer{
+		MatchFinder: &matchfinder.ZFast{MaxDistance: 1 << 15},
+		Encoder:     flate.NewGZIPEncoder(),
+		BlockSize:   1 << 16,
+	}
+	w.Reset(io.Discard)
+	if _, err := w.Checkpoint(); err == nil {
+		t.Error("no error from Checkpoint with an Encoder that doesn't support it")
+	}
+}
+
+// decodeFiltered decompresses a stream that may have been written with a
+// Filter.
+func decodeFiltered(t *testing.T, compressed []byte) []byte {
+	t.Helper()
+	decoded, err := io.ReadAll(NewFilterReader(NewReader(bytes.NewReader(compressed))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return decoded
+}
+
+func BenchmarkTranscode(b *testing.B) {
+	text, err := os.ReadFile("testdata/Isaac.Newton-Opticks.txt")
+	if err != nil {
+		b.Fatal(err)
+	}
+	gz := new(bytes.Buffer)
+	w := gzip.NewWriter(gz)
+	w.Write(text)
+	w.Close()
+	for _, refine := range []bool{false, true} {
+		b.Run(fmt.Sprintf("refine=%v", refine), func(b *testing.B) {
+			zr := new(flate.GZIPReader)
+			out := new(bytes.Buffer)
+			b.SetBytes(int64(len(text)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				out.Reset()
+				zr.Reset(bytes.NewReader(gz.Bytes()))
+				Transcode(out, zr, TranscodeOptions{Refine: refine})
+			}
+			b.ReportMetric(float64(len(text))/float64(out.Len()), "ratio")
+		})
+	}
+}
+
+func TestTransformers(t *testing.T) {
+	text, err := os.ReadFile("testdata/Isaac.Newton-Opticks.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, quality := range []int{0, 1, 5, 11} {
+		c := NewCompressor(WriterOptions{Quality: quality, LGWin: 18})
+		compressed, _, err := transform.Bytes(c, text)
+		if err != nil {
+			t.Fatalf("quality %d: transform.Bytes: %v", quality, err)
+		}
+		if err := checkCompressedData(compressed, text); err != nil {
+			t.Fatalf("quality %d: %v", quality, err)
+		}
+
+		d := NewDecompressor(ReaderOptions{})
+		decompressed, err := io.ReadAll(transform.NewReader(bytes.NewReader(compressed), d))
+		if err != nil {
+			t.Fatalf("quality %d: transform.NewReader: %v", quality, err)
+		}
+		if !bytes.Equal(decompressed, text) {
+			t.Fatalf("quality %d: transform.NewReader: decompressed data doesn't match", quality)
+		}
+
+		// Feed both transformers a few bytes at a time, so that every
+		// call ends with a short buffer.
+		c.Reset()
+		compressed = runTransformer(t, c, text, 7, 3)
+		if err := checkCompressedData(compressed, text); err != nil {
+			t.Fatalf("quality %d, small buffers: %v", quality, err)
+		}
+		d.Reset()
+		if decompressed := runTransformer(t, d, compressed, 3, 7); !bytes.Equal(decompressed, text) {
+			t.Fatalf("quality %d, small buffers: decompressed data doesn't match", quality)
+		}
+	}
+
+	compressed, _ := Encode(text, WriterOptions{Quality: 5})
+	d := NewDecompressor(ReaderOptions{})
+	if _, _, err := transform.Bytes(d, compressed[:len(compressed)/2]); err != io.ErrUnexpectedEOF {
+		t.Errorf("truncated stream: got error %v, want %v", err, io.ErrUnexpectedEOF)
+	}
+	d.Reset()
+	if _, _, err := transform.Bytes(d, append(compressed, 0)); err != errExcessiveInput {
+		t.Errorf("trailing data: got error %v, want %v", err, errExcessiveInput)
+	}
+	d.Reset()
+	if _, _, err := transform.Bytes(d, []byte("trash")); err == nil {
+		t.Error("no error for invalid input")
+	}
+	d.Reset()
+	if decompressed, _, err := transform.Bytes(d, compressed); err != nil || !bytes.Equal(decompressed, text) {
+		t.Errorf("after Reset: got %d bytes, error %v", len(decompressed), err)
+	}
+}
+
+// runTransformer runs data through tr, srcSize bytes at a time, with an
+// output buffer of dstSize bytes.
+func runTransformer(t *testing.T, tr transform.Transformer, data []byte, srcSize, dstSize int) []byte {
+	var result []byte
+	dst := make([]byte, dstSize)
+	for {
+		src := data[:min(srcSize, len(data))]
+		atEOF := len(src) == len(data)
+		nDst, nSrc, err := tr.Transform(dst, src, atEOF)
+		result = append(result, dst[:nDst]...)
+		data = data[nSrc:]
+		switch {
+		case err == transform.ErrShortDst:
+		case err != nil:
+			t.Fatalf("Transform: %v", err)
+		case atEOF:
+			return result
+		}
+	}
+}
+
+func TestHTTPDecompressRequest(t *testing.T) {
+	data, err := os.ReadFile("testdata/Isaac.Newton-Opticks.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Compress with gzip, and then with brotli.
+	gz := new(bytes.Buffer)
+	w := flate.NewGZIPWriter(gz, 5)
+	w.Write(data)
+	w.Close()
+	br := new(bytes.Buffer)
+	w = NewWriterV2(br, 5)
+	w.Write(gz.Bytes())
+	w.Close()
+	zl := new(bytes.Buffer)
+	w = flate.NewZLIBWriter(zl, 5)
+	w.Write(data)
+	w.Close()
+
+	for _, c := range []struct {
+		encoding string
+		body     []byte
+	}{
+		{"", data},
+		{"gzip", gz.Bytes()},
+		{"gzip, br", br.Bytes()},
+		{"deflate", zl.Bytes()},
+	} {
+		req := httptest.NewRequest("POST", "/", bytes.NewReader(c.body))
+		if c.encoding != "" {
+			req.Header.Set("Content-Encoding", c.encoding)
+		}
+		body, err := HTTPDecompressRequest(req)
+		if err != nil {
+			t.Fatalf("%q: %v", c.encoding, err)
+		}
+		decompressed, err := io.ReadAll(body)
+		if err != nil {
+			t.Fatalf("%q: %v", c.encoding, err)
+		}
+		body.Close()
+		if !bytes.Equal(decompressed, data) {
+			t.Fatalf("%q: decompressed output doesn't match", c.encoding)
+		}
+		if req.Header.Get("Content-Encoding") != "" {
+			t.Errorf("%q: Content-Encoding header was not removed", c.encoding)
+		}
+	}
+
+	req := httptest.NewRequest("POST", "/", bytes.NewReader(data))
+	req.Header.Set("Content-Encoding", "compress")
+	if _, err := HTTPDecompressRequest(req); err == nil {
+		t.Error("no error for unsupported Content-Encoding")
+	}
+
+	resp := &http.Response{
+		Header: http.Header{"Content-Encoding": {"gzip"}},
+		Body:   io.NopCloser(bytes.NewReader(gz.Bytes())),
+	}
+	body, err := HTTPDecompressResponse(resp)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decompressed, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decompressed, data) {
+		t.Fatal("decompressed response doesn't match")
+	}
+}
+
+func TestHTTPCompressorNegotiation(t *testing.T) {
+	for _, c := range []struct {
+		accept   string
+		encoding string
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"gzip, zstd", "zstd"},
+		{"gzip, zstd, br", "br"},
+		{"br;q=0.5, zstd", "zstd"},
+		{"*", "br"},
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		if c.accept != "" {
+			req.Header.Set("Accept-Encoding", c.accept)
+		}
+		rec := httptest.NewRecorder()
+		w := HTTPCompressor(rec, req)
+		w.Write([]byte("hello, world"))
+		w.Close()
+		if got := rec.Header().Get("Content-Encoding"); got != c.encoding {
+			t.Errorf("Accept-Encoding %q: got Content-Encoding %q, want %q", c.accept, got, c.encoding)
+		}
+		if c.encoding == "zstd" && !bytes.HasPrefix(rec.Body.Bytes(), []byte{0x28, 0xb5, 0x2f, 0xfd}) {
+			t.Errorf("Accept-Encoding %q: response doesn't start with the zstd magic number", c.accept)
+		}
+	}
+}
+
+var updateGolden = flag.Bool("update-golden", false, "rewrite testdata/golden.txt with the current output hashes")
+
+const goldenFile = "testdata/golden.txt"
+
+// goldenCorpus returns the files that TestGoldenOutput compresses.
+func goldenCorpus(t *testing.T) (names []string, files [][]byte) {
+	text, err := os.ReadFile("testdata/Isaac.Newton-Opticks.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open("testdata/issue22.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issue22, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return []string{"Isaac.Newton-Opticks.txt", "issue22"}, [][]byte{text, issue22}
+}
+
+// TestGoldenOutput checks that the compressed output for every level of
+// NewWriterLevel and NewWriterV2 matches the hashes recorded in
+// testdata/golden.txt. The output must be the same on every platform and
+// Go version, so any change to it should be deliberate. When it is, run
+//
+//	go test -run TestGoldenOutput -update-golden
+//
+// and commit the new golden file along with the change.
+func TestGoldenOutput(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping in short mode")
+	}
+	names, files := goldenCorpus(t)
+
+	var lines []string
+	check := func(writerName string, level int, w io.WriteCloser, reset func(io.Writer)) {
+		compress := func(data []byte) string {
+			buf := new(bytes.Buffer)
+			reset(buf)
+			w.Write(data)
+			w.Close()
+			return fmt.Sprintf("%x", sha256.Sum256(buf.Bytes()))
+		}
+		var sums []string
+		for i, data := range files {
+			sums = append(sums, compress(data))
+			lines = append(lines, fmt.Sprintf("%s/%d %s %s", writerName, level, names[i], sums[i]))
+		}
+		// Compress the first file again, to check that reusing the Writer
+		// doesn't change the output.
+		if compress(files[0]) != sums[0] {
+			t.Errorf("%s(%d) %s: output changed when the Writer was reused", writerName, level, names[0])
+		}
+	}
+	for level := BestSpeed; level <= BestCompression; level++ {
+		w := NewWriterLevel(nil, level)
+		check("NewWriterLevel", level, w, w.Reset)
+	}
+	for level := 0; level <= 9; level++ {
+		w := NewWriterV2(nil, level)
+		check("NewWriterV2", level, w, w.Reset)
+	}
+
+	if *updateGolden {
+		header := "# SHA-256 hashes of the compressed output for the files in the test corpus.\n" +
+			"# Generated by go test -run TestGoldenOutput -update-golden\n"
+		if err := os.WriteFile(goldenFile, []byte(header+strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	golden, err := os.ReadFile(goldenFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := make(map[string]string)
+	for _, line := range strings.Split(string(golden), "\n") {
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		i := strings.LastIndexByte(line, ' ')
+		if i < 0 {
+			t.Fatalf("malformed line in %s: %q", goldenFile, line)
+		}
+		want[line[:i]] = line[i+1:]
+	}
+	for _, line := range lines {
+		i := strings.LastIndexByte(line, ' ')
+		key, sum := line[:i], line[i+1:]
+		switch w, ok := want[key]; {
+		case !ok:
+			t.Errorf("%s: missing from %s", key, goldenFile)
+		case w != sum:
+			t.Errorf("%s: output hash is %s, want %s", key, sum, w)
+		}
+	}
+}
+
+func TestLog2(t *testing.T) {
+	if got := matchfinder.Log2(0); got != 0 {
+		t.Errorf("Log2(0) = %v, want 0", got)
+	}
+	for k := range strconv.IntSize {
+		if got := matchfinder.Log2(1 << k); got != float64(k) {
+			t.Errorf("Log2(1<<%d) = %v, want %d", k, got, k)
+		}
+	}
+	for n := uint(1); n < math.MaxUint/3; n = n*3/2 + 1 {
+		if got, want := matchfinder.Log2(n), math.Log2(float64(n)); math.Abs(got-want) > 1e-6 {
+			t.Errorf("Log2(%d) = %v, want %v", n, got, want)
+		}
+	}
+
+	// At n = 2048 + i, matchfinder.Log2 uses entry i of its table exactly.
+	// Check the entries against log2(1 + i/2048) computed bit by bit, by
+	// repeatedly squaring it (in 1.63 fixed-point format).
+	for i := 0; i < 2048; i++ {
+		x := uint64(1)<<63 | uint64(i)<<52
+		var frac uint64
+		for range 39 {
+			hi, lo := bits.Mul64(x, x)
+			x = hi<<1 | lo>>63
+			frac <<= 1
+			if hi >= 1<<63 {
+				x = hi
+				frac |= 1
+			}
+		}
+		want := 11 + float64((frac+1<<7)>>8)/(1<<31)
+		if got := matchfinder.Log2(uint(2048 + i)); got != want {
+			t.Fatalf("Log2(%d) = %v, want %v", 2048+i, got, want)
+		}
+	}
+
+	prev := 0.0
+	for n := uint(1); n < 1<<20; n++ {
+		got := matchfinder.Log2(n)
+		if got < prev {
+			t.Fatalf("Log2(%d) = %v, less than Log2(%d) = %v", n, got, n-1, prev)
+		}
+		prev = got
+		if d := math.Abs(got - math.Log2(float64(n))); d > 1e-7 {
+			t.Fatalf("Log2(%d) = %v, off by %g", n, got, d)
+		}
+	}
+	r := rand.New(rand.NewSource(1))
+	for range 100000 {
+		n := uint(r.Uint64()>>uint(r.Intn(64))) | 1
+		if d := math.Abs(matchfinder.Log2(n) - math.Log2(float64(n))); d > 1e-7 {
+			t.Fatalf("Log2(%d) = %v, off by %g", n, matchfinder.Log2(n), d)
+		}
+	}
+}
+
+func BenchmarkLog2(b *testing.B) {
+	var sum float64
+	for i := 0; i < b.N; i++ {
+		sum += matchfinder.Log2(uint(i&0xfffff + 256))
+	}
+	if sum < 0 {
+		b.Fatal(sum)
+	}
+}
@@ -0,0 +1,296 @@
+package brotli
+
+import (
+	"unsafe"
+
+	"github.com/andybalholm/brotli/matchfinder"
+)
+
+// EstimateMemory returns an estimate of the peak number of bytes of memory
+// a Writer created with options will allocate while compressing. It is
+// meant as an upper bound for typical inputs, for sizing the number of
+// Writers that can run at once; it does not include memory used by the
+// destination io.Writer.
+//
+// If options.MaxMemory is set, the estimate is for the configuration that
+// the Writer will actually use. For the Writers returned by NewWriterV2 and
+// NewWriterV2Options, use EstimateMemoryV2.
+func EstimateMemory(options WriterOptions) int {
+	var params encoderParams
+	encoderInitParams(&params)
+	applyWriterOptions(&params, options)
+	return int(estimateMemory(&params))
+}
+
+// applyWriterOptions sets the parameters specified by options.
+func applyWriterOptions(params *encoderParams, options WriterOptions) {
+	params.quality = options.Quality
+	params.large_window = options.LargeWindow
+	if options.LGWin > 0 {
+		params.lgwin = uint(options.LGWin)
+	}
+	if options.MaxMemory > 0 {
+		fitMemoryBudget(params, uint(options.MaxMemory))
+	}
+}
+
+// fitMemoryBudget adjusts params so that estimateMemory(params) is no more
+// than budget, if possible. It shrinks the input block size first, then the
+// hash tables, then the window (down to 256 KB), and then the quality. If
+// the budget is still exceeded at quality 0, the window is reduced further.
+func fitMemoryBudget(params *encoderParams, budget uint) {
+	sanitizeParams(params)
+	params.lgblock = computeLgBlock(params)
+
+	for estimateMemory(params) > budget {
+		var hparams hasherParams
+		chooseWorstCaseHasher(params, &hparams)
+		windowMemory := uint(1) << uint(computeRbBits(params))
+		if hparams.type_ == 10 {
+			windowMemory += hasherMemory(params, &hparams)
+		}
+
+		switch {
+		case params.quality >= minQualityForBlockSplit && params.lgblock > minInputBlockBits:
+			params.lgblock--
+
+		case (hparams.type_ == 5 || hparams.type_ == 6) && hparams.block_bits > 4 && hasherMemory(params, &hparams) > windowMemory:
+			params.max_hasher_block_bits = hparams.block_bits - 1
+
+		case params.lgwin > 18:
+			params.lgwin--
+
+		case params.quality > minQuality:
+			params.quality--
+			params.lgblock = 0
+
+		case params.lgwin > minWindowBits:
+			params.lgwin--
+
+		default:
+			return
+		}
+
+		params.lgblock = computeLgBlock(params)
+	}
+}
+
+// chooseWorstCaseHasher sets hparams to the parameters of the largest hasher
+// that chooseHasher may select for params, depending on the size of the
+// input.
+func chooseWorstCaseHasher(params *encoderParams, hparams *hasherParams) {
+	p := *params
+	p.size_hint = 0
+	chooseHasher(&p, hparams)
+
+	var large hasherParams
+	p.size_hint = 1 << 30
+	chooseHasher(&p, &large)
+	if hasherMemory(&p, &large) > hasherMemory(&p, hparams) {
+		*hparams = large
+	}
+}
+
+// hasherMemory returns the number of bytes allocated by the hasher
+// described by hparams.
+func hasherMemory(params *encoderParams, hparams *hasherParams) uint {
+	switch hparams.type_ {
+	case 2, 3, 4, 54:
+		h := newHasher(hparams.type_).(*hashLongestMatchQuickly)
+		return uint(unsafe.Sizeof(*h)) + 4*(1<<h.bucketBits+uint(h.bucketSweep))
+
+	case 5, 6:
+		bucketSize := uint(1) << uint(hparams.bucket_bits)
+		blockSize := uint(1) << uint(hparams.block_bits)
+		return uint(unsafe.Sizeof(h6{})) + 2*bucketSize + 4*bucketSize*blockSize
+
+	case 10:
+		return uint(unsafe.Sizeof(h10{})) + 8<<params.lgwin
+
+	case 40, 41, 42:
+		h := newHasher(hparams.type_).(*hashForgetfulChain)
+		bucketSize := uint(1) << h.bucketBits
+		bankSize := uint(1) << h.bankBits
+		return uint(unsafe.Sizeof(*h)) + 6*bucketSize + h.numBanks*(bankSize*uint(unsafe.Sizeof(slot{}))+2)
+
+	case 35, 55, 65:
+		// H3, H54, or H6 combined with a rolling hash.
+		inner := *hparams
+		switch hparams.type_ {
+		case 35:
+			inner.type_ = 3
+		case 55:
+			inner.type_ = 54
+		case 65:
+			inner.type_ = 6
+		}
+		return hasherMemory(params, &inner) + uint(unsafe.Sizeof(hashRolling{})) + 4*16777216
+	}
+
+	return 0
+}
+
+// estimateMemory returns the approximate peak memory usage of a Writer
+// using params.
+func estimateMemory(params *encoderParams) uint {
+	p := *params
+	sanitizeParams(&p)
+	p.lgblock = computeLgBlock(&p)
+
+	total := uint(unsafe.Sizeof(Writer{}))
+
+	if p.quality == fastOnePassCompressionQuality || p.quality == fastTwoPassCompressionQuality {
+		blockSize := uint(1) << p.lgwin
+		total += 2*blockSize + 503
+		total += maxHashTableSize(p.quality) * uint(unsafe.Sizeof(int(0)))
+		if p.quality == fastTwoPassCompressionQuality {
+			total += 5 * brotli_min_size_t(kCompressFragmentTwoPassBlockSize, blockSize)
+		}
+		return total
+	}
+
+	blockSize := uint(1) << uint(p.lgblock)
+	metablockSize := maxMetablockSize(&p)
+
+	// The ring buffer, hasher, and storage for the compressed output.
+	total += uint(1)<<uint(computeRbBits(&p)) + blockSize + 2 + kSlackForEightByteHashingEverywhere
+	var hparams hasherParams
+	chooseWorstCaseHasher(&p, &hparams)
+	total += hasherMemory(&p, &hparams)
+	total += 2*metablockSize + 503
+
+	// A meta-block is flushed when it reaches metablockSize/8 commands or
+	// literals, or maxNumDelayedSymbols at low quality levels.
+	maxCommands := metablockSize / 8
+	if p.quality < minQualityForBlockSplit {
+		maxCommands = maxNumDelayedSymbols
+	}
+	// The command buffer grows as needed, so the old one may still be live
+	// when the new one is allocated.
+	total += 2 * (maxCommands + blockSize*3/4 + 17) * uint(unsafe.Sizeof(command{}))
+
+	switch {
+	case p.quality >= hqZopflificationQuality:
+		total += blockSize * (4 + 2*4*uint(unsafe.Sizeof(backwardMatch{})) + uint(unsafe.Sizeof(zopfliNode{})) + 4)
+	case p.quality >= zopflificationQuality:
+		total += blockSize * (uint(unsafe.Sizeof(zopfliNode{})) + 4)
+	}
+
+	if p.quality >= minQualityForBlockSplit {
+		// Copies of the literals, command codes, and distance codes for
+		// block splitting, and the histograms for each block type.
+		total += maxCommands + 4*maxCommands
+		numLiteralHistograms := uint(1)
+		if p.quality >= minQualityForHqBlockSplitting {
+			numLiteralHistograms = brotli_min_size_t(kMaxLiteralHistograms, maxCommands/kSymbolsPerLiteralHistogram+1)
+		}
+		if p.quality >= minQualityForContextModeling {
+			numLiteralHistograms <<= literalContextBits
+		}
+		total += 2 * numLiteralHistograms * uint(unsafe.Sizeof(histogramLiteral{}))
+		total += 2 * kMaxCommandHistograms * (uint(unsafe.Sizeof(histogramCommand{})) + uint(unsafe.Sizeof(histogramDistance{}))<<distanceContextBits)
+	}
+
+	return total
+}
+
+// EstimateMemoryV2 is like EstimateMemory, but for a Writer created by
+// NewWriterV2Options. Most of the memory goes to the match finder's hash
+// tables, which have a fixed size for each level (from about 256 KB at
+// levels 0 and 1 to 7 MB at level 9), and to its history buffer, which
+// holds up to twice the window size.
+//
+// The Writer collects the data from each call to Write before compressing
+// it, so the estimate assumes that no more than BlockSize bytes are written
+// at a time. If options.MaxMemory is set, the estimate is for the
+// configuration that the Writer will actually use.
+func EstimateMemoryV2(options WriterV2Options) int {
+	normalizeV2Options(&options)
+	return int(estimateMemoryV2(&options))
+}
+
+// fitMemoryBudgetV2 adjusts options, which must already be normalized, so
+// that estimateMemoryV2(options) is no more than budget, if possible. It
+// shrinks the block size (down to 16 KB), then the window (down to 256
+// KB), then the level, and then the window again.
+func fitMemoryBudgetV2(options *WriterV2Options, budget uint) {
+	for estimateMemoryV2(options) > budget {
+		switch {
+		case options.BlockSize > 1<<14:
+			options.BlockSize = max(options.BlockSize/2, 1<<14)
+
+		case options.WindowBits > 18:
+			options.WindowBits--
+
+		case options.Level > 0:
+			options.Level--
+
+		case options.WindowBits > minWindowBits:
+			options.WindowBits--
+
+		default:
+			return
+		}
+	}
+}
+
+// v2ArrivalSize is the size of the entries in the shortest-path search
+// array that the Bargain match finders use (levels 5 and up).
+const v2ArrivalSize = 12
+
+// estimateMemoryV2 returns the approximate peak memory usage of a Writer
+// created by NewWriterV2Options with options, which must already be
+// normalized.
+func estimateMemoryV2(options *WriterV2Options) uint {
+	blockSize := uint(options.BlockSize)
+	maxDistance := uint(1)<<options.WindowBits - windowGap
+
+	total := uint(unsafe.Sizeof(matchfinder.Writer{}))
+
+	// The match finder's hash tables are part of its struct.
+	switch options.Level {
+	case 0, 1:
+		total += uint(unsafe.Sizeof(matchfinder.ZFast{}))
+	case 2:
+		total += uint(unsafe.Sizeof(matchfinder.ZDFast{}))
+	case 3:
+		total += uint(unsafe.Sizeof(matchfinder.ZM{}))
+	case 4:
+		total += uint(unsafe.Sizeof(matchfinder.Trio{}))
+	case 5, 6:
+		total += uint(unsafe.Sizeof(matchfinder.Bargain1{}))
+	case 7, 8:
+		total += uint(unsafe.Sizeof(matchfinder.Bargain2{}))
+	case 9:
+		total += uint(unsafe.Sizeof(matchfinder.Bargain3{}))
+	}
+
+	// The history. ZFast and ZDFast allocate it once; the others let it
+	// grow to twice the window plus a block before trimming it, and append
+	// may leave some extra capacity.
+	if options.Level <= 2 {
+		total += max(2*maxDistance, 1<<20, blockSize)
+	} else {
+		total += (2*maxDistance + blockSize) * 5 / 4
+	}
+	if options.Level >= 5 {
+		total += blockSize * v2ArrivalSize
+	}
+
+	// The input buffer (which may hold nearly two blocks), the filtered
+	// copy of a block, and the compressed output.
+	total += 3 * blockSize
+	if options.Filter != nil {
+		total += blockSize
+	}
+	// The matches for a block, copied by the match finder and the
+	// Encoder, with room for about one match every 8 bytes.
+	total += blockSize / 8 * uint(2*unsafe.Sizeof(matchfinder.Match{})+unsafe.Sizeof(distanceCode{}))
+
+	if options.AdaptiveBlocks {
+		// EntropySplitter's byte counts, 1 KB for each 1 KB of input.
+		total += blockSize
+	}
+
+	return total
+}
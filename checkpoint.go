@@ -0,0 +1,548 @@
+package brotli
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"math"
+	"slices"
+)
+
+// readerCheckpointMagic starts a marshaled ReaderCheckpoint. The last
+// byte is the format version.
+const readerCheckpointMagic = "BRCKPT\x01"
+
+var errInvalidCheckpoint = errors.New("brotli: invalid checkpoint")
+
+// A ReaderCheckpoint is a saved state of a Reader. It can be used to
+// resume decompressing a stream partway through (for example, after a
+// network connection is lost) without starting again from the beginning.
+//
+// A checkpoint includes the decoder's window, so it can be as large as
+// the window size declared in the stream (up to 16 MB, or more for a
+// "Large Window Brotli" stream).
+type ReaderCheckpoint struct {
+	// InputOffset is the number of bytes of compressed data that the
+	// Reader had consumed. A Reader restored from the checkpoint continues
+	// with the compressed data that starts at this offset.
+	InputOffset int64
+
+	// OutputOffset is the number of bytes of decompressed data that the
+	// Reader had returned. A restored Reader returns the data that comes
+	// after this point.
+	OutputOffset int64
+
+	state []byte
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (c *ReaderCheckpoint) MarshalBinary() ([]byte, error) {
+	b := []byte(readerCheckpointMagic)
+	b = binary.AppendUvarint(b, uint64(c.InputOffset))
+	b = binary.AppendUvarint(b, uint64(c.OutputOffset))
+	b = append(b, c.state...)
+	return binary.LittleEndian.AppendUint32(b, crc32.ChecksumIEEE(b)), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler. It checks that
+// the data is a complete checkpoint, but the decoder state is not
+// validated until the checkpoint is passed to Restore.
+func (c *ReaderCheckpoint) UnmarshalBinary(data []byte) error {
+	body, ok := checkCRC(data)
+	if !ok {
+		return errInvalidCheckpoint
+	}
+	body, ok = bytes.CutPrefix(body, []byte(readerCheckpointMagic))
+	if !ok {
+		return errInvalidCheckpoint
+	}
+	d := &stateReader{buf: body}
+	in := d.uvarint(math.MaxInt64)
+	out := d.uvarint(math.MaxInt64)
+	if d.err != nil {
+		return d.err
+	}
+	c.InputOffset = int64(in)
+	c.OutputOffset = int64(out)
+	c.state = append([]byte(nil), d.buf...)
+	return nil
+}
+
+// checkCRC checks the CRC-32 at the end of data, and returns the data
+// without it.
+func checkCRC(data []byte) ([]byte, bool) {
+	if len(data) < 4 {
+		return nil, false
+	}
+	body := data[:len(data)-4]
+	return body, crc32.ChecksumIEEE(body) == binary.LittleEndian.Uint32(data[len(body):])
+}
+
+// Checkpoint saves the Reader's state, so that decompression can be
+// resumed later with Restore.
+//
+// The decoder's state can only be saved at certain points in the stream:
+// between commands, or between meta-blocks. So Checkpoint may need to
+// decode a little further, reading more compressed data from the source.
+// The data that it decodes is kept for later calls to Read, and it is
+// included in the checkpoint.
+//
+// The checkpoint doesn't include the matches that ReadMatches has not
+// reported yet, or the state of a FilterReader.
+func (r *Reader) Checkpoint() (*ReaderCheckpoint, error) {
+	if r.error_code < 0 {
+		return nil, decodeError(r.error_code)
+	}
+	if !r.atCheckpoint() {
+		if err := r.decodeToCheckpoint(); err != nil {
+			return nil, err
+		}
+	}
+	return &ReaderCheckpoint{
+		InputOffset:  r.inputRead - int64(len(r.in)),
+		OutputOffset: int64(r.outputPos()),
+		state:        r.saveState(),
+	}, nil
+}
+
+// Restore discards the Reader's state, and replaces it with the state
+// saved in c. It will read the rest of the compressed stream (starting at
+// c.InputOffset) from src.
+func (r *Reader) Restore(c *ReaderCheckpoint, src io.Reader) error {
+	r.Reset(src)
+	if err := r.restoreState(c.state); err != nil {
+		r.Reset(src)
+		return err
+	}
+	if int64(r.outputPos()) != c.OutputOffset {
+		r.Reset(src)
+		return errInvalidCheckpoint
+	}
+	r.inputRead = c.InputOffset
+	r.reportedPos = r.outputPos()
+	return nil
+}
+
+// atCheckpoint reports whether the decoder is at a point where its state
+// can be saved.
+func (r *Reader) atCheckpoint() bool {
+	if r.buffer_length != 0 || r.should_wrap_ringbuffer != 0 {
+		return false
+	}
+	switch r.state {
+	case stateUninited, stateMetablockBegin, stateCommandBegin, stateDone:
+		return true
+	}
+	return false
+}
+
+// decodeToCheckpoint decodes until the decoder reaches a point where its
+// state can be saved, putting the decompressed data in r.pending.
+func (r *Reader) decodeToCheckpoint() error {
+	r.checkpointRequested = true
+	defer func() {
+		r.checkpointRequested = false
+	}()
+
+	for !r.atCheckpoint() {
+		r.pending = slices.Grow(r.pending, readBufSize)
+		out := r.pending[len(r.pending):cap(r.pending)]
+		outRemaining := uint(len(out))
+		inRemaining := uint(len(r.in))
+		result := decoderDecompressStream(r, &inRemaining, &r.in, &outRemaining, &out)
+		r.pending = r.pending[:cap(r.pending)-int(outRemaining)]
+
+		switch result {
+		case decoderResultError:
+			return decodeError(decoderGetErrorCode(r))
+		case decoderResultNeedsMoreInput:
+			n, err := r.src.Read(r.buf)
+			r.inputRead += int64(n)
+			if n == 0 && err != nil {
+				if err == io.EOF {
+					return io.ErrUnexpectedEOF
+				}
+				return err
+			}
+			r.in = r.buf[:n]
+		}
+	}
+	return nil
+}
+
+// saveState serializes the parts of the decoder's state that are in use
+// at a checkpoint.
+func (r *Reader) saveState() []byte {
+	w := new(stateWriter)
+	w.uvarint(uint64(r.state))
+	w.bool(r.large_window)
+	w.uvarint(uint64(r.window_bits))
+	w.uvarint(r.br.val_)
+	w.uvarint(uint64(r.br.bit_pos_))
+
+	w.uvarint(uint64(r.ringbuffer_size))
+	w.uvarint(uint64(r.new_ringbuffer_size))
+	w.uvarint(uint64(r.pos))
+	w.uvarint(uint64(r.rb_roundtrips))
+	w.uvarint(uint64(r.partial_pos_out))
+	w.uvarint(uint64(r.max_distance))
+	w.uvarint(uint64(r.canny_ringbuffer_allocation))
+	if r.ringbuffer_size > 0 {
+		w.bytes(r.ringbuffer[:r.ringbuffer_size])
+	}
+	w.bytes(r.pending)
+	for _, d := range r.dist_rb {
+		w.uvarint(uint64(d))
+	}
+	w.uvarint(uint64(r.dist_rb_idx & 3))
+	w.uvarint(uint64(r.is_last_metablock))
+
+	if r.state != stateCommandBegin {
+		return w.buf
+	}
+
+	// The rest is the state of the current meta-block.
+	w.uvarint(uint64(r.meta_block_remaining_len))
+	for i := range 3 {
+		w.uvarint(uint64(r.num_block_types[i]))
+		w.uvarint(uint64(r.block_length[i]))
+	}
+	for _, t := range r.block_type_rb {
+		w.uvarint(uint64(t))
+	}
+	w.codes(r.block_type_trees)
+	w.uvarint(uint64(r.distance_postfix_bits))
+	w.uvarint(uint64(r.num_direct_distance_codes))
+	w.uvarint(uint64(r.num_literal_htrees))
+	w.uvarint(uint64(r.num_dist_htrees))
+	w.bytes(r.context_modes)
+	w.bytes(r.context_map)
+	w.bytes(r.dist_context_map)
+	for _, t := range r.trivial_literal_contexts {
+		w.uvarint(uint64(t))
+	}
+	w.uvarint(uint64(r.distance_context))
+	w.uvarint(uint64(r.dist_htree_index))
+	for _, g := range []*huffmanTreeGroup{&r.literal_hgroup, &r.insert_copy_hgroup, &r.distance_hgroup} {
+		w.codes(g.codes)
+		for _, t := range g.htrees {
+			w.uvarint(uint64(cap(g.codes) - cap(t)))
+		}
+	}
+	return w.buf
+}
+
+// restoreState reverses saveState. It checks the values that decoding
+// uses as indexes (including the entries of the Huffman tables), so that
+// a corrupted checkpoint gives an error instead of a panic, but it doesn't
+// check that the Huffman tables are complete prefix codes; a checkpoint
+// that was tampered with may decode to garbage.
+func (r *Reader) restoreState(state []byte) error {
+	d := &stateReader{buf: state}
+	r.state = int(d.uvarint(stateDone))
+	switch r.state {
+	case stateUninited, stateMetablockBegin, stateCommandBegin, stateDone:
+	default:
+		return errInvalidCheckpoint
+	}
+	r.large_window = d.bool()
+	if r.large_window && !r.options.LargeWindow {
+		return errInvalidCheckpoint
+	}
+	maxBits := uint64(maxWindowBits)
+	if r.large_window {
+		maxBits = largeMaxWbits
+	}
+	r.window_bits = uint32(d.uvarint(maxBits))
+	r.br.val_ = d.uvarint(math.MaxUint64)
+	r.br.bit_pos_ = uint32(d.uvarint(64))
+
+	windowSize := uint64(1) << r.window_bits
+	r.ringbuffer_size = int(d.uvarint(windowSize))
+	r.new_ringbuffer_size = int(d.uvarint(windowSize))
+	r.pos = int(d.uvarint(uint64(r.ringbuffer_size)))
+	r.rb_roundtrips = uint(d.uvarint(math.MaxInt64 / windowSize))
+	r.partial_pos_out = uint(d.uvarint(math.MaxInt64))
+	r.max_distance = int(d.uvarint(windowSize))
+	r.canny_ringbuffer_allocation = uint(d.uvarint(1))
+	if d.err != nil {
+		return d.err
+	}
+	switch {
+	case !isPowerOfTwoOrZero(r.ringbuffer_size) || !isPowerOfTwoOrZero(r.new_ringbuffer_size):
+		return errInvalidCheckpoint
+	case r.state == stateUninited && (r.ringbuffer_size != 0 || r.new_ringbuffer_size != 0):
+		return errInvalidCheckpoint
+	case r.state != stateUninited && r.window_bits < largeMinWbits:
+		return errInvalidCheckpoint
+	case r.state == stateCommandBegin && (r.pos == r.ringbuffer_size || r.new_ringbuffer_size != r.ringbuffer_size):
+		return errInvalidCheckpoint
+	}
+	if r.ringbuffer_size > 0 {
+		r.ringbuffer = make([]byte, r.ringbuffer_size+int(kRingBufferWriteAheadSlack))
+		copy(r.ringbuffer, d.bytes(r.ringbuffer_size, r.ringbuffer_size))
+		r.ringbuffer_mask = r.ringbuffer_size - 1
+		r.ringbuffer_end = r.ringbuffer[r.ringbuffer_size:]
+	}
+	if r.partial_pos_out > r.rb_roundtrips*uint(r.ringbuffer_size)+uint(r.pos) ||
+		unwrittenBytes(r, false) > uint(r.ringbuffer_size) {
+		return errInvalidCheckpoint
+	}
+	r.pending = d.bytes(0, int(min(r.partial_pos_out, math.MaxInt32)))
+	for i := range r.dist_rb {
+		r.dist_rb[i] = int(d.uvarint(math.MaxInt32))
+	}
+	r.dist_rb_idx = int(d.uvarint(3))
+	r.is_last_metablock = uint(d.uvarint(1))
+
+	if r.state != stateUninited {
+		r.max_backward_distance = (1 << r.window_bits) - windowGap
+		if r.block_type_trees == nil {
+			r.block_type_trees = make([]huffmanCode, (3 * (huffmanMaxSize258 + huffmanMaxSize26)))
+		}
+		r.block_len_trees = r.block_type_trees[3*huffmanMaxSize258:]
+	}
+	if r.state != stateCommandBegin {
+		if d.err == nil && len(d.buf) > 0 {
+			return errInvalidCheckpoint
+		}
+		return d.err
+	}
+
+	r.meta_block_remaining_len = int(d.uvarint(1 << 24))
+	for i := range 3 {
+		r.num_block_types[i] = uint32(d.uvarint(256))
+		r.block_length[i] = uint32(d.uvarint(math.MaxUint32))
+		if r.num_block_types[i] == 0 {
+			return errInvalidCheckpoint
+		}
+	}
+	for i := range r.block_type_rb {
+		r.block_type_rb[i] = uint32(d.uvarint(uint64(r.num_block_types[i/2])))
+		if i%2 == 1 && r.block_type_rb[i] == r.num_block_types[i/2] {
+			// The current block type must be less than the number of types;
+			// the previous one may be 1 when there is only one type.
+			return errInvalidCheckpoint
+		}
+	}
+	clear(r.block_type_trees)
+	d.codes(r.block_type_trees)
+	for i := range 3 {
+		// The trees are only used if there is more than one block type.
+		if r.num_block_types[i] > 1 &&
+			(!validHuffmanTable(r.block_type_trees[i*huffmanMaxSize258:(i+1)*huffmanMaxSize258], r.num_block_types[i]+2) ||
+				!validHuffmanTable(r.block_len_trees[i*huffmanMaxSize26:(i+1)*huffmanMaxSize26], numBlockLenSymbols)) {
+			return errInvalidCheckpoint
+		}
+	}
+	r.distance_postfix_bits = uint32(d.uvarint(3))
+	r.num_direct_distance_codes = uint32(d.uvarint(numDistanceShortCodes + 15<<r.distance_postfix_bits))
+	r.distance_postfix_mask = int(bitMask(r.distance_postfix_bits))
+	direct := r.num_direct_distance_codes - numDistanceShortCodes
+	if r.num_direct_distance_codes < numDistanceShortCodes || direct&uint32(r.distance_postfix_mask) != 0 {
+		return errInvalidCheckpoint
+	}
+	r.num_literal_htrees = uint32(d.uvarint(256))
+	r.num_dist_htrees = uint32(d.uvarint(256))
+	r.context_modes = d.bytes(int(r.num_block_types[0]), int(r.num_block_types[0]))
+	r.context_map = d.bytes(int(r.num_block_types[0]<<literalContextBits), int(r.num_block_types[0]<<literalContextBits))
+	r.dist_context_map = d.bytes(int(r.num_block_types[2]<<distanceContextBits), int(r.num_block_types[2]<<distanceContextBits))
+	for i := range r.trivial_literal_contexts {
+		r.trivial_literal_contexts[i] = uint32(d.uvarint(math.MaxUint32))
+	}
+	r.distance_context = int(d.uvarint(3))
+	r.dist_htree_index = byte(d.uvarint(uint64(max(r.num_dist_htrees, 1) - 1)))
+	if d.err != nil {
+		return d.err
+	}
+	if r.num_literal_htrees == 0 || r.num_dist_htrees == 0 ||
+		uint32(slices.Max(r.context_map)) >= r.num_literal_htrees ||
+		uint32(slices.Max(r.dist_context_map)) >= r.num_dist_htrees {
+		return errInvalidCheckpoint
+	}
+
+	var numDistanceCodes, maxSymbol uint32
+	if r.large_window {
+		numDistanceCodes = uint32(distanceAlphabetSize(uint(r.distance_postfix_bits), uint(direct), largeMaxDistanceBits))
+		maxSymbol = maxDistanceSymbol(direct, r.distance_postfix_bits)
+	} else {
+		numDistanceCodes = uint32(distanceAlphabetSize(uint(r.distance_postfix_bits), uint(direct), maxDistanceBits))
+		maxSymbol = numDistanceCodes
+	}
+	decoderHuffmanTreeGroupInit(&r.literal_hgroup, numLiteralSymbols, numLiteralSymbols, r.num_literal_htrees)
+	decoderHuffmanTreeGroupInit(&r.insert_copy_hgroup, numCommandSymbols, numCommandSymbols, r.num_block_types[1])
+	decoderHuffmanTreeGroupInit(&r.distance_hgroup, numDistanceCodes, maxSymbol, r.num_dist_htrees)
+	for _, g := range []*huffmanTreeGroup{&r.literal_hgroup, &r.insert_copy_hgroup, &r.distance_hgroup} {
+		clear(g.codes)
+		d.codes(g.codes)
+		for i := range g.htrees {
+			g.htrees[i] = g.codes[d.uvarint(uint64(len(g.codes)-1)):]
+			if d.err == nil && !validHuffmanTable(g.htrees[i], uint32(min(g.alphabet_size, g.max_symbol))) {
+				return errInvalidCheckpoint
+			}
+		}
+	}
+	if d.err == nil && len(d.buf) > 0 {
+		return errInvalidCheckpoint
+	}
+	if d.err != nil {
+		return d.err
+	}
+
+	prepareLiteralDecoding(r)
+	r.htree_command = r.insert_copy_hgroup.htrees[r.block_type_rb[3]]
+	r.dist_context_map_slice = r.dist_context_map[r.block_type_rb[5]<<distanceContextBits:]
+	return nil
+}
+
+// validHuffmanTable reports whether table (a root table followed by its
+// second-level tables) can be used to decode symbols without going out of
+// bounds, and whether all the symbols are less than alphabetSize.
+func validHuffmanTable(table []huffmanCode, alphabetSize uint32) bool {
+	if len(table) < 1<<huffmanTableBits {
+		return false
+	}
+	for i, c := range table[:1<<huffmanTableBits] {
+		if c.bits <= huffmanTableBits {
+			if uint32(c.value) >= alphabetSize {
+				return false
+			}
+			continue
+		}
+		// The entry points to a second-level table, c.value entries after
+		// it, indexed by the next c.bits-huffmanTableBits bits.
+		if c.bits > huffmanMaxCodeLength {
+			return false
+		}
+		subBits := c.bits - huffmanTableBits
+		start := i + int(c.value)
+		if start+1<<subBits > len(table) {
+			return false
+		}
+		for _, s := range table[start : start+1<<subBits] {
+			if s.bits > subBits || uint32(s.value) >= alphabetSize {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// appendEncoderState appends the part of an encoder's state that Encoder
+// and FastEncoder have in common: whether the stream header has been
+// written, and the bits that are waiting to be written.
+func appendEncoderState(b []byte, wroteHeader bool, bw *bitWriter) []byte {
+	if wroteHeader {
+		b = append(b, 1)
+	} else {
+		b = append(b, 0)
+	}
+	b = append(b, byte(bw.nbits))
+	return binary.LittleEndian.AppendUint32(b, uint32(bw.bits))
+}
+
+// parseEncoderState reverses appendEncoderState, and returns the rest of
+// data.
+func parseEncoderState(data []byte, wroteHeader *bool, bw *bitWriter) ([]byte, error) {
+	if len(data) < 6 || data[0] > 1 || data[1] >= 32 {
+		return nil, errInvalidCheckpoint
+	}
+	*wroteHeader = data[0] == 1
+	*bw = bitWriter{
+		nbits: uint(data[1]),
+		bits:  uint64(binary.LittleEndian.Uint32(data[2:])) & (1<<data[1] - 1),
+	}
+	return data[6:], nil
+}
+
+func isPowerOfTwoOrZero(n int) bool {
+	return n&(n-1) == 0
+}
+
+// A stateWriter serializes the decoder state for a checkpoint.
+type stateWriter struct {
+	buf []byte
+}
+
+func (w *stateWriter) uvarint(v uint64) {
+	w.buf = binary.AppendUvarint(w.buf, v)
+}
+
+func (w *stateWriter) bool(v bool) {
+	if v {
+		w.buf = append(w.buf, 1)
+	} else {
+		w.buf = append(w.buf, 0)
+	}
+}
+
+func (w *stateWriter) bytes(b []byte) {
+	w.uvarint(uint64(len(b)))
+	w.buf = append(w.buf, b...)
+}
+
+// codes writes a Huffman table, leaving out the unused entries at the
+// end.
+func (w *stateWriter) codes(c []huffmanCode) {
+	n := len(c)
+	for n > 0 && c[n-1] == (huffmanCode{}) {
+		n--
+	}
+	w.uvarint(uint64(n))
+	for _, h := range c[:n] {
+		w.buf = append(w.buf, h.bits, byte(h.value), byte(h.value>>8))
+	}
+}
+
+// A stateReader reads the values written by a stateWriter. After an
+// error, it returns zero values, and err is set.
+type stateReader struct {
+	buf []byte
+	err error
+}
+
+// uvarint reads a value, which must not be greater than limit.
+func (r *stateReader) uvarint(limit uint64) uint64 {
+	if r.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(r.buf)
+	if n <= 0 || v > limit {
+		r.err = errInvalidCheckpoint
+		return 0
+	}
+	r.buf = r.buf[n:]
+	return v
+}
+
+func (r *stateReader) bool() bool {
+	return r.uvarint(1) == 1
+}
+
+// bytes reads a copy of a byte slice whose length is between minLen and
+// maxLen.
+func (r *stateReader) bytes(minLen, maxLen int) []byte {
+	n := int(r.uvarint(uint64(maxLen)))
+	if r.err != nil || n < minLen || n > len(r.buf) {
+		r.err = errInvalidCheckpoint
+		return make([]byte, minLen)
+	}
+	b := append([]byte(nil), r.buf[:n]...)
+	r.buf = r.buf[n:]
+	return b
+}
+
+// codes reads a Huffman table into dst.
+func (r *stateReader) codes(dst []huffmanCode) {
+	n := int(r.uvarint(uint64(len(dst))))
+	if r.err != nil || 3*n > len(r.buf) {
+		r.err = errInvalidCheckpoint
+		return
+	}
+	for i := range dst[:n] {
+		dst[i] = huffmanCode{bits: r.buf[3*i], value: uint16(r.buf[3*i+1]) | uint16(r.buf[3*i+2])<<8}
+	}
+	r.buf = r.buf[3*n:]
+}
@@ -26,5 +26,6 @@ func histogramPairIsLess(p1 *histogramPair, p2 *histogramPair) bool {
 /* Returns entropy reduction of the context map when we combine two clusters. */
 func clusterCostDiff(size_a uint, size_b uint) float64 {
 	var size_c uint = size_a + size_b
-	return float64(size_a)*fastLog2(size_a) + float64(size_b)*fastLog2(size_b) - float64(size_c)*fastLog2(size_c)
+	/* The conversions prevent fused multiply-add, for platform-independent output. */
+	return float64(float64(size_a)*fastLog2(size_a)) + float64(float64(size_b)*fastLog2(size_b)) - float64(float64(size_c)*fastLog2(size_c))
 }
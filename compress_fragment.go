@@ -327,9 +327,9 @@ func shouldMergeBlock(data []byte, len uint, depths []byte) bool {
 	}
 	{
 		var total uint = (len + shouldMergeBlock_kSampleRate - 1) / shouldMergeBlock_kSampleRate
-		var r float64 = (fastLog2(total)+0.5)*float64(total) + 200
+		var r float64 = float64((fastLog2(total)+0.5)*float64(total)) + 200
 		for i = 0; i < 256; i++ {
-			r -= float64(histo[i]) * (float64(depths[i]) + fastLog2(histo[i]))
+			r -= float64(float64(histo[i]) * (float64(depths[i]) + fastLog2(histo[i])))
 		}
 
 		return r >= 0.0
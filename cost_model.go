@@ -0,0 +1,45 @@
+package brotli
+
+import (
+	"math/bits"
+
+	"github.com/andybalholm/brotli/matchfinder"
+)
+
+// CostModel is a matchfinder.CostModel for Encoder. It estimates the costs
+// of insert lengths, copy lengths, and distances from the extra bits of
+// their prefix codes.
+type CostModel struct{}
+
+func (CostModel) LiteralCosts(costs *[256]float32, src []byte) {
+	matchfinder.LiteralCosts(costs, src)
+}
+
+func (CostModel) InsertCost(n int) float32 {
+	code := getInsertLengthCode(uint(n))
+	return float32(kInsExtra[code]) + float32(bits.Len16(code))/2
+}
+
+func (CostModel) LengthCost(length int) float32 {
+	// The insert and copy lengths share a command code; its cost is counted
+	// here, since every match has a copy length.
+	const commandCost = 3
+	code := getCopyLengthCode(uint(length))
+	cost := commandCost + float32(kCopyExtra[code]) + float32(bits.Len16(code))/2
+	if length < 6 {
+		// Matches shorter than 6 are comparatively rare, and therefore
+		// have longer codes.
+		cost += float32(6 - length)
+	}
+	return cost
+}
+
+func (CostModel) DistanceCost(distance int, repeat bool) float32 {
+	if repeat {
+		// The last distance is stored as distance code 0, or implied by the
+		// command code.
+		return 0
+	}
+	const distanceSymbolCost = 2
+	return distanceSymbolCost + float32(getDistanceCode(distance).nExtra)
+}
@@ -11,6 +11,10 @@ const (
 	decoderResultSuccess         = 1
 	decoderResultNeedsMoreInput  = 2
 	decoderResultNeedsMoreOutput = 3
+
+	// The decoder stopped at a point where a checkpoint can be taken,
+	// because s.checkpointRequested was set.
+	decoderResultReachedCheckpoint = 4
 )
 
 /**
@@ -23,6 +27,7 @@ const (
 	decoderSuccess                          = 1
 	decoderNeedsMoreInput                   = 2
 	decoderNeedsMoreOutput                  = 3
+	decoderReachedCheckpoint                = 4
 	decoderErrorFormatExuberantNibble       = -1
 	decoderErrorFormatReserved              = -2
 	decoderErrorFormatExuberantMetaNibble   = -3
@@ -79,6 +84,9 @@ func saveErrorCode(s *Reader, e int) int {
 	case decoderNeedsMoreOutput:
 		return decoderResultNeedsMoreOutput
 
+	case decoderReachedCheckpoint:
+		return decoderResultReachedCheckpoint
+
 	default:
 		return decoderResultError
 	}
@@ -1661,6 +1669,12 @@ CommandBegin:
 		goto saveStateAndReturn
 	}
 
+	if s.checkpointRequested && s.buffer_length == 0 {
+		s.state = stateCommandBegin
+		result = decoderReachedCheckpoint
+		goto saveStateAndReturn
+	}
+
 	if s.block_length[1] == 0 {
 		if safe != 0 {
 			if !safeDecodeCommandBlockSwitch(s) {
@@ -1918,6 +1932,10 @@ CommandPostDecodeLiterals:
 		s.dist_rb_idx++
 		s.meta_block_remaining_len -= i
 
+		if s.recordCopies {
+			s.copies = append(s.copies, decodedCopy{s.rb_roundtrips*uint(s.ringbuffer_size) + uint(pos), i, s.distance_code})
+		}
+
 		/* There are 32+ bytes of slack in the ring-buffer allocation.
 		   Also, we have 16 short codes, that make these 16 bytes irrelevant
 		   in the ring-buffer. Let's copy over them as a first guess. */
@@ -2187,6 +2205,11 @@ func decoderDecompressStream(s *Reader, available_in *uint, next_in *[]byte, ava
 
 			/* Fall through. */
 		case stateMetablockBegin:
+			if s.checkpointRequested && s.buffer_length == 0 {
+				result = decoderReachedCheckpoint
+				break
+			}
+
 			decoderStateMetablockBegin(s)
 
 			s.state = stateMetablockHeader
@@ -2208,6 +2231,11 @@ func decoderDecompressStream(s *Reader, available_in *uint, next_in *[]byte, ava
 			}
 
 			if s.is_metadata != 0 {
+				s.keepMetadata = s.saveMetadata && s.metadata == nil && s.ringbuffer_size == 0 &&
+					s.meta_block_remaining_len >= len(filterMagic) && s.meta_block_remaining_len <= maxSavedMetadata
+				if s.keepMetadata {
+					s.metadata = make([]byte, 0, s.meta_block_remaining_len)
+				}
 				s.state = stateMetadata
 				break
 			}
@@ -2236,11 +2264,20 @@ func decoderDecompressStream(s *Reader, available_in *uint, next_in *[]byte, ava
 			for ; s.meta_block_remaining_len > 0; s.meta_block_remaining_len-- {
 				var bits uint32
 
-				/* Read one byte and ignore it. */
+				/* Read one byte, and save it or ignore it. */
 				if !safeReadBits(br, 8, &bits) {
 					result = decoderNeedsMoreInput
 					break
 				}
+
+				if s.keepMetadata {
+					s.metadata = append(s.metadata, byte(bits))
+					if n := len(s.metadata); n <= len(filterMagic) && s.metadata[n-1] != filterMagic[n-1] {
+						// It isn't a filter's metadata block.
+						s.keepMetadata = false
+						s.metadata = nil
+					}
+				}
 			}
 
 			if result == decoderSuccess {
@@ -2514,6 +2551,8 @@ func decoderErrorString(c int) string {
 		return "NEEDS_MORE_INPUT"
 	case decoderNeedsMoreOutput:
 		return "NEEDS_MORE_OUTPUT"
+	case decoderReachedCheckpoint:
+		return "REACHED_CHECKPOINT"
 	case decoderErrorFormatExuberantNibble:
 		return "EXUBERANT_NIBBLE"
 	case decoderErrorFormatReserved:
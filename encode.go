@@ -1,8 +1,12 @@
 package brotli
 
 import (
+	"context"
 	"io"
 	"math"
+	"time"
+
+	"github.com/andybalholm/brotli/matchfinder"
 )
 
 /* Copyright 2016 Google Inc. All Rights Reserved.
@@ -74,7 +78,9 @@ const (
 type Writer struct {
 	dst     io.Writer
 	options WriterOptions
+	ctx     context.Context
 	err     error
+	stats   matchfinder.Stats
 
 	params              encoderParams
 	hasher_             hasherHandle
@@ -345,8 +351,8 @@ func shouldUseComplexStaticContextMap(input []byte, start_pos uint, length uint,
 		}
 
 		entropy[0] = 1.0 / float64(total)
-		entropy[1] *= entropy[0]
-		entropy[2] *= entropy[0]
+		entropy[1] = float64(entropy[1] * entropy[0])
+		entropy[2] = float64(entropy[2] * entropy[0])
 
 		/* The triggering heuristics below were tuned by compressing the individual
 		   files of the silesia corpus. If we skip this kind of context modeling
@@ -763,6 +769,9 @@ func encodeData(s *Writer, is_last bool, force_flush bool) bool {
 	if s.is_last_block_emitted_ {
 		return false
 	}
+	if s.checkCanceled() {
+		return false
+	}
 	if is_last {
 		s.is_last_block_emitted_ = true
 	}
@@ -797,16 +806,20 @@ func encodeData(s *Writer, is_last bool, force_flush bool) bool {
 		storage[0] = byte(s.last_bytes_)
 		storage[1] = byte(s.last_bytes_ >> 8)
 		table = getHashTable(s, s.params.quality, uint(bytes), &table_size)
+		start := time.Now()
 		if s.params.quality == fastOnePassCompressionQuality {
 			compressFragmentFast(data[wrapped_last_processed_pos&mask:], uint(bytes), is_last, table, table_size, s.cmd_depths_[:], s.cmd_bits_[:], &s.cmd_code_numbits_, s.cmd_code_[:], &storage_ix, storage)
 		} else {
 			compressFragmentTwoPass(data[wrapped_last_processed_pos&mask:], uint(bytes), is_last, s.command_buf_, s.literal_buf_, table, table_size, &storage_ix, storage)
 		}
+		s.stats.EncodingTime += time.Since(start)
+		s.stats.Blocks++
 
 		s.last_bytes_ = uint16(storage[storage_ix>>3])
 		s.last_bytes_bits_ = byte(storage_ix & 7)
 		updateLastProcessedPos(s)
 		s.writeOutput(storage[:storage_ix>>3])
+		s.reportProgress(int64(s.last_processed_pos_))
 		return true
 	}
 	{
@@ -834,6 +847,7 @@ func encodeData(s *Writer, is_last bool, force_flush bool) bool {
 		extendLastCommand(s, &bytes, &wrapped_last_processed_pos)
 	}
 
+	matchFindingStart := time.Now()
 	if s.params.quality == zopflificationQuality {
 		assert(s.params.hasher.type_ == 10)
 		createZopfliBackwardReferences(uint(bytes), uint(wrapped_last_processed_pos), data, uint(mask), &s.params, s.hasher_.(*h10), s.dist_cache_[:], &s.last_insert_len_, &s.commands, &s.num_literals_)
@@ -843,6 +857,11 @@ func encodeData(s *Writer, is_last bool, force_flush bool) bool {
 	} else {
 		createBackwardReferences(uint(bytes), uint(wrapped_last_processed_pos), data, uint(mask), &s.params, s.hasher_, s.dist_cache_[:], &s.last_insert_len_, &s.commands, &s.num_literals_)
 	}
+	s.stats.MatchFindingTime += time.Since(matchFindingStart)
+	if s.checkCanceled() {
+		/* The match finder may have stopped early. */
+		return false
+	}
 	{
 		var max_length uint = maxMetablockSize(&s.params)
 		var max_literals uint = max_length / 8
@@ -887,7 +906,15 @@ func encodeData(s *Writer, is_last bool, force_flush bool) bool {
 		var storage_ix uint = uint(s.last_bytes_bits_)
 		storage[0] = byte(s.last_bytes_)
 		storage[1] = byte(s.last_bytes_ >> 8)
+		s.recordCommands()
+		start := time.Now()
 		writeMetaBlockInternal(data, uint(mask), s.last_flush_pos_, uint(metablock_size), is_last, literal_context_mode, &s.params, s.prev_byte_, s.prev_byte2_, s.num_literals_, s.commands, s.saved_dist_cache_[:], s.dist_cache_[:], &storage_ix, storage)
+		s.stats.EncodingTime += time.Since(start)
+		if s.checkCanceled() {
+			/* The block splitter may have stopped early. */
+			return false
+		}
+		s.stats.Blocks++
 		s.last_bytes_ = uint16(storage[storage_ix>>3])
 		s.last_bytes_bits_ = byte(storage_ix & 7)
 		s.last_flush_pos_ = s.input_pos_
@@ -911,6 +938,7 @@ func encodeData(s *Writer, is_last bool, force_flush bool) bool {
 		copy(s.saved_dist_cache_[:], s.dist_cache_[:])
 
 		s.writeOutput(storage[:storage_ix>>3])
+		s.reportProgress(int64(s.last_flush_pos_))
 		return true
 	}
 }
@@ -978,6 +1006,7 @@ func checkFlushComplete(s *Writer) {
 func encoderCompressStreamFast(s *Writer, op int, available_in *uint, next_in *[]byte) bool {
 	var block_size_limit uint = uint(1) << s.params.lgwin
 	var buf_size uint = brotli_min_size_t(kCompressFragmentTwoPassBlockSize, brotli_min_size_t(*available_in, block_size_limit))
+	var initial_available_in uint = *available_in
 	var command_buf []uint32 = nil
 	var literal_buf []byte = nil
 	if s.params.quality != fastOnePassCompressionQuality && s.params.quality != fastTwoPassCompressionQuality {
@@ -1021,17 +1050,23 @@ func encoderCompressStreamFast(s *Writer, op int, available_in *uint, next_in *[
 				continue
 			}
 
+			if s.checkCanceled() {
+				return false
+			}
 			storage = s.getStorage(int(max_out_size))
 
 			storage[0] = byte(s.last_bytes_)
 			storage[1] = byte(s.last_bytes_ >> 8)
 			table = getHashTable(s, s.params.quality, block_size, &table_size)
 
+			start := time.Now()
 			if s.params.quality == fastOnePassCompressionQuality {
 				compressFragmentFast(*next_in, block_size, is_last, table, table_size, s.cmd_depths_[:], s.cmd_bits_[:], &s.cmd_code_numbits_, s.cmd_code_[:], &storage_ix, storage)
 			} else {
 				compressFragmentTwoPass(*next_in, block_size, is_last, command_buf, literal_buf, table, table_size, &storage_ix, storage)
 			}
+			s.stats.EncodingTime += time.Since(start)
+			s.stats.Blocks++
 
 			*next_in = (*next_in)[block_size:]
 			*available_in -= block_size
@@ -1040,6 +1075,7 @@ func encoderCompressStreamFast(s *Writer, op int, available_in *uint, next_in *[
 
 			s.last_bytes_ = uint16(storage[storage_ix>>3])
 			s.last_bytes_bits_ = byte(storage_ix & 7)
+			s.reportProgress(s.stats.BytesIn + int64(initial_available_in-*available_in))
 
 			if force_flush {
 				s.stream_state_ = streamFlushRequested
@@ -1213,7 +1249,9 @@ func (w *Writer) writeOutput(data []byte) {
 		return
 	}
 
-	_, w.err = w.dst.Write(data)
+	var n int
+	n, w.err = w.dst.Write(data)
+	w.stats.BytesOut += int64(n)
 	if w.err == nil {
 		checkFlushComplete(w)
 	}
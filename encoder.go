@@ -1,12 +1,34 @@
 package brotli
 
-import "github.com/andybalholm/brotli/matchfinder"
+import (
+	"math"
+	"math/bits"
+
+	"github.com/andybalholm/brotli/matchfinder"
+)
 
 // An Encoder implements the matchfinder.Encoder interface, writing in Brotli format.
 type Encoder struct {
+	// WindowBits is the base-2 logarithm of the window size declared in the
+	// stream header; matches must not have distances longer than
+	// (1 << WindowBits) - 16. If it is zero, 24 is used.
+	//
+	// Values from 25 to 30 produce a "Large Window Brotli" stream, which is
+	// not part of RFC 7932. Decoding it requires a Reader with LargeWindow
+	// set in its ReaderOptions.
+	WindowBits int
+
+	// Filter, if it is not nil, is recorded in a metadata block at the
+	// start of the stream, so that FilterReader can reverse it. It should be
+	// the same as the Filter of the matchfinder.Writer. Filters from
+	// outside the matchfinder package are recorded as unknown, so that
+	// FilterReader returns an error instead of the filtered data.
+	Filter matchfinder.Filter
+
 	wroteHeader bool
 	bw          bitWriter
 	distCache   []distanceCode
+	distances   []int
 }
 
 func (e *Encoder) Reset() {
@@ -14,10 +36,30 @@ func (e *Encoder) Reset() {
 	e.bw = bitWriter{}
 }
 
+// MarshalBinary saves the Encoder's position in the stream (but not its
+// settings), so that a matchfinder.Writer can make a checkpoint.
+func (e *Encoder) MarshalBinary() ([]byte, error) {
+	return appendEncoderState(nil, e.wroteHeader, &e.bw), nil
+}
+
+func (e *Encoder) UnmarshalBinary(data []byte) error {
+	rest, err := parseEncoderState(data, &e.wroteHeader, &e.bw)
+	if err == nil && len(rest) > 0 {
+		err = errInvalidCheckpoint
+	}
+	return err
+}
+
 func (e *Encoder) Encode(dst []byte, src []byte, matches []matchfinder.Match, lastBlock bool) []byte {
 	e.bw.dst = dst
 	if !e.wroteHeader {
-		e.bw.writeBits(4, 15)
+		var header uint16
+		var headerBits byte
+		encodeWindowBits(e.windowBits(), e.largeWindow(), &header, &headerBits)
+		e.bw.writeBits(uint(headerBits), uint64(header))
+		if e.Filter != nil {
+			storeMetadataBW(filterMetadata(e.Filter), &e.bw)
+		}
 		e.wroteHeader = true
 	}
 
@@ -25,22 +67,30 @@ func (e *Encoder) Encode(dst []byte, src []byte, matches []matchfinder.Match, la
 		if lastBlock {
 			e.bw.writeBits(2, 3) // islast + isempty
 			e.bw.jumpToByteBoundary()
-			return e.bw.dst
 		}
-		return dst
+		return e.bw.dst
 	}
 
 	var literalHisto [256]uint32
 	var commandHisto [704]uint32
-	var distanceHisto [64]uint32
+	var distanceHisto [numDistanceSymbols]uint32
+	distanceBitsLimit := uint(maxDistanceBits)
+	if e.largeWindow() {
+		distanceBitsLimit = largeMaxDistanceBits
+	}
 	literalCount := 0
 	commandCount := 0
 	distanceCount := 0
+	extraBits := 0
 
 	if len(e.distCache) < len(matches) {
 		e.distCache = make([]distanceCode, len(matches))
 	}
 
+	// distances holds the distances that don't use the distance cache
+	// (the ones that will be affected by the choice of NPOSTFIX and NDIRECT).
+	distances := e.distances[:0]
+
 	// first pass: build the histograms
 	pos := 0
 
@@ -63,6 +113,7 @@ func (e *Encoder) Encode(dst []byte, src []byte, matches []matchfinder.Match, la
 		command := combineLengthCodes(insertCode, copyCode, i > 0 && m.Distance == matches[i-1].Distance)
 		commandHisto[command]++
 		commandCount++
+		extraBits += int(kInsExtra[insertCode]) + int(kCopyExtra[copyCode])
 
 		if command >= 128 && m.Length != 0 {
 			var distCode distanceCode
@@ -92,20 +143,61 @@ func (e *Encoder) Encode(dst []byte, src []byte, matches []matchfinder.Match, la
 
 			default:
 				distCode = getDistanceCode(m.Distance)
+				distances = append(distances, m.Distance)
 			}
 			e.distCache[i] = distCode
 			distanceHisto[distCode.code]++
 			distanceCount++
+			extraBits += int(distCode.nExtra)
 			if distCode.code != 0 {
 				d[0], d[1], d[2], d[3] = d[1], d[2], d[3], m.Distance
 			}
+		} else {
+			e.distCache[i] = distanceCode{code: -1}
 		}
 
 		pos += m.Unmatched + m.Length
 	}
+	e.distances = distances
+
+	// Choose the distance parameters, and recalculate the distance codes
+	// if they aren't the default.
+	npostfix, ndirect := optimizeDistanceParams(distances, distanceHisto[:numDistanceShortCodes], distanceBitsLimit)
+	if npostfix != 0 || ndirect != 0 {
+		clear(distanceHisto[numDistanceShortCodes:])
+		for i := range matches {
+			distCode := e.distCache[i]
+			if distCode.code < numDistanceShortCodes {
+				continue
+			}
+			extraBits -= int(distCode.nExtra)
+			distCode = getDistanceCodeWithParams(matches[i].Distance, npostfix, ndirect)
+			e.distCache[i] = distCode
+			distanceHisto[distCode.code]++
+			extraBits += int(distCode.nExtra)
+		}
+	}
+	distanceAlphabetSize := distanceAlphabetSize(npostfix, ndirect, distanceBitsLimit)
+	distanceAlphabetBits := uint(bits.Len(distanceAlphabetSize - 1))
+
+	// If compressing the block would make it bigger (as with random data or
+	// data that is already compressed), store it uncompressed instead.
+	estimate := estimateHuffmanBits(literalHisto[:]) + estimateHuffmanBits(commandHisto[:]) +
+		estimateHuffmanBits(distanceHisto[:distanceAlphabetSize]) + float64(extraBits)
+	if estimate >= float64(len(src))*8 {
+		storeUncompressedMetaBlockBW(src, &e.bw)
+		if lastBlock {
+			e.bw.writeBits(2, 3) // islast + isempty
+			e.bw.jumpToByteBoundary()
+		}
+		return e.bw.dst
+	}
 
 	storeMetaBlockHeaderBW(uint(len(src)), false, &e.bw)
-	e.bw.writeBits(13, 0)
+	e.bw.writeBits(3, 0) // one block type each for literals, commands, and distances
+	e.bw.writeBits(2, uint64(npostfix))
+	e.bw.writeBits(4, uint64(ndirect>>npostfix))
+	e.bw.writeBits(4, 0) // literal context mode, and one tree each for literals and distances
 
 	var literalDepths [256]byte
 	var literalBits [256]uint16
@@ -115,9 +207,9 @@ func (e *Encoder) Encode(dst []byte, src []byte, matches []matchfinder.Match, la
 	var commandBits [704]uint16
 	buildAndStoreHuffmanTreeFastBW(commandHisto[:], uint(commandCount), 10, commandDepths[:], commandBits[:], &e.bw)
 
-	var distanceDepths [64]byte
-	var distanceBits [64]uint16
-	buildAndStoreHuffmanTreeFastBW(distanceHisto[:], uint(distanceCount), 6, distanceDepths[:], distanceBits[:], &e.bw)
+	var distanceDepths [numDistanceSymbols]byte
+	var distanceBits [numDistanceSymbols]uint16
+	buildAndStoreHuffmanTreeFastBW(distanceHisto[:distanceAlphabetSize], uint(distanceCount), distanceAlphabetBits, distanceDepths[:], distanceBits[:], &e.bw)
 
 	pos = 0
 	for i, m := range matches {
@@ -160,6 +252,17 @@ func (e *Encoder) Encode(dst []byte, src []byte, matches []matchfinder.Match, la
 	return e.bw.dst
 }
 
+func (e *Encoder) windowBits() int {
+	if e.WindowBits == 0 {
+		return maxWindowBits
+	}
+	return e.WindowBits
+}
+
+func (e *Encoder) largeWindow() bool {
+	return e.windowBits() > maxWindowBits
+}
+
 type distanceCode struct {
 	code      int
 	nExtra    uint
@@ -175,3 +278,85 @@ func getDistanceCode(distance int) distanceCode {
 	extra := d - offset
 	return distanceCode{distcode, uint(nbits), uint64(extra)}
 }
+
+// getDistanceCodeWithParams is like getDistanceCode, but with npostfix
+// postfix bits and ndirect direct distance codes.
+func getDistanceCodeWithParams(distance int, npostfix, ndirect uint) distanceCode {
+	var code uint16
+	var extra uint32
+	prefixEncodeCopyDistance(uint(distance)+numDistanceShortCodes-1, ndirect, npostfix, &code, &extra)
+	return distanceCode{int(code & 0x3ff), uint(code >> 10), uint64(extra)}
+}
+
+// optimizeDistanceParams chooses the number of postfix bits (NPOSTFIX) and
+// direct distance codes (NDIRECT) that minimize the estimated size of
+// distances, using the same search as buildMetaBlock. shortCodes is the
+// histogram of the distance codes that use the distance cache.
+func optimizeDistanceParams(distances []int, shortCodes []uint32, distanceBitsLimit uint) (npostfix, ndirect uint) {
+	if len(distances) == 0 {
+		return 0, 0
+	}
+
+	// To keep the search fast, use a sample of the distances if there are
+	// a lot of them.
+	const maxSamples = 1024
+	step := (len(distances) + maxSamples - 1) / maxSamples
+	var scaledShortCodes [numDistanceShortCodes]uint32
+	for i, n := range shortCodes {
+		scaledShortCodes[i] = (n + uint32(step) - 1) / uint32(step)
+	}
+
+	var histogram [numDistanceSymbols]uint32
+	cost := func(npostfix, ndirect uint) float64 {
+		clear(histogram[:])
+		copy(histogram[:], scaledShortCodes[:])
+		extraBits := 0
+		for i := 0; i < len(distances); i += step {
+			d := distances[i]
+			c := getDistanceCodeWithParams(d, npostfix, ndirect)
+			histogram[c.code]++
+			extraBits += int(c.nExtra)
+		}
+		return estimateHuffmanBits(histogram[:distanceAlphabetSize(npostfix, ndirect, distanceBitsLimit)]) + float64(extraBits)
+	}
+
+	bestCost := math.Inf(1)
+	var ndirectMSB uint
+	for p := uint(0); p <= maxNpostfix; p++ {
+		for ; ndirectMSB < 16; ndirectMSB++ {
+			c := cost(p, ndirectMSB<<p)
+			if c > bestCost {
+				break
+			}
+			bestCost = c
+			npostfix, ndirect = p, ndirectMSB<<p
+		}
+		if ndirectMSB > 0 {
+			ndirectMSB--
+		}
+		ndirectMSB /= 2
+	}
+	return npostfix, ndirect
+}
+
+// estimateHuffmanBits estimates how many bits it will take to store the
+// symbols counted in histogram with a Huffman code, including the code itself.
+func estimateHuffmanBits(histogram []uint32) float64 {
+	// Storing the code takes about 4 bits for each symbol that is used.
+	const bitsPerCodeLength = 4
+
+	used := 0
+	for _, n := range histogram {
+		if n != 0 {
+			used++
+		}
+	}
+	return bitsEntropy(histogram, uint(len(histogram))) + float64(used*bitsPerCodeLength)
+}
+
+// storeUncompressedMetaBlockBW writes src as an uncompressed meta-block.
+func storeUncompressedMetaBlockBW(src []byte, bw *bitWriter) {
+	storeMetaBlockHeaderBW(uint(len(src)), true, bw)
+	bw.jumpToByteBoundary()
+	bw.dst = append(bw.dst, src...)
+}
@@ -1,6 +1,7 @@
 package brotli
 
 import (
+	"encoding/binary"
 	"math"
 
 	"github.com/andybalholm/brotli/matchfinder"
@@ -13,11 +14,28 @@ func gaussianProbability(x, mean, stdDev float64) float64 {
 // A FastEncoder implements the matchfinder.Encoder interface, writing in Brotli
 // format. It uses a simplified encoding (like level 0 in the reference
 // implementation) to save time.
+//
+// Instead of counting the commands and distances in each block before writing
+// it, it builds its Huffman codes from the statistics of the previous blocks
+// (with older blocks given progressively less weight). Blocks that look
+// incompressible are stored uncompressed. Matches shorter than 4 bytes are
+// stored as literals.
 type FastEncoder struct {
+	// WindowBits is the base-2 logarithm of the window size declared in the
+	// stream header; matches must not have distances longer than
+	// (1 << WindowBits) - 16. It must be between 10 and 24. If it is zero,
+	// 24 is used.
+	WindowBits int
+
+	// Filter, if it is not nil, is recorded in a metadata block at the
+	// start of the stream, as in Encoder.
+	Filter matchfinder.Filter
+
 	wroteHeader   bool
 	bw            bitWriter
 	commandHisto  [704]uint32
 	distanceHisto [64]uint32
+	matches       []matchfinder.Match
 }
 
 func (e *FastEncoder) Reset() {
@@ -25,10 +43,55 @@ func (e *FastEncoder) Reset() {
 	e.bw = bitWriter{}
 }
 
+// MarshalBinary saves the FastEncoder's position in the stream and the
+// statistics it has collected (but not its settings), so that a
+// matchfinder.Writer can make a checkpoint.
+func (e *FastEncoder) MarshalBinary() ([]byte, error) {
+	b := appendEncoderState(nil, e.wroteHeader, &e.bw)
+	for _, n := range e.commandHisto {
+		b = binary.AppendUvarint(b, uint64(n))
+	}
+	for _, n := range e.distanceHisto {
+		b = binary.AppendUvarint(b, uint64(n))
+	}
+	return b, nil
+}
+
+func (e *FastEncoder) UnmarshalBinary(data []byte) error {
+	rest, err := parseEncoderState(data, &e.wroteHeader, &e.bw)
+	if err != nil {
+		return err
+	}
+	for _, histo := range [][]uint32{e.commandHisto[:], e.distanceHisto[:]} {
+		for i := range histo {
+			v, n := binary.Uvarint(rest)
+			if n <= 0 || v > math.MaxUint32 {
+				return errInvalidCheckpoint
+			}
+			histo[i] = uint32(v)
+			rest = rest[n:]
+		}
+	}
+	if len(rest) > 0 {
+		return errInvalidCheckpoint
+	}
+	return nil
+}
+
 func (e *FastEncoder) Encode(dst []byte, src []byte, matches []matchfinder.Match, lastBlock bool) []byte {
 	e.bw.dst = dst
 	if !e.wroteHeader {
-		e.bw.writeBits(4, 15)
+		windowBits := e.WindowBits
+		if windowBits == 0 {
+			windowBits = maxWindowBits
+		}
+		var header uint16
+		var headerBits byte
+		encodeWindowBits(windowBits, false, &header, &headerBits)
+		e.bw.writeBits(uint(headerBits), uint64(header))
+		if e.Filter != nil {
+			storeMetadataBW(filterMetadata(e.Filter), &e.bw)
+		}
 		e.wroteHeader = true
 
 		// Fill the histograms with default statistics.
@@ -68,9 +131,8 @@ func (e *FastEncoder) Encode(dst []byte, src []byte, matches []matchfinder.Match
 		if lastBlock {
 			e.bw.writeBits(2, 3) // islast + isempty
 			e.bw.jumpToByteBoundary()
-			return e.bw.dst
 		}
-		return dst
+		return e.bw.dst
 	}
 
 	var literalHisto [256]uint32
@@ -78,6 +140,24 @@ func (e *FastEncoder) Encode(dst []byte, src []byte, matches []matchfinder.Match
 		literalHisto[c]++
 	}
 
+	// The command codes that FastEncoder uses don't cover copy lengths
+	// under 4, so turn short matches into literals.
+	for i, m := range matches {
+		if m.Length > 0 && m.Length < 4 {
+			matches = e.removeShortMatches(matches[:i], matches[i:])
+			break
+		}
+	}
+
+	if shouldStoreUncompressed(src, matches, literalHisto[:]) {
+		storeUncompressedMetaBlockBW(src, &e.bw)
+		if lastBlock {
+			e.bw.writeBits(2, 3) // islast + isempty
+			e.bw.jumpToByteBoundary()
+		}
+		return e.bw.dst
+	}
+
 	storeMetaBlockHeaderBW(uint(len(src)), false, &e.bw)
 	e.bw.writeBits(13, 0)
 
@@ -101,21 +181,14 @@ func (e *FastEncoder) Encode(dst []byte, src []byte, matches []matchfinder.Match
 	}
 	buildAndStoreHuffmanTreeFastBW(e.distanceHisto[:], uint(distanceCount), 6, distanceDepths[:], distanceBits[:], &e.bw)
 
-	// Reset the statistics, starting with a count of 1 for each symbol we might use.
-	for i := range 24 {
-		e.commandHisto[combineLengthCodes(uint16(i), 0, false)] = 1
-	}
-	for i := range 24 {
-		e.commandHisto[combineLengthCodes(0, uint16(i), i < 16)] = 1
-	}
-	for insertCode := range 6 {
-		for copyCode := 2; copyCode < 8; copyCode++ {
-			e.commandHisto[128+insertCode<<3+copyCode] = 1
-		}
+	// Decay the statistics, so that the codes for the next block are based
+	// mostly on recent blocks. Symbols that have been used keep a count of
+	// at least 1, so that they will still have codes.
+	for i, n := range e.commandHisto {
+		e.commandHisto[i] = (n + 3) >> 2
 	}
-	e.distanceHisto[0] = 1
-	for i := 16; i < 64; i++ {
-		e.distanceHisto[i] = 1
+	for i, n := range e.distanceHisto {
+		e.distanceHisto[i] = (n + 3) >> 2
 	}
 
 	pos := 0
@@ -195,3 +268,40 @@ func (e *FastEncoder) Encode(dst []byte, src []byte, matches []matchfinder.Match
 	}
 	return e.bw.dst
 }
+
+// shouldStoreUncompressed reports whether src would probably take less space
+// as an uncompressed meta-block than as a compressed one: there are hardly any
+// matches, and the byte frequencies are close to uniform.
+func shouldStoreUncompressed(src []byte, matches []matchfinder.Match, literalHisto []uint32) bool {
+	const minEntropy = 7.92
+
+	matched := 0
+	for _, m := range matches {
+		matched += m.Length
+	}
+	if matched > len(src)/100 {
+		return false
+	}
+	return bitsEntropy(literalHisto, 256) > float64(len(src))*minEntropy
+}
+
+// removeShortMatches returns done followed by rest, with the matches in
+// rest that are shorter than 4 bytes merged into the following literals.
+func (e *FastEncoder) removeShortMatches(done, rest []matchfinder.Match) []matchfinder.Match {
+	out := append(e.matches[:0], done...)
+	unmatched := 0
+	for _, m := range rest {
+		m.Unmatched += unmatched
+		unmatched = 0
+		if m.Length > 0 && m.Length < 4 {
+			unmatched = m.Unmatched + m.Length
+			continue
+		}
+		out = append(out, m)
+	}
+	if unmatched > 0 {
+		out = append(out, matchfinder.Match{Unmatched: unmatched})
+	}
+	e.matches = out
+	return out
+}
@@ -1,8 +1,9 @@
 package brotli
 
 import (
-	"math"
 	"math/bits"
+
+	"github.com/andybalholm/brotli/matchfinder"
 )
 
 /* Copyright 2013 Google Inc. All Rights Reserved.
@@ -280,11 +281,13 @@ var kLog2Table = []float32{
 	7.9943534368588578,
 }
 
-/* Faster logarithm for small integers, with the property of log2(0) == 0. */
+/* Faster logarithm for small integers, with the property of log2(0) == 0.
+   Larger values use matchfinder.Log2 instead of math.Log2, so that the
+   result is the same on every platform. */
 func fastLog2(v uint) float64 {
 	if v < uint(len(kLog2Table)) {
 		return float64(kLog2Table[v])
 	}
 
-	return math.Log2(float64(v))
+	return matchfinder.Log2(v)
 }
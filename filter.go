@@ -0,0 +1,174 @@
+package brotli
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/bits"
+
+	"github.com/andybalholm/brotli/matchfinder"
+)
+
+// filterMagic starts the metadata block that records which
+// matchfinder.Filter was applied to the data. It is followed by a byte
+// identifying the filter, and the filter's parameter as a uvarint.
+const filterMagic = "BRFLT"
+
+const (
+	filterDelta  = 1
+	filterStride = 2
+	filterX86    = 3
+
+	// filterCustom marks a Filter from outside the matchfinder package,
+	// which a FilterReader can't reverse.
+	filterCustom = 0xff
+)
+
+// maxFilterParam is the largest Distance or Width that a FilterReader
+// accepts.
+const maxFilterParam = 1 << 16
+
+var errUnknownFilter = errors.New("brotli: unknown filter in stream")
+
+// filterMetadata returns the contents of the metadata block that records
+// f. Filters from outside the matchfinder package are recorded as
+// filterCustom.
+func filterMetadata(f matchfinder.Filter) []byte {
+	b := []byte(filterMagic)
+	switch f := f.(type) {
+	case *matchfinder.DeltaFilter:
+		b = append(b, filterDelta)
+		b = binary.AppendUvarint(b, uint64(max(f.Distance, 0)))
+	case *matchfinder.StrideFilter:
+		b = append(b, filterStride)
+		b = binary.AppendUvarint(b, uint64(max(f.Width, 0)))
+	case *matchfinder.X86Filter:
+		b = append(b, filterX86)
+		b = binary.AppendUvarint(b, 0)
+	default:
+		b = append(b, filterCustom)
+		b = binary.AppendUvarint(b, 0)
+	}
+	return b
+}
+
+// parseFilterMetadata parses the metadata block that records a stream's
+// filter. If there isn't one (metadata is nil), it returns nil.
+func parseFilterMetadata(metadata []byte) (matchfinder.Filter, error) {
+	if metadata == nil {
+		return nil, nil
+	}
+	b, ok := bytes.CutPrefix(metadata, []byte(filterMagic))
+	if !ok || len(b) < 2 {
+		return nil, errUnknownFilter
+	}
+	id := b[0]
+	param, n := binary.Uvarint(b[1:])
+	if n <= 0 || n != len(b)-1 || param > maxFilterParam {
+		return nil, errUnknownFilter
+	}
+	switch id {
+	case filterDelta:
+		return &matchfinder.DeltaFilter{Distance: int(param)}, nil
+	case filterStride:
+		return &matchfinder.StrideFilter{Width: int(param)}, nil
+	case filterX86:
+		return &matchfinder.X86Filter{}, nil
+	}
+	return nil, errUnknownFilter
+}
+
+// storeMetadataBW writes a metadata meta-block containing data, which must
+// be no more than 16 MB.
+func storeMetadataBW(data []byte, bw *bitWriter) {
+	bw.writeBits(1, 0) // ISLAST
+	bw.writeBits(2, 3) // MNIBBLES: metadata
+	bw.writeBits(1, 0) // reserved
+	if len(data) == 0 {
+		bw.writeBits(2, 0)
+	} else {
+		skipLen := uint64(len(data) - 1)
+		skipBytes := max((bits.Len64(skipLen)+7)/8, 1)
+		bw.writeBits(2, uint64(skipBytes))
+		bw.writeBits(uint(skipBytes*8), skipLen)
+	}
+	bw.jumpToByteBoundary()
+	bw.dst = append(bw.dst, data...)
+}
+
+// A FilterReader decompresses a stream written with a Filter (as set in
+// WriterV2Options), and reverses the filter. Streams without a filter are
+// decompressed normally. A plain Reader can decompress a filtered stream
+// too, but it returns the filtered data.
+type FilterReader struct {
+	r      *Reader
+	filter matchfinder.Filter
+
+	// started is set when the filter has been looked for.
+	started bool
+
+	buf    []byte // data from r
+	outBuf []byte // scratch space for the filter's output
+	out    []byte // data waiting to be returned by Read
+	err    error
+}
+
+// NewFilterReader returns a FilterReader that reads the decompressed data
+// from r and reverses the filter. Nothing must have been read from r yet.
+func NewFilterReader(r *Reader) *FilterReader {
+	r.saveMetadata = true
+	return &FilterReader{r: r}
+}
+
+// Reset discards the FilterReader's state, and resets its Reader to read
+// from src.
+func (f *FilterReader) Reset(src io.Reader) error {
+	f.r.Reset(src)
+	f.filter = nil
+	f.started = false
+	f.out = nil
+	f.err = nil
+	return nil
+}
+
+func (f *FilterReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	for len(f.out) == 0 {
+		if f.err != nil {
+			return 0, f.err
+		}
+		f.fill()
+	}
+	n := copy(p, f.out)
+	f.out = f.out[n:]
+	return n, nil
+}
+
+// fill reads from the Reader, and puts the unfiltered data in f.out.
+func (f *FilterReader) fill() {
+	if f.buf == nil {
+		f.buf = make([]byte, readBufSize)
+	}
+	n, err := f.r.Read(f.buf)
+
+	if !f.started && (n > 0 || err != nil) {
+		// The metadata block with the filter comes before any data.
+		f.started = true
+		filter, ferr := parseFilterMetadata(f.r.metadata)
+		if ferr != nil {
+			f.err = ferr
+			return
+		}
+		f.filter = filter
+	}
+
+	f.out = f.buf[:n]
+	if f.filter != nil && (n > 0 || err == io.EOF) {
+		f.outBuf = f.filter.Decode(f.outBuf[:0], f.out, err == io.EOF)
+		f.out = f.outBuf
+	}
+	f.err = err
+}
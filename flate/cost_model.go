@@ -0,0 +1,46 @@
+package flate
+
+import "github.com/andybalholm/brotli/matchfinder"
+
+// CostModel is a matchfinder.CostModel for the flate Encoder. It estimates
+// the costs of lengths and offsets from the extra bits of their codes.
+type CostModel struct{}
+
+const (
+	// lengthSymbolCost and offsetSymbolCost are the estimated costs of the
+	// Huffman codes for length and offset symbols.
+	lengthSymbolCost = 2
+	offsetSymbolCost = 3
+)
+
+func (CostModel) LiteralCosts(costs *[256]float32, src []byte) {
+	matchfinder.LiteralCosts(costs, src)
+}
+
+// InsertCost returns 0, since flate doesn't encode the lengths of runs of
+// literals.
+func (CostModel) InsertCost(n int) float32 {
+	return 0
+}
+
+func (c CostModel) LengthCost(length int) float32 {
+	if length > maxMatchLength {
+		// Longer matches can't be encoded; count them as if they were split
+		// into pieces.
+		return c.LengthCost(maxMatchLength) + offsetSymbolCost + c.LengthCost(max(length-maxMatchLength, baseMatchLength))
+	}
+	cost := lengthSymbolCost + float32(lengthExtraBits[lengthCode(length)])
+	if length < 6 {
+		// Matches shorter than 6 are comparatively rare, and therefore
+		// have longer codes.
+		cost += float32(6 - length)
+	}
+	return cost
+}
+
+// DistanceCost returns the cost of the offset of a match. Repeated
+// distances cost the same as others, since flate has no special code for
+// them.
+func (CostModel) DistanceCost(distance int, repeat bool) float32 {
+	return offsetSymbolCost + float32(offsetExtraBits[offsetCode(distance)])
+}
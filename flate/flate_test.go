@@ -4,10 +4,15 @@ import (
 	"bytes"
 	"compress/flate"
 	"compress/gzip"
+	"compress/zlib"
+	"errors"
 	"fmt"
 	"io"
+	"math/rand"
 	"os"
 	"testing"
+	"testing/iotest"
+	"time"
 
 	"github.com/andybalholm/brotli/matchfinder"
 )
@@ -41,6 +46,10 @@ func TestEncodeHuffmanOnly(t *testing.T) {
 	test(t, "../testdata/Isaac.Newton-Opticks.txt", matchfinder.NoMatchFinder{}, 1<<16)
 }
 
+func TestEncodeBargain3CostModel(t *testing.T) {
+	test(t, "../testdata/Isaac.Newton-Opticks.txt", &matchfinder.Bargain3{MaxDistance: 1 << 15, CostModel: CostModel{}}, 1<<16)
+}
+
 func TestWriterLevels(t *testing.T) {
 	data, err := os.ReadFile("../testdata/Isaac.Newton-Opticks.txt")
 	if err != nil {
@@ -90,6 +99,510 @@ func TestGZIPWriterLevels(t *testing.T) {
 	}
 }
 
+func TestWriterLongMatches(t *testing.T) {
+	// Runs of the same byte produce matches longer than flate allows.
+	data := append(make([]byte, 100000), bytes.Repeat([]byte("abc"), 1000)...)
+
+	for i := 1; i < 10; i++ {
+		b := new(bytes.Buffer)
+		w := NewWriter(b, i)
+		w.Write(data)
+		w.Close()
+		sr := flate.NewReader(bytes.NewReader(b.Bytes()))
+		decompressed, err := io.ReadAll(sr)
+		if err != nil {
+			t.Fatalf("error decompressing level %d: %v", i, err)
+		}
+		if !bytes.Equal(decompressed, data) {
+			t.Fatalf("decompressed output doesn't match on level %d", i)
+		}
+	}
+}
+
+func TestGZIPHeader(t *testing.T) {
+	data, err := os.ReadFile("../testdata/Isaac.Newton-Opticks.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := GZIPHeader{
+		Name:      "Opticks.txt",
+		Comment:   "Isaac Newton, 1704 – Project Gutenberg",
+		Extra:     []byte("AB\x04\x00test"),
+		HeaderCRC: true,
+		ModTime:   time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC),
+		OS:        3,
+	}
+	var outputs [][]byte
+	for range 2 {
+		b := new(bytes.Buffer)
+		w, err := NewGZIPWriterHeader(b, 6, h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write(data)
+		w.Close()
+		outputs = append(outputs, b.Bytes())
+	}
+	if !bytes.Equal(outputs[0], outputs[1]) {
+		t.Fatal("output is not deterministic")
+	}
+
+	sr, err := gzip.NewReader(bytes.NewReader(outputs[0]))
+	if err != nil {
+		t.Fatalf("error creating gzip reader: %v", err)
+	}
+	decompressed, err := io.ReadAll(sr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decompressed, data) {
+		t.Fatal("decompressed output doesn't match")
+	}
+	if sr.Name != h.Name {
+		t.Errorf("Name = %q, want %q", sr.Name, h.Name)
+	}
+	if want := "Isaac Newton, 1704 ? Project Gutenberg"; sr.Comment != want {
+		t.Errorf("Comment = %q, want %q", sr.Comment, want)
+	}
+	if !bytes.Equal(sr.Extra, h.Extra) {
+		t.Errorf("Extra = %q, want %q", sr.Extra, h.Extra)
+	}
+	if !sr.ModTime.Equal(h.ModTime) {
+		t.Errorf("ModTime = %v, want %v", sr.ModTime, h.ModTime)
+	}
+	if sr.OS != h.OS {
+		t.Errorf("OS = %d, want %d", sr.OS, h.OS)
+	}
+
+	// The header checksum is checked by our reader too.
+	zr, err := NewGZIPReader(bytes.NewReader(outputs[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !zr.Header.HeaderCRC {
+		t.Error("HeaderCRC = false, want true")
+	}
+	corrupted := bytes.Clone(outputs[0])
+	corrupted[20] ^= 1 // in the Name field
+	if _, err := NewGZIPReader(bytes.NewReader(corrupted)); err != ErrHeader {
+		t.Errorf("reading a header with a bad checksum: got %v, want %v", err, ErrHeader)
+	}
+
+	// With the zero ModTime, the time stamp is left empty.
+	b := new(bytes.Buffer)
+	w, err := NewGZIPWriterHeader(b, 1, GZIPHeader{OS: 255})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(data)
+	w.Close()
+	if mtime := b.Bytes()[4:8]; !bytes.Equal(mtime, []byte{0, 0, 0, 0}) {
+		t.Errorf("MTIME = %v, want 0", mtime)
+	}
+
+	if _, err := NewGZIPWriterHeader(io.Discard, 1, GZIPHeader{Extra: make([]byte, 1<<16)}); err == nil {
+		t.Error("no error for an Extra field longer than 65535 bytes")
+	}
+	if _, err := NewGZIPEncoderHeader(GZIPHeader{ModTime: time.Unix(1<<32, 0)}); err == nil {
+		t.Error("no error for a ModTime after 2106")
+	}
+	if _, err := NewGZIPEncoderHeader(GZIPHeader{ModTime: time.Unix(1<<32-1, 0)}); err != nil {
+		t.Errorf("error for the last ModTime that fits: %v", err)
+	}
+}
+
+func TestZLIBWriterLevels(t *testing.T) {
+	data, err := os.ReadFile("../testdata/Isaac.Newton-Opticks.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 1; i < 10; i++ {
+		b := new(bytes.Buffer)
+		w := NewZLIBWriter(b, i)
+		w.Write(data)
+		w.Close()
+		zr, err := zlib.NewReader(bytes.NewReader(b.Bytes()))
+		if err != nil {
+			t.Fatalf("error creating zlib reader: %v", err)
+		}
+		decompressed, err := io.ReadAll(zr)
+		if err != nil {
+			t.Fatalf("error decompressing level %d: %v", i, err)
+		}
+		if !bytes.Equal(decompressed, data) {
+			t.Fatalf("decompressed output doesn't match on level %d", i)
+		}
+	}
+}
+
+func TestZLIBWriterDict(t *testing.T) {
+	data, err := os.ReadFile("../testdata/Isaac.Newton-Opticks.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dict := data[:50000]
+	data = data[50000:60000]
+
+	for i := 1; i < 10; i++ {
+		plain := new(bytes.Buffer)
+		w := NewZLIBWriter(plain, i)
+		w.Write(data)
+		w.Close()
+
+		b := new(bytes.Buffer)
+		w = NewZLIBWriterDict(b, i, dict)
+		w.Write(data)
+		w.Close()
+		if b.Len() >= plain.Len() {
+			t.Errorf("level %d: compressed size with dictionary is %d bytes, without dictionary %d", i, b.Len(), plain.Len())
+		}
+
+		zr, err := zlib.NewReaderDict(bytes.NewReader(b.Bytes()), dict)
+		if err != nil {
+			t.Fatalf("error creating zlib reader: %v", err)
+		}
+		decompressed, err := io.ReadAll(zr)
+		if err != nil {
+			t.Fatalf("error decompressing level %d: %v", i, err)
+		}
+		if !bytes.Equal(decompressed, data) {
+			t.Fatalf("decompressed output doesn't match on level %d", i)
+		}
+	}
+}
+
+func readerTestData(t *testing.T) [][]byte {
+	text, err := os.ReadFile("../testdata/Isaac.Newton-Opticks.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	random := make([]byte, 100000)
+	rand.New(rand.NewSource(1)).Read(random)
+	zeros := append(make([]byte, 100000), bytes.Repeat([]byte("abc"), 1000)...)
+	return [][]byte{text, random, zeros, nil, []byte("a")}
+}
+
+func TestReader(t *testing.T) {
+	for _, data := range readerTestData(t) {
+		var streams [][]byte
+		for level := 1; level < 10; level++ {
+			b := new(bytes.Buffer)
+			w := NewWriter(b, level)
+			w.Write(data)
+			w.Close()
+			streams = append(streams, b.Bytes())
+		}
+		for _, level := range []int{flate.HuffmanOnly, flate.NoCompression, flate.BestSpeed, flate.DefaultCompression, flate.BestCompression} {
+			b := new(bytes.Buffer)
+			w, _ := flate.NewWriter(b, level)
+			w.Write(data)
+			w.Close()
+			streams = append(streams, b.Bytes())
+		}
+
+		r := new(Reader)
+		for i, compressed := range streams {
+			r.Reset(iotest.HalfReader(bytes.NewReader(compressed)))
+			decompressed, err := io.ReadAll(r)
+			if err != nil {
+				t.Fatalf("stream %d: error decompressing: %v", i, err)
+			}
+			if !bytes.Equal(decompressed, data) {
+				t.Fatalf("stream %d: decompressed output doesn't match", i)
+			}
+
+			decompressed, err = r.Decode([]byte("prefix"), compressed)
+			if err != nil {
+				t.Fatalf("stream %d: error from Decode: %v", i, err)
+			}
+			if !bytes.Equal(decompressed, append([]byte("prefix"), data...)) {
+				t.Fatalf("stream %d: output of Decode doesn't match", i)
+			}
+
+			if len(compressed) > 0 {
+				_, err = Decode(nil, compressed[:len(compressed)-1])
+				if err != io.ErrUnexpectedEOF {
+					t.Fatalf("stream %d: truncated input: got %v, want %v", i, err, io.ErrUnexpectedEOF)
+				}
+			}
+		}
+	}
+}
+
+func TestReaderMaxSize(t *testing.T) {
+	data := make([]byte, 1<<20)
+	b := new(bytes.Buffer)
+	w := NewWriter(b, 6)
+	w.Write(data)
+	w.Close()
+
+	r := NewReader(bytes.NewReader(b.Bytes()))
+	r.MaxSize = 100000
+	decompressed, err := io.ReadAll(r)
+	if err != ErrTooLarge {
+		t.Fatalf("got error %v, want %v", err, ErrTooLarge)
+	}
+	if len(decompressed) != 100000 {
+		t.Fatalf("got %d bytes, want 100000", len(decompressed))
+	}
+
+	r.MaxSize = int64(len(data))
+	if _, err := r.Decode(nil, b.Bytes()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGZIPReader(t *testing.T) {
+	data, err := os.ReadFile("../testdata/Isaac.Newton-Opticks.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Two members: one from the standard library, and one of ours with a
+	// full header.
+	b := new(bytes.Buffer)
+	sw := gzip.NewWriter(b)
+	sw.Write(data[:100000])
+	sw.Close()
+	h := GZIPHeader{
+		Name:    "Opticks-ü.txt",
+		Comment: "part 2",
+		Extra:   []byte("AB\x00\x00"),
+		ModTime: time.Date(2001, 2, 3, 4, 5, 6, 0, time.UTC),
+		OS:      3,
+	}
+	w, err := NewGZIPWriterHeader(b, 6, h)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write(data[100000:])
+	w.Close()
+	compressed := b.Bytes()
+
+	zr, err := NewGZIPReader(iotest.HalfReader(bytes.NewReader(compressed)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	decompressed, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decompressed, data) {
+		t.Fatal("decompressed output doesn't match")
+	}
+	if zr.Header.Name != h.Name || zr.Header.Comment != h.Comment || !bytes.Equal(zr.Header.Extra, h.Extra) ||
+		!zr.Header.ModTime.Equal(h.ModTime) || zr.Header.OS != h.OS {
+		t.Errorf("Header = %+v, want %+v", zr.Header, h)
+	}
+
+	// Without multistream, reading stops after the first member.
+	br := bytes.NewReader(compressed)
+	zr.Reset(br)
+	zr.Multistream(false)
+	decompressed, err = io.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decompressed, data[:100000]) {
+		t.Fatal("decompressed output of first member doesn't match")
+	}
+	if rest := compressed[len(compressed)-br.Len():]; !bytes.HasPrefix(rest, []byte{0x1f, 0x8b}) {
+		t.Fatal("reader is not positioned at the start of the second member")
+	}
+
+	decompressed, err = DecodeGZIP(nil, compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decompressed, data) {
+		t.Fatal("output of DecodeGZIP doesn't match")
+	}
+
+	zr.MaxSize = 150000
+	decompressed, err = zr.Decode(nil, compressed)
+	if err != ErrTooLarge || len(decompressed) != 150000 {
+		t.Fatalf("with MaxSize: got %d bytes and error %v", len(decompressed), err)
+	}
+
+	corrupted := bytes.Clone(compressed)
+	corrupted[len(corrupted)-5]++
+	if _, err := DecodeGZIP(nil, corrupted); !errors.Is(err, ErrChecksum) {
+		t.Fatalf("with corrupted trailer: got error %v, want %v", err, ErrChecksum)
+	}
+}
+
+func TestZLIBReader(t *testing.T) {
+	for _, data := range readerTestData(t) {
+		for level := 1; level < 10; level += 4 {
+			ours := new(bytes.Buffer)
+			w := NewZLIBWriter(ours, level)
+			w.Write(data)
+			w.Close()
+			theirs := new(bytes.Buffer)
+			zw, _ := zlib.NewWriterLevel(theirs, level)
+			zw.Write(data)
+			zw.Close()
+
+			for _, compressed := range [][]byte{ours.Bytes(), theirs.Bytes()} {
+				zr, err := NewZLIBReader(iotest.HalfReader(bytes.NewReader(compressed)))
+				if err != nil {
+					t.Fatal(err)
+				}
+				decompressed, err := io.ReadAll(zr)
+				if err != nil {
+					t.Fatalf("level %d: %v", level, err)
+				}
+				if !bytes.Equal(decompressed, data) {
+					t.Fatalf("level %d: decompressed output doesn't match", level)
+				}
+			}
+		}
+	}
+
+	b := new(bytes.Buffer)
+	w := NewZLIBWriter(b, 6)
+	w.Write([]byte("hello, world"))
+	w.Close()
+	corrupted := bytes.Clone(b.Bytes())
+	corrupted[len(corrupted)-1]++
+	zr, err := NewZLIBReader(bytes.NewReader(corrupted))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := io.ReadAll(zr); err == nil {
+		t.Error("no error for a corrupted checksum")
+	}
+	if _, err := NewZLIBReader(bytes.NewReader([]byte{0x78, 0x00})); err == nil {
+		t.Error("no error for an invalid header")
+	}
+
+	b.Reset()
+	w = NewZLIBWriterDict(b, 6, []byte("hello"))
+	w.Write([]byte("hello, world"))
+	w.Close()
+	if _, err := NewZLIBReader(bytes.NewReader(b.Bytes())); err == nil {
+		t.Error("no error for a stream with a preset dictionary")
+	}
+}
+
+// replayMatches reconstructs the data described by matches.
+func replayMatches(t *testing.T, matches []matchfinder.Match, literals []byte) []byte {
+	t.Helper()
+	var out []byte
+	for _, m := range matches {
+		pos := len(out)
+		out = append(out, literals[pos:pos+m.Unmatched]...)
+		if m.Length == 0 {
+			continue
+		}
+		if m.Distance < 1 || m.Distance > len(out) || m.Distance > windowSize || m.Length > maxMatchLength {
+			t.Fatalf("invalid match %+v at position %d", m, len(out))
+		}
+		for range m.Length {
+			out = append(out, out[len(out)-m.Distance])
+		}
+	}
+	return out
+}
+
+func TestReadMatches(t *testing.T) {
+	for _, data := range readerTestData(t) {
+		for _, level := range []int{1, 6, 9} {
+			b := new(bytes.Buffer)
+			w, _ := flate.NewWriter(b, level)
+			w.Write(data)
+			w.Close()
+
+			r := NewReader(bytes.NewReader(b.Bytes()))
+			var decompressed []byte
+			var matches []matchfinder.Match
+			var err error
+			for err == nil {
+				n := len(matches)
+				start := len(decompressed)
+				decompressed, matches, err = r.ReadMatches(decompressed, matches)
+				// The matches from each call should cover the data from
+				// that call.
+				covered := 0
+				for _, m := range matches[n:] {
+					covered += m.Unmatched + m.Length
+				}
+				if covered != len(decompressed)-start {
+					t.Fatalf("level %d: matches cover %d bytes, got %d bytes of data", level, covered, len(decompressed)-start)
+				}
+			}
+			if err != io.EOF {
+				t.Fatalf("level %d: %v", level, err)
+			}
+			if !bytes.Equal(decompressed, data) {
+				t.Fatalf("level %d: decompressed output doesn't match", level)
+			}
+			if !bytes.Equal(replayMatches(t, matches, data), data) {
+				t.Fatalf("level %d: replaying the matches doesn't give the same data", level)
+			}
+		}
+	}
+
+	// After Read, the data that hasn't been returned yet is reported as
+	// literals. With MaxSize, the matches stop where the data does.
+	data := readerTestData(t)[0]
+	b := new(bytes.Buffer)
+	sw := gzip.NewWriter(b)
+	sw.Write(data[:100000])
+	sw.Close()
+	sw = gzip.NewWriter(b)
+	sw.Write(data[100000:])
+	sw.Close()
+	zr, err := NewGZIPReader(bytes.NewReader(b.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr.MaxSize = 500000
+	decompressed := make([]byte, 1000)
+	if _, err := io.ReadFull(zr, decompressed); err != nil {
+		t.Fatal(err)
+	}
+	var matches []matchfinder.Match
+	for err == nil {
+		decompressed, matches, err = zr.ReadMatches(decompressed, matches)
+	}
+	if err != ErrTooLarge || len(decompressed) != 500000 {
+		t.Fatalf("with MaxSize: got %d bytes and error %v", len(decompressed), err)
+	}
+	if !bytes.Equal(decompressed, data[:500000]) {
+		t.Fatal("decompressed output doesn't match")
+	}
+	replayed := replayMatches(t, matches, data[1000:])
+	if !bytes.Equal(replayed, data[1000:500000]) {
+		t.Fatal("replaying the matches doesn't give the same data")
+	}
+}
+
+func BenchmarkReader(b *testing.B) {
+	data, err := os.ReadFile("../testdata/Isaac.Newton-Opticks.txt")
+	if err != nil {
+		b.Fatal(err)
+	}
+	buf := new(bytes.Buffer)
+	w := NewWriter(buf, 6)
+	w.Write(data)
+	w.Close()
+	compressed := buf.Bytes()
+
+	r := new(Reader)
+	var out []byte
+	b.SetBytes(int64(len(data)))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		out, err = r.Decode(out[:0], compressed)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
 func benchmark(b *testing.B, filename string, m matchfinder.MatchFinder, blockSize int) {
 	b.StopTimer()
 	b.ReportAllocs()
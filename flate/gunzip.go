@@ -0,0 +1,232 @@
+package flate
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"time"
+
+	"github.com/andybalholm/brotli/matchfinder"
+)
+
+var (
+	// ErrChecksum is returned when a gzip stream's checksum or length
+	// doesn't match the decompressed data.
+	ErrChecksum = errors.New("flate: invalid gzip checksum")
+
+	// ErrHeader is returned when a gzip header is invalid.
+	ErrHeader = errors.New("flate: invalid gzip header")
+)
+
+// A GZIPReader decompresses data in the gzip format (RFC 1952).
+//
+// A gzip file may consist of several members, each with its own header.
+// By default, a GZIPReader reads all of them as one stream, and Header
+// holds the header of the current member.
+type GZIPReader struct {
+	Header GZIPHeader
+
+	// MaxSize is the maximum number of bytes of decompressed data, for all
+	// members together. If it is greater than zero, and the data is longer,
+	// Read returns ErrTooLarge after returning the first MaxSize bytes.
+	MaxSize int64
+
+	r           flateReader
+	f           Reader
+	crc         uint32
+	size        uint32
+	err         error
+	multistream bool
+	buf         [10]byte
+}
+
+// NewGZIPReader returns a new GZIPReader that decompresses data from r.
+// It reads the first gzip header before returning.
+func NewGZIPReader(r io.Reader) (*GZIPReader, error) {
+	z := new(GZIPReader)
+	if err := z.Reset(r); err != nil {
+		return nil, err
+	}
+	return z, nil
+}
+
+// Reset discards the GZIPReader's state and prepares it to decompress data
+// from r, reading the first gzip header. This permits reusing a GZIPReader
+// rather than allocating a new one.
+func (z *GZIPReader) Reset(r io.Reader) error {
+	z.r = makeReader(r)
+	z.f.written = 0
+	z.multistream = true
+	z.err = z.readHeader()
+	return z.err
+}
+
+// Multistream controls whether the GZIPReader reads all the members of a
+// gzip file (the default), or stops at the end of the current one. This
+// permits reading files that have other data after the gzip data; after
+// the end of a member, the underlying reader is positioned just after it.
+func (z *GZIPReader) Multistream(ok bool) {
+	z.multistream = ok
+}
+
+// Close returns nil. It is provided so that a GZIPReader can be used as an
+// io.ReadCloser; it doesn't close the underlying reader.
+func (z *GZIPReader) Close() error {
+	return nil
+}
+
+// Read decompresses data into p.
+func (z *GZIPReader) Read(p []byte) (n int, err error) {
+	if z.err != nil {
+		return 0, z.err
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	for n == 0 {
+		n, z.err = z.f.Read(p)
+		z.crc = crc32.Update(z.crc, crc32.IEEETable, p[:n])
+		z.size += uint32(n)
+		if z.err != io.EOF {
+			return n, z.err
+		}
+		if z.err = z.endMember(); z.err != nil {
+			return n, z.err
+		}
+	}
+	return n, nil
+}
+
+// endMember checks the trailer at the end of a gzip member, and reads the
+// header of the next one. It returns io.EOF if there are no more members
+// to read.
+func (z *GZIPReader) endMember() error {
+	if _, err := io.ReadFull(z.r, z.buf[:8]); err != nil {
+		return noEOF(err)
+	}
+	if binary.LittleEndian.Uint32(z.buf[:4]) != z.crc || binary.LittleEndian.Uint32(z.buf[4:8]) != z.size {
+		return ErrChecksum
+	}
+	if !z.multistream {
+		return io.EOF
+	}
+	return z.readHeader()
+}
+
+// ReadMatches is like Reader.ReadMatches. Each member of the gzip file is
+// compressed separately, so the matches never refer to data from a
+// previous member.
+func (z *GZIPReader) ReadMatches(data []byte, matches []matchfinder.Match) ([]byte, []matchfinder.Match, error) {
+	if z.err != nil {
+		return data, matches, z.err
+	}
+	start := len(data)
+	data, matches, z.err = z.f.ReadMatches(data, matches)
+	z.crc = crc32.Update(z.crc, crc32.IEEETable, data[start:])
+	z.size += uint32(len(data) - start)
+	if z.err == io.EOF {
+		z.err = z.endMember()
+	}
+	return data, matches, z.err
+}
+
+// Decode decompresses the gzip data in src, appends it to dst, and returns
+// the updated slice. It uses z's buffers and MaxSize, and leaves z in an
+// unspecified state; call Reset before using z as an io.Reader again.
+func (z *GZIPReader) Decode(dst, src []byte) ([]byte, error) {
+	z.f.br.Reset(src)
+	if err := z.Reset(&z.f.br); err != nil {
+		return dst, err
+	}
+	return readAppend(dst, z)
+}
+
+// DecodeGZIP decompresses the gzip data in src, appends it to dst, and
+// returns the updated slice.
+func DecodeGZIP(dst, src []byte) ([]byte, error) {
+	return new(GZIPReader).Decode(dst, src)
+}
+
+// readHeader reads a gzip header, and prepares to decompress the member
+// that follows it. If there is no more data, it returns io.EOF.
+func (z *GZIPReader) readHeader() error {
+	if _, err := io.ReadFull(z.r, z.buf[:10]); err != nil {
+		// io.EOF here means the end of a multistream file (or an empty
+		// input).
+		return err
+	}
+	if z.buf[0] != 0x1f || z.buf[1] != 0x8b || z.buf[2] != 8 {
+		return ErrHeader
+	}
+	flags := z.buf[3]
+	if flags&0xe0 != 0 {
+		// reserved flags
+		return ErrHeader
+	}
+	digest := crc32.Update(0, crc32.IEEETable, z.buf[:10])
+
+	h := GZIPHeader{OS: z.buf[9]}
+	if mtime := binary.LittleEndian.Uint32(z.buf[4:8]); mtime > 0 {
+		h.ModTime = time.Unix(int64(mtime), 0)
+	}
+
+	if flags&gzipFlagExtra != 0 {
+		if _, err := io.ReadFull(z.r, z.buf[:2]); err != nil {
+			return noEOF(err)
+		}
+		digest = crc32.Update(digest, crc32.IEEETable, z.buf[:2])
+		h.Extra = make([]byte, binary.LittleEndian.Uint16(z.buf[:2]))
+		if _, err := io.ReadFull(z.r, h.Extra); err != nil {
+			return noEOF(err)
+		}
+		digest = crc32.Update(digest, crc32.IEEETable, h.Extra)
+	}
+
+	var err error
+	if flags&gzipFlagName != 0 {
+		if h.Name, digest, err = z.readLatin1(digest); err != nil {
+			return err
+		}
+	}
+	if flags&gzipFlagComment != 0 {
+		if h.Comment, digest, err = z.readLatin1(digest); err != nil {
+			return err
+		}
+	}
+
+	if flags&gzipFlagHeaderCRC != 0 {
+		h.HeaderCRC = true
+		if _, err := io.ReadFull(z.r, z.buf[:2]); err != nil {
+			return noEOF(err)
+		}
+		if binary.LittleEndian.Uint16(z.buf[:2]) != uint16(digest) {
+			return ErrHeader
+		}
+	}
+
+	z.Header = h
+	z.crc = 0
+	z.size = 0
+	z.f.restart(z.r)
+	z.f.MaxSize = z.MaxSize
+	return nil
+}
+
+// readLatin1 reads a zero-terminated ISO 8859-1 string, and converts it to
+// UTF-8. It also updates the header checksum.
+func (z *GZIPReader) readLatin1(digest uint32) (string, uint32, error) {
+	var s []rune
+	for {
+		c, err := z.r.ReadByte()
+		if err != nil {
+			return "", digest, noEOF(err)
+		}
+		digest = crc32.Update(digest, crc32.IEEETable, []byte{c})
+		if c == 0 {
+			return string(s), digest, nil
+		}
+		s = append(s, rune(c))
+	}
+}
@@ -1,7 +1,9 @@
 package flate
 
 import (
+	"errors"
 	"hash/crc32"
+	"math"
 	"time"
 
 	"github.com/andybalholm/brotli/matchfinder"
@@ -13,11 +15,64 @@ func NewGZIPEncoder() matchfinder.Encoder {
 	}
 }
 
+// A GZIPHeader holds the optional fields of a gzip header (RFC 1952).
+type GZIPHeader struct {
+	// Name is the name of the original file. Comment is a comment about the
+	// file. They are stored in ISO 8859-1 (Latin-1); characters that can't be
+	// represented in it (and NUL characters) are replaced with '?'.
+	Name    string
+	Comment string
+
+	// Extra is the contents of the extra field. It must not be longer than
+	// 65535 bytes.
+	Extra []byte
+
+	// HeaderCRC adds a checksum of the header (the FHCRC flag), which some
+	// decoders check.
+	HeaderCRC bool
+
+	// ModTime is the modification time of the original file. If it is the
+	// zero Time, the header has no time stamp, which is useful for making
+	// reproducible output. Times before 1970 are also left out; times
+	// after 2106 can't be represented.
+	ModTime time.Time
+
+	// OS is the type of file system where the file was compressed
+	// (e.g. 3 for Unix). The value for unknown is 255.
+	OS byte
+}
+
+var (
+	errExtraTooLong = errors.New("flate: gzip Extra field is longer than 65535 bytes")
+	errModTimeRange = errors.New("flate: gzip ModTime is out of range")
+)
+
+// NewGZIPEncoderHeader returns an Encoder for the gzip format that uses
+// the fields in h for its header. Unlike NewGZIPEncoder, it doesn't depend
+// on the current time, so its output is deterministic. It returns an error
+// if h.Extra is too long, or h.ModTime is too late to be stored.
+func NewGZIPEncoderHeader(h GZIPHeader) (matchfinder.Encoder, error) {
+	if len(h.Extra) > 0xffff {
+		return nil, errExtraTooLong
+	}
+	if h.ModTime.Unix() > math.MaxUint32 {
+		return nil, errModTimeRange
+	}
+	return &gzipEncoder{
+		f:      NewEncoder(),
+		header: &h,
+	}, nil
+}
+
 type gzipEncoder struct {
 	f           matchfinder.Encoder
 	length      uint32
 	crc         uint32
 	wroteHeader bool
+
+	// header is the header to write; if it is nil, the header has the
+	// current time and no optional fields.
+	header *GZIPHeader
 }
 
 func (g *gzipEncoder) Reset() {
@@ -38,16 +93,7 @@ func appendUint32(dst []byte, n uint32) []byte {
 
 func (g *gzipEncoder) Encode(dst []byte, src []byte, matches []matchfinder.Match, lastBlock bool) []byte {
 	if !g.wroteHeader {
-		dst = append(dst,
-			0x1f, 0x8b, // magic number
-			8, // CM = flate
-			0, // FLG
-		)
-		dst = appendUint32(dst, uint32(time.Now().Unix()))
-		dst = append(dst,
-			0,   // XFL
-			255, // OS (unspecified)
-		)
+		dst = g.appendHeader(dst)
 		g.wroteHeader = true
 	}
 
@@ -63,3 +109,73 @@ func (g *gzipEncoder) Encode(dst []byte, src []byte, matches []matchfinder.Match
 
 	return dst
 }
+
+const (
+	gzipFlagHeaderCRC = 1 << 1
+	gzipFlagExtra     = 1 << 2
+	gzipFlagName      = 1 << 3
+	gzipFlagComment   = 1 << 4
+)
+
+func (g *gzipEncoder) appendHeader(dst []byte) []byte {
+	h := g.header
+	if h == nil {
+		h = &GZIPHeader{ModTime: time.Now(), OS: 255}
+	}
+
+	var flags byte
+	if h.HeaderCRC {
+		flags |= gzipFlagHeaderCRC
+	}
+	if len(h.Extra) > 0 {
+		flags |= gzipFlagExtra
+	}
+	if h.Name != "" {
+		flags |= gzipFlagName
+	}
+	if h.Comment != "" {
+		flags |= gzipFlagComment
+	}
+	var mtime uint32
+	if !h.ModTime.IsZero() && h.ModTime.Unix() > 0 {
+		mtime = uint32(h.ModTime.Unix())
+	}
+
+	start := len(dst)
+	dst = append(dst,
+		0x1f, 0x8b, // magic number
+		8, // CM = flate
+		flags,
+	)
+	dst = appendUint32(dst, mtime)
+	dst = append(dst,
+		0, // XFL
+		h.OS,
+	)
+	if len(h.Extra) > 0 {
+		dst = append(dst, byte(len(h.Extra)), byte(len(h.Extra)>>8))
+		dst = append(dst, h.Extra...)
+	}
+	if h.Name != "" {
+		dst = appendLatin1(dst, h.Name)
+	}
+	if h.Comment != "" {
+		dst = appendLatin1(dst, h.Comment)
+	}
+	if h.HeaderCRC {
+		crc := crc32.ChecksumIEEE(dst[start:])
+		dst = append(dst, byte(crc), byte(crc>>8))
+	}
+	return dst
+}
+
+// appendLatin1 appends s to dst as a zero-terminated ISO 8859-1 string.
+func appendLatin1(dst []byte, s string) []byte {
+	for _, r := range s {
+		if r == 0 || r > 0xff {
+			r = '?'
+		}
+		dst = append(dst, byte(r))
+	}
+	return append(dst, 0)
+}
@@ -24,8 +24,9 @@ const (
 
 	maxNumLit         = 286
 	maxStoreBlockSize = 65535
-	baseMatchLength   = 3 // The smallest match length per the RFC section 3.2.5
-	baseMatchOffset   = 1 // The smallest match offset
+	baseMatchLength   = 3   // The smallest match length per the RFC section 3.2.5
+	maxMatchLength    = 258 // The largest match length per the RFC section 3.2.5
+	baseMatchOffset   = 1   // The smallest match offset
 )
 
 // The number of extra bits needed by length code X - LENGTH_CODES_START.
@@ -75,6 +76,10 @@ type huffmanBitWriter struct {
 	literalEncoding *huffmanEncoder
 	offsetEncoding  *huffmanEncoder
 	codegenEncoding *huffmanEncoder
+
+	// fitted is a buffer for matches that have been adjusted to the
+	// lengths that deflate supports.
+	fitted []matchfinder.Match
 }
 
 func NewEncoder() matchfinder.Encoder {
@@ -506,6 +511,14 @@ func (w *huffmanBitWriter) writeTokens(matches []matchfinder.Match, input []byte
 func (w *huffmanBitWriter) Encode(dst []byte, src []byte, matches []matchfinder.Match, lastBlock bool) []byte {
 	w.dst = dst
 
+	for _, m := range matches {
+		if m.Length > maxMatchLength || m.Length > 0 && m.Length < baseMatchLength {
+			w.fitted = fitMatchLengths(w.fitted[:0], matches)
+			matches = w.fitted
+			break
+		}
+	}
+
 	w.writeBlock(matches, lastBlock, src)
 	if lastBlock {
 		w.flush()
@@ -515,3 +528,32 @@ func (w *huffmanBitWriter) Encode(dst []byte, src []byte, matches []matchfinder.
 	w.dst = nil
 	return dst
 }
+
+// fitMatchLengths appends matches to dst, adjusted to the lengths that
+// deflate supports: each match that is longer than maxMatchLength is
+// replaced with several shorter matches at the same distance, and matches
+// shorter than baseMatchLength (which other formats, such as brotli, can
+// have) are turned into literals.
+func fitMatchLengths(dst, matches []matchfinder.Match) []matchfinder.Match {
+	unmatched := 0
+	for _, m := range matches {
+		m.Unmatched += unmatched
+		unmatched = 0
+		if m.Length > 0 && m.Length < baseMatchLength {
+			unmatched = m.Unmatched + m.Length
+			continue
+		}
+		for m.Length > maxMatchLength {
+			// Don't leave a piece that is too short to be a match.
+			n := min(maxMatchLength, m.Length-baseMatchLength)
+			dst = append(dst, matchfinder.Match{Unmatched: m.Unmatched, Length: n, Distance: m.Distance})
+			m.Unmatched = 0
+			m.Length -= n
+		}
+		dst = append(dst, m)
+	}
+	if unmatched > 0 {
+		dst = append(dst, matchfinder.Match{Unmatched: unmatched})
+	}
+	return dst
+}
@@ -0,0 +1,663 @@
+// Copyright 2009 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package flate
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"math/bits"
+
+	"github.com/andybalholm/brotli/matchfinder"
+)
+
+var (
+	// ErrCorrupt is returned when the compressed data is invalid.
+	ErrCorrupt = errors.New("flate: corrupt input")
+
+	// ErrTooLarge is returned when the decompressed data is longer than
+	// the MaxSize of a Reader or GZIPReader.
+	ErrTooLarge = errors.New("flate: decompressed data is larger than MaxSize")
+)
+
+const (
+	windowSize = 1 << 15
+
+	// histSize is the size of a Reader's buffer: the window, plus room for
+	// the output of one decoding step.
+	histSize = 2 * windowSize
+
+	maxCodeLen = 16 // max length of Huffman code, plus one
+
+	huffmanChunkBits  = 9
+	huffmanNumChunks  = 1 << huffmanChunkBits
+	huffmanCountMask  = 15
+	huffmanValueShift = 4
+)
+
+// A huffmanDecoder decodes the symbols of a canonical Huffman code.
+//
+// The data structure for decoding is a two-level table: chunks is indexed
+// by the next huffmanChunkBits bits of input. Each chunk holds the symbol
+// value in the high bits and the code length in the low 4 bits. Codes
+// longer than huffmanChunkBits have a chunk that points to an entry in
+// links, which is indexed by the following bits.
+type huffmanDecoder struct {
+	min      int
+	chunks   [huffmanNumChunks]uint32
+	links    [][]uint32
+	linkMask uint32
+}
+
+// init initializes h to decode the code with the given code lengths.
+// It returns false if the lengths don't describe a valid code.
+func (h *huffmanDecoder) init(lengths []int) bool {
+	links := h.links[:0]
+	*h = huffmanDecoder{}
+
+	var count [maxCodeLen]int
+	var min, max int
+	for _, n := range lengths {
+		if n == 0 {
+			continue
+		}
+		if min == 0 || n < min {
+			min = n
+		}
+		if n > max {
+			max = n
+		}
+		count[n]++
+	}
+	if max == 0 {
+		// An empty code is allowed (for example, a distance code for a block
+		// with no matches), but it can't decode anything.
+		return true
+	}
+
+	code := 0
+	var nextCode [maxCodeLen]int
+	for i := min; i <= max; i++ {
+		code <<= 1
+		nextCode[i] = code
+		code += count[i]
+	}
+	// The code must be complete, except that a single code of length 1 is
+	// allowed, for compatibility with zlib.
+	if code != 1<<uint(max) && !(code == 1 && max == 1) {
+		return false
+	}
+
+	h.min = min
+	if max > huffmanChunkBits {
+		numLinks := 1 << (uint(max) - huffmanChunkBits)
+		h.linkMask = uint32(numLinks - 1)
+
+		link := nextCode[huffmanChunkBits+1] >> 1
+		if cap(links) < huffmanNumChunks-link {
+			links = append(links[:cap(links)], make([][]uint32, huffmanNumChunks-link-cap(links))...)
+		}
+		links = links[:huffmanNumChunks-link]
+		for j := link; j < huffmanNumChunks; j++ {
+			reverse := int(bits.Reverse16(uint16(j))) >> (16 - huffmanChunkBits)
+			off := j - link
+			h.chunks[reverse] = uint32(off<<huffmanValueShift | (huffmanChunkBits + 1))
+			if cap(links[off]) >= numLinks {
+				links[off] = links[off][:numLinks]
+				clear(links[off])
+			} else {
+				links[off] = make([]uint32, numLinks)
+			}
+		}
+	}
+	h.links = links
+
+	for i, n := range lengths {
+		if n == 0 {
+			continue
+		}
+		code := nextCode[n]
+		nextCode[n]++
+		chunk := uint32(i<<huffmanValueShift | n)
+		reverse := int(bits.Reverse16(uint16(code))) >> (16 - n)
+		if n <= huffmanChunkBits {
+			for off := reverse; off < len(h.chunks); off += 1 << uint(n) {
+				h.chunks[off] = chunk
+			}
+		} else {
+			j := reverse & (huffmanNumChunks - 1)
+			linkTab := h.links[h.chunks[j]>>huffmanValueShift]
+			reverse >>= huffmanChunkBits
+			for off := reverse; off < len(linkTab); off += 1 << uint(n-huffmanChunkBits) {
+				linkTab[off] = chunk
+			}
+		}
+	}
+	return true
+}
+
+// flateReader is the interface that a Reader needs for its input. If the
+// input doesn't implement io.ByteReader, it is wrapped in a bufio.Reader.
+//
+// Since a Reader reads its input one byte at a time, it never reads past
+// the end of the compressed data.
+type flateReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+func makeReader(r io.Reader) flateReader {
+	if fr, ok := r.(flateReader); ok {
+		return fr
+	}
+	return bufio.NewReader(r)
+}
+
+// A Reader decompresses data in the flate format (RFC 1951).
+type Reader struct {
+	// MaxSize is the maximum number of bytes of decompressed data. If it is
+	// greater than zero, and the compressed data decodes to more than
+	// MaxSize bytes, Read returns ErrTooLarge after returning the first
+	// MaxSize bytes.
+	MaxSize int64
+
+	r   flateReader
+	err error
+
+	// Input bits, in the low nbits bits of b.
+	b     uint32
+	nbits uint
+
+	// The decompressed data is written to hist[wpos:], and returned to the
+	// caller from hist[rpos:wpos]. The windowSize bytes before wpos are the
+	// history that matches can refer to.
+	hist    []byte
+	rpos    int
+	wpos    int
+	written int64
+
+	// The state of the current block.
+	final      bool
+	inBlock    bool
+	stored     int // the number of bytes remaining in a stored block
+	huffman    bool
+	lits, dist *huffmanDecoder
+	h1, h2     huffmanDecoder
+	lengths    [maxNumLit + offsetCodeCount]int
+
+	// unmatched is the number of literals decoded since the last match.
+	// If recording is set, decode appends the matches it decodes to
+	// matches.
+	unmatched int
+	recording bool
+	matches   []matchfinder.Match
+
+	// br is used by Decode to read from a byte slice.
+	br bytes.Reader
+}
+
+// NewReader returns a new Reader that decompresses data from r.
+func NewReader(r io.Reader) *Reader {
+	f := new(Reader)
+	f.Reset(r)
+	return f
+}
+
+// Reset discards the Reader's state and prepares it to decompress data from
+// r. This permits reusing a Reader rather than allocating a new one.
+func (f *Reader) Reset(r io.Reader) {
+	f.restart(makeReader(r))
+	f.written = 0
+}
+
+// restart prepares f to decompress a new stream from r, but leaves the
+// count of bytes written (for MaxSize) unchanged.
+func (f *Reader) restart(r flateReader) {
+	f.r = r
+	f.err = nil
+	f.b, f.nbits = 0, 0
+	if f.hist == nil {
+		f.hist = make([]byte, histSize)
+	}
+	f.rpos, f.wpos = 0, 0
+	f.final = false
+	f.inBlock = false
+}
+
+// Close returns nil. It is provided so that a Reader can be used as an
+// io.ReadCloser; it doesn't close the underlying reader.
+func (f *Reader) Close() error {
+	return nil
+}
+
+// Read decompresses data into p.
+func (f *Reader) Read(p []byte) (int, error) {
+	for {
+		if f.rpos < f.wpos {
+			n := copy(p, f.hist[f.rpos:f.wpos])
+			f.rpos += n
+			return n, nil
+		}
+		if f.err != nil {
+			return 0, f.err
+		}
+		if len(p) == 0 {
+			return 0, nil
+		}
+		f.step()
+	}
+}
+
+// step decodes the next part of the stream into f.hist, and returns the
+// position where the new data starts.
+func (f *Reader) step() (start int) {
+	if f.wpos > histSize-maxMatchLength {
+		// Move the window to the start of the buffer.
+		copy(f.hist, f.hist[f.wpos-windowSize:f.wpos])
+		f.rpos, f.wpos = windowSize, windowSize
+	}
+	start = f.wpos
+	f.err = f.decode()
+	f.written += int64(f.wpos - start)
+	if f.MaxSize > 0 && f.written > f.MaxSize {
+		f.wpos -= int(f.written - f.MaxSize)
+		f.written = f.MaxSize
+		f.err = ErrTooLarge
+	}
+	return start
+}
+
+// ReadMatches decompresses the next part of the stream (usually about 32
+// KB), appends it to data, and appends the matches that it was encoded
+// with to matches. The new matches cover exactly the new data (the last
+// one may have a Length of 0, for literals at the end), but their
+// distances may refer to data from previous calls. This makes
+// ReadMatches a matchfinder.MatchReader, which allows converting the data
+// to another format without searching for matches again.
+//
+// Data may be returned along with an error; at the end of the stream, the
+// error is io.EOF. If ReadMatches is called after Read, any data that Read
+// decompressed but didn't return is reported as literals.
+func (f *Reader) ReadMatches(data []byte, matches []matchfinder.Match) ([]byte, []matchfinder.Match, error) {
+	if f.rpos < f.wpos {
+		matches = append(matches, matchfinder.Match{Unmatched: f.wpos - f.rpos})
+		data = append(data, f.hist[f.rpos:f.wpos]...)
+		f.rpos = f.wpos
+		return data, matches, nil
+	}
+	if f.err != nil {
+		return data, matches, f.err
+	}
+
+	first := len(matches)
+	f.unmatched = 0
+	f.recording = true
+	f.matches = matches
+	start := f.step()
+	matches = f.matches
+	f.recording = false
+	f.matches = nil
+	if f.unmatched > 0 {
+		matches = append(matches, matchfinder.Match{Unmatched: f.unmatched})
+	}
+	if f.err == ErrTooLarge {
+		matches = append(matches[:first], trimMatches(matches[first:], f.wpos-start)...)
+	}
+
+	data = append(data, f.hist[start:f.wpos]...)
+	f.rpos = f.wpos
+	return data, matches, f.err
+}
+
+// trimMatches shortens matches so that they cover only the first n bytes.
+func trimMatches(matches []matchfinder.Match, n int) []matchfinder.Match {
+	for i, m := range matches {
+		if m.Unmatched >= n {
+			matches[i] = matchfinder.Match{Unmatched: n}
+			return matches[:i+1]
+		}
+		n -= m.Unmatched
+		if m.Length >= n {
+			matches[i].Length = n
+			return matches[:i+1]
+		}
+		n -= m.Length
+	}
+	return matches
+}
+
+// Decode decompresses src, appends the result to dst, and returns the
+// updated slice. It uses f's buffers and MaxSize, and leaves f in an
+// unspecified state; call Reset before using f as an io.Reader again.
+func (f *Reader) Decode(dst, src []byte) ([]byte, error) {
+	f.br.Reset(src)
+	f.Reset(&f.br)
+	return readAppend(dst, f)
+}
+
+// readAppend reads from r until EOF, and appends the data to dst.
+func readAppend(dst []byte, r io.Reader) ([]byte, error) {
+	for {
+		if len(dst) == cap(dst) {
+			dst = append(dst, 0)[:len(dst)]
+		}
+		n, err := r.Read(dst[len(dst):cap(dst)])
+		dst = dst[:len(dst)+n]
+		if err == io.EOF {
+			return dst, nil
+		}
+		if err != nil {
+			return dst, err
+		}
+	}
+}
+
+// Decode decompresses the flate data in src, appends it to dst, and returns
+// the updated slice.
+func Decode(dst, src []byte) ([]byte, error) {
+	return new(Reader).Decode(dst, src)
+}
+
+// decode decompresses data into f.hist, until the buffer is nearly full or
+// the end of the stream is reached. At the end of the stream, it returns
+// io.EOF.
+func (f *Reader) decode() error {
+	for f.wpos <= histSize-maxMatchLength {
+		if !f.inBlock {
+			if f.final {
+				return io.EOF
+			}
+			if err := f.readBlockHeader(); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if !f.huffman {
+			n := min(f.stored, histSize-f.wpos)
+			if _, err := io.ReadFull(f.r, f.hist[f.wpos:f.wpos+n]); err != nil {
+				return noEOF(err)
+			}
+			f.wpos += n
+			f.stored -= n
+			f.unmatched += n
+			if f.stored == 0 {
+				f.inBlock = false
+			}
+			continue
+		}
+
+		sym, err := f.readSymbol(f.lits)
+		if err != nil {
+			return err
+		}
+		switch {
+		case sym < endBlockMarker:
+			f.hist[f.wpos] = byte(sym)
+			f.wpos++
+			f.unmatched++
+			continue
+		case sym == endBlockMarker:
+			f.inBlock = false
+			continue
+		case sym >= lengthCodesStart+len(lengthBase):
+			return ErrCorrupt
+		}
+
+		code := sym - lengthCodesStart
+		length := lengthBase[code] + baseMatchLength
+		if n := uint(lengthExtraBits[code]); n > 0 {
+			extra, err := f.readBits(n)
+			if err != nil {
+				return err
+			}
+			length += int(extra)
+		}
+
+		var distCode int
+		if f.dist == nil {
+			// The fixed distance code is 5 bits, reversed.
+			c, err := f.readBits(5)
+			if err != nil {
+				return err
+			}
+			distCode = int(bits.Reverse8(uint8(c << 3)))
+		} else if distCode, err = f.readSymbol(f.dist); err != nil {
+			return err
+		}
+		if distCode >= offsetCodeCount {
+			return ErrCorrupt
+		}
+		distance := offsetBase[distCode] + baseMatchOffset
+		if n := uint(offsetExtraBits[distCode]); n > 0 {
+			extra, err := f.readBits(n)
+			if err != nil {
+				return err
+			}
+			distance += int(extra)
+		}
+
+		if distance > f.wpos {
+			return ErrCorrupt
+		}
+		from := f.wpos - distance
+		if distance >= length {
+			copy(f.hist[f.wpos:f.wpos+length], f.hist[from:])
+		} else {
+			for i := range length {
+				f.hist[f.wpos+i] = f.hist[from+i]
+			}
+		}
+		f.wpos += length
+		if f.recording {
+			f.matches = append(f.matches, matchfinder.Match{Unmatched: f.unmatched, Length: length, Distance: distance})
+		}
+		f.unmatched = 0
+	}
+	return nil
+}
+
+// noEOF converts io.EOF to io.ErrUnexpectedEOF, since the compressed data
+// shouldn't end in the middle of a block.
+func noEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// readBits reads n bits of input (n <= 16).
+func (f *Reader) readBits(n uint) (uint32, error) {
+	for f.nbits < n {
+		c, err := f.r.ReadByte()
+		if err != nil {
+			return 0, noEOF(err)
+		}
+		f.b |= uint32(c) << f.nbits
+		f.nbits += 8
+	}
+	v := f.b & (1<<n - 1)
+	f.b >>= n
+	f.nbits -= n
+	return v, nil
+}
+
+// readSymbol reads a symbol encoded with h. It reads only as many bytes of
+// input as it needs.
+func (f *Reader) readSymbol(h *huffmanDecoder) (int, error) {
+	n := uint(h.min)
+	for {
+		for f.nbits < n {
+			c, err := f.r.ReadByte()
+			if err != nil {
+				return 0, noEOF(err)
+			}
+			f.b |= uint32(c) << f.nbits
+			f.nbits += 8
+		}
+		chunk := h.chunks[f.b&(huffmanNumChunks-1)]
+		n = uint(chunk & huffmanCountMask)
+		if n > huffmanChunkBits {
+			chunk = h.links[chunk>>huffmanValueShift][(f.b>>huffmanChunkBits)&h.linkMask]
+			n = uint(chunk & huffmanCountMask)
+		}
+		if n == 0 {
+			return 0, ErrCorrupt
+		}
+		if n <= f.nbits {
+			f.b >>= n
+			f.nbits -= n
+			return int(chunk >> huffmanValueShift), nil
+		}
+	}
+}
+
+var fixedLiteralDecoder huffmanDecoder
+
+func init() {
+	var lengths [288]int
+	for i := range lengths {
+		switch {
+		case i < 144:
+			lengths[i] = 8
+		case i < 256:
+			lengths[i] = 9
+		case i < 280:
+			lengths[i] = 7
+		default:
+			lengths[i] = 8
+		}
+	}
+	fixedLiteralDecoder.init(lengths[:])
+}
+
+func (f *Reader) readBlockHeader() error {
+	header, err := f.readBits(3)
+	if err != nil {
+		return err
+	}
+	f.final = header&1 != 0
+
+	switch header >> 1 {
+	case 0:
+		// Stored block: discard the rest of the current byte, and read the
+		// length and its complement.
+		f.b, f.nbits = 0, 0
+		var buf [4]byte
+		if _, err := io.ReadFull(f.r, buf[:]); err != nil {
+			return noEOF(err)
+		}
+		n := int(buf[0]) | int(buf[1])<<8
+		nn := int(buf[2]) | int(buf[3])<<8
+		if uint16(nn) != uint16(^n) {
+			return ErrCorrupt
+		}
+		f.huffman = false
+		f.stored = n
+		f.inBlock = n > 0
+
+	case 1:
+		f.huffman = true
+		f.lits = &fixedLiteralDecoder
+		f.dist = nil
+		f.inBlock = true
+
+	case 2:
+		if err := f.readDynamicHeader(); err != nil {
+			return err
+		}
+		f.huffman = true
+		f.lits = &f.h1
+		f.dist = &f.h2
+		f.inBlock = true
+
+	default:
+		return ErrCorrupt
+	}
+	return nil
+}
+
+// readDynamicHeader reads the code lengths for a block with dynamic Huffman
+// codes, and initializes f.h1 and f.h2 with them.
+func (f *Reader) readDynamicHeader() error {
+	counts, err := f.readBits(14)
+	if err != nil {
+		return err
+	}
+	nlit := int(counts&31) + 257
+	ndist := int(counts>>5&31) + 1
+	nclen := int(counts>>10) + 4
+	if nlit > maxNumLit {
+		return ErrCorrupt
+	}
+
+	lengths := f.lengths[:]
+	for i := range codegenCodeCount {
+		n := 0
+		if i < nclen {
+			v, err := f.readBits(3)
+			if err != nil {
+				return err
+			}
+			n = int(v)
+		}
+		lengths[codegenOrder[i]] = n
+	}
+	if !f.h1.init(lengths[:codegenCodeCount]) {
+		return ErrCorrupt
+	}
+
+	// Read the literal/length and distance code lengths, which are encoded
+	// together.
+	for i := 0; i < nlit+ndist; {
+		sym, err := f.readSymbol(&f.h1)
+		if err != nil {
+			return err
+		}
+		if sym < 16 {
+			lengths[i] = sym
+			i++
+			continue
+		}
+
+		var rep, n int
+		var repeated int
+		switch sym {
+		case 16:
+			if i == 0 {
+				return ErrCorrupt
+			}
+			rep, n = 3, 2
+			repeated = lengths[i-1]
+		case 17:
+			rep, n = 3, 3
+		case 18:
+			rep, n = 11, 7
+		default:
+			return ErrCorrupt
+		}
+		extra, err := f.readBits(uint(n))
+		if err != nil {
+			return err
+		}
+		rep += int(extra)
+		if i+rep > nlit+ndist {
+			return ErrCorrupt
+		}
+		for range rep {
+			lengths[i] = repeated
+			i++
+		}
+	}
+
+	if lengths[endBlockMarker] == 0 {
+		return ErrCorrupt
+	}
+	if !f.h1.init(lengths[:nlit]) || !f.h2.init(lengths[nlit:nlit+ndist]) {
+		return ErrCorrupt
+	}
+	return nil
+}
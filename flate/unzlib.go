@@ -0,0 +1,102 @@
+package flate
+
+import (
+	"encoding/binary"
+	"errors"
+	"hash"
+	"hash/adler32"
+	"io"
+)
+
+var (
+	errZLIBHeader     = errors.New("flate: invalid zlib header")
+	errZLIBChecksum   = errors.New("flate: invalid zlib checksum")
+	errZLIBDictionary = errors.New("flate: zlib stream requires a preset dictionary")
+)
+
+// A ZLIBReader decompresses data in the zlib format (RFC 1950). Streams
+// that use a preset dictionary are not supported.
+type ZLIBReader struct {
+	// MaxSize is the maximum number of bytes of decompressed data. If it is
+	// greater than zero, and the data is longer, Read returns ErrTooLarge
+	// after returning the first MaxSize bytes.
+	MaxSize int64
+
+	r     flateReader
+	f     Reader
+	adler hash.Hash32
+	err   error
+	buf   [4]byte
+}
+
+// NewZLIBReader returns a new ZLIBReader that decompresses data from r.
+// It reads the zlib header before returning.
+func NewZLIBReader(r io.Reader) (*ZLIBReader, error) {
+	z := new(ZLIBReader)
+	if err := z.Reset(r); err != nil {
+		return nil, err
+	}
+	return z, nil
+}
+
+// Reset discards the ZLIBReader's state and prepares it to decompress data
+// from r, reading the zlib header. This permits reusing a ZLIBReader
+// rather than allocating a new one.
+func (z *ZLIBReader) Reset(r io.Reader) error {
+	z.r = makeReader(r)
+	if z.adler == nil {
+		z.adler = adler32.New()
+	}
+	z.adler.Reset()
+	z.err = z.readHeader()
+	return z.err
+}
+
+// Close returns nil. It is provided so that a ZLIBReader can be used as an
+// io.ReadCloser; it doesn't close the underlying reader.
+func (z *ZLIBReader) Close() error {
+	return nil
+}
+
+// Read decompresses data into p.
+func (z *ZLIBReader) Read(p []byte) (n int, err error) {
+	if z.err != nil {
+		return 0, z.err
+	}
+	n, z.err = z.f.Read(p)
+	z.adler.Write(p[:n])
+	if z.err == io.EOF {
+		z.err = z.readTrailer()
+	}
+	return n, z.err
+}
+
+// readHeader reads the zlib header, and prepares to decompress the data
+// that follows it.
+func (z *ZLIBReader) readHeader() error {
+	if _, err := io.ReadFull(z.r, z.buf[:2]); err != nil {
+		return noEOF(err)
+	}
+	cmf, flg := z.buf[0], z.buf[1]
+	if cmf&0x0f != 8 || cmf>>4 > 7 || binary.BigEndian.Uint16(z.buf[:2])%31 != 0 {
+		return errZLIBHeader
+	}
+	if flg&(1<<5) != 0 {
+		return errZLIBDictionary
+	}
+	z.f.Reset(z.r)
+	z.f.MaxSize = z.MaxSize
+	return nil
+}
+
+// readTrailer checks the Adler-32 checksum at the end of the stream. It
+// returns io.EOF if it matches.
+func (z *ZLIBReader) readTrailer() error {
+	if _, err := io.ReadFull(z.r, z.buf[:4]); err != nil {
+		return noEOF(err)
+	}
+	if binary.BigEndian.Uint32(z.buf[:4]) != z.adler.Sum32() {
+		return errZLIBChecksum
+	}
+	return io.EOF
+}
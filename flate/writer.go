@@ -1,6 +1,7 @@
 package flate
 
 import (
+	"hash/adler32"
 	"io"
 
 	"github.com/andybalholm/brotli/matchfinder"
@@ -20,6 +21,42 @@ func NewGZIPWriter(w io.Writer, level int) *matchfinder.Writer {
 	return newWriter(w, level, NewGZIPEncoder())
 }
 
+// NewGZIPWriterHeader is like NewGZIPWriter, but it uses the fields in h
+// for the gzip header. It returns an error if h.Extra is too long, or
+// h.ModTime is too late to be stored.
+func NewGZIPWriterHeader(w io.Writer, level int, h GZIPHeader) (*matchfinder.Writer, error) {
+	e, err := NewGZIPEncoderHeader(h)
+	if err != nil {
+		return nil, err
+	}
+	return newWriter(w, level, e), nil
+}
+
+// NewZLIBWriter returns a new matchfinder.Writer that compresses data at the
+// given level, in zlib encoding. Levels 1–9 are available; levels outside
+// this range will be replaced by the closest level available.
+func NewZLIBWriter(w io.Writer, level int) *matchfinder.Writer {
+	return newWriter(w, level, newZLIBEncoder(level))
+}
+
+// NewZLIBWriterDict is like NewZLIBWriter, but it uses dict as a preset
+// dictionary. The decompressor must be given the same dictionary.
+// Only the last 32 KB of dict can be referred to by matches.
+func NewZLIBWriterDict(w io.Writer, level int, dict []byte) *matchfinder.Writer {
+	e := newZLIBEncoder(level)
+	e.hasDict = true
+	e.dictID = adler32.Checksum(dict)
+	zw := newWriter(w, level, e)
+	if len(dict) > 1<<15 {
+		dict = dict[len(dict)-1<<15:]
+	}
+	zw.MatchFinder = &dictMatchFinder{
+		MatchFinder: zw.MatchFinder,
+		dict:        dict,
+	}
+	return zw
+}
+
 func newWriter(w io.Writer, level int, e matchfinder.Encoder) *matchfinder.Writer {
 	var mf matchfinder.MatchFinder
 	if level < 2 {
@@ -54,6 +91,7 @@ func newWriter(w io.Writer, level int, e matchfinder.Encoder) *matchfinder.Write
 			MaxDistance: 1 << 15,
 			ChainLength: chainLen,
 			HashLen:     hashLen,
+			CostModel:   CostModel{},
 		}
 	}
 
@@ -0,0 +1,115 @@
+package flate
+
+import (
+	"hash"
+	"hash/adler32"
+
+	"github.com/andybalholm/brotli/matchfinder"
+)
+
+// NewZLIBEncoder returns an Encoder for the zlib format (RFC 1950).
+func NewZLIBEncoder() matchfinder.Encoder {
+	return newZLIBEncoder(6)
+}
+
+// NewZLIBEncoderDict is like NewZLIBEncoder, but it marks the output as
+// using dict as a preset dictionary. The MatchFinder that is used with it
+// must treat dict as data that came before the start of the stream, so that
+// matches can refer to it; NewZLIBWriterDict takes care of that.
+func NewZLIBEncoderDict(dict []byte) matchfinder.Encoder {
+	z := newZLIBEncoder(6)
+	z.hasDict = true
+	z.dictID = adler32.Checksum(dict)
+	return z
+}
+
+// newZLIBEncoder returns a zlibEncoder whose header indicates the given
+// compression level.
+func newZLIBEncoder(level int) *zlibEncoder {
+	return &zlibEncoder{
+		f:     NewEncoder(),
+		level: level,
+		adler: adler32.New(),
+	}
+}
+
+type zlibEncoder struct {
+	f           matchfinder.Encoder
+	level       int
+	hasDict     bool
+	dictID      uint32
+	adler       hash.Hash32
+	wroteHeader bool
+}
+
+func (z *zlibEncoder) Reset() {
+	z.f.Reset()
+	z.adler.Reset()
+	z.wroteHeader = false
+}
+
+func (z *zlibEncoder) Encode(dst []byte, src []byte, matches []matchfinder.Match, lastBlock bool) []byte {
+	if !z.wroteHeader {
+		dst = z.appendHeader(dst)
+		z.wroteHeader = true
+	}
+
+	dst = z.f.Encode(dst, src, matches, lastBlock)
+	z.adler.Write(src)
+
+	if lastBlock {
+		dst = z.adler.Sum(dst)
+	}
+	return dst
+}
+
+func (z *zlibEncoder) appendHeader(dst []byte) []byte {
+	const cmf = 0x78 // CM = 8 (deflate), CINFO = 7 (32 KB window)
+
+	// FLEVEL is only informational; it indicates which of zlib's
+	// compression levels the output is most like.
+	var flevel byte
+	switch {
+	case z.level < 2:
+		flevel = 0
+	case z.level < 6:
+		flevel = 1
+	case z.level == 6:
+		flevel = 2
+	default:
+		flevel = 3
+	}
+	flg := flevel << 6
+	if z.hasDict {
+		flg |= 1 << 5 // FDICT
+	}
+	flg += 31 - byte((uint16(cmf)<<8|uint16(flg))%31) // FCHECK
+
+	dst = append(dst, cmf, flg)
+	if z.hasDict {
+		dst = append(dst, byte(z.dictID>>24), byte(z.dictID>>16), byte(z.dictID>>8), byte(z.dictID))
+	}
+	return dst
+}
+
+// dictMatchFinder wraps a MatchFinder, and gives it a preset dictionary at
+// the start of each stream, so that it can find matches in the dictionary.
+type dictMatchFinder struct {
+	matchfinder.MatchFinder
+	dict    []byte
+	primed  bool
+	scratch []matchfinder.Match
+}
+
+func (d *dictMatchFinder) Reset() {
+	d.MatchFinder.Reset()
+	d.primed = false
+}
+
+func (d *dictMatchFinder) FindMatches(dst []matchfinder.Match, src []byte) []matchfinder.Match {
+	if !d.primed && len(d.dict) > 0 {
+		d.scratch = d.MatchFinder.FindMatches(d.scratch[:0], d.dict)
+		d.primed = true
+	}
+	return d.MatchFinder.FindMatches(dst, src)
+}
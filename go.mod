@@ -4,4 +4,8 @@ go 1.22
 
 retract v1.0.1 // occasional panics and data corruption
 
-require github.com/xyproto/randomstring v1.0.5
+require (
+	github.com/klauspost/compress v1.17.11
+	github.com/xyproto/randomstring v1.0.5
+	golang.org/x/text v0.22.0
+)
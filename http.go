@@ -1,14 +1,16 @@
 package brotli
 
 import (
+	"fmt"
 	"io"
 	"net/http"
 	"strings"
 
 	"github.com/andybalholm/brotli/flate"
+	"github.com/andybalholm/brotli/zstd"
 )
 
-// HTTPCompressor chooses a compression method (brotli, gzip, or none) based on
+// HTTPCompressor chooses a compression method (brotli, zstd, gzip, or none) based on
 // the Accept-Encoding header, sets the Content-Encoding header, and returns a
 // WriteCloser that implements that compression. The Close method must be called
 // before the current HTTP handler returns.
@@ -21,11 +23,14 @@ func HTTPCompressorWithLevel(w http.ResponseWriter, r *http.Request, level int)
 		w.Header().Set("Vary", "Accept-Encoding")
 	}
 
-	encoding := negotiateContentEncoding(r, []string{"br", "gzip"})
+	encoding := negotiateContentEncoding(r, []string{"br", "zstd", "gzip"})
 	switch encoding {
 	case "br":
 		w.Header().Set("Content-Encoding", "br")
 		return NewWriterV2(w, level)
+	case "zstd":
+		w.Header().Set("Content-Encoding", "zstd")
+		return zstd.NewWriter(w, level)
 	case "gzip":
 		w.Header().Set("Content-Encoding", "gzip")
 		return flate.NewGZIPWriter(w, level)
@@ -33,6 +38,82 @@ func HTTPCompressorWithLevel(w http.ResponseWriter, r *http.Request, level int)
 	return nopCloser{w}
 }
 
+// HTTPDecompressRequest returns a ReadCloser that decompresses r's body
+// according to its Content-Encoding header (brotli, gzip, deflate, or
+// none), and removes the header. As specified in RFC 9110, deflate means
+// the zlib format; raw deflate data isn't accepted. Closing the ReadCloser
+// closes the body. If the body uses an encoding that isn't supported, it
+// returns an error.
+func HTTPDecompressRequest(r *http.Request) (io.ReadCloser, error) {
+	body, err := decompressBody(r.Body, r.Header)
+	if err != nil {
+		return nil, err
+	}
+	r.Header.Del("Content-Encoding")
+	r.Header.Del("Content-Length")
+	return body, nil
+}
+
+// HTTPDecompressResponse is like HTTPDecompressRequest, but for the body of
+// an HTTP response.
+func HTTPDecompressResponse(resp *http.Response) (io.ReadCloser, error) {
+	body, err := decompressBody(resp.Body, resp.Header)
+	if err != nil {
+		return nil, err
+	}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	return body, nil
+}
+
+// decompressBody wraps body with decompressors for the encodings listed in
+// the Content-Encoding header. If several encodings were applied, they are
+// listed in the order they were applied, so they are removed in reverse
+// order.
+func decompressBody(body io.ReadCloser, header http.Header) (io.ReadCloser, error) {
+	var encodings []string
+	for _, v := range header.Values("Content-Encoding") {
+		for _, e := range strings.Split(v, ",") {
+			if e = strings.ToLower(strings.TrimSpace(e)); e != "" && e != "identity" {
+				encodings = append(encodings, e)
+			}
+		}
+	}
+
+	var r io.Reader = body
+	for i := len(encodings) - 1; i >= 0; i-- {
+		switch encodings[i] {
+		case "br":
+			r = NewReader(r)
+		case "gzip", "x-gzip":
+			zr, err := flate.NewGZIPReader(r)
+			if err != nil {
+				return nil, err
+			}
+			r = zr
+		case "deflate":
+			zr, err := flate.NewZLIBReader(r)
+			if err != nil {
+				return nil, err
+			}
+			r = zr
+		default:
+			return nil, fmt.Errorf("brotli: unsupported Content-Encoding %q", encodings[i])
+		}
+	}
+	if len(encodings) == 0 {
+		return body, nil
+	}
+	return decompressedBody{r, body}, nil
+}
+
+// A decompressedBody reads decompressed data from Reader, and closes the
+// original body when it is closed.
+type decompressedBody struct {
+	io.Reader
+	io.Closer
+}
+
 // negotiateContentEncoding returns the best offered content encoding for the
 // request's Accept-Encoding header. If two offers match with equal weight and
 // then the offer earlier in the list is preferred. If no offers are
@@ -124,7 +124,7 @@ func estimateBitCostsForLiteralsUTF8(pos uint, len uint, mask uint, data []byte,
 			   rapidly in the beginning of the file, perhaps because the beginning
 			   of the data is a statistical "anomaly". */
 			if i < 2000 {
-				lit_cost += 0.7 - (float64(2000-i) / 2000.0 * 0.35)
+				lit_cost += 0.7 - float64(float64(2000-i)/2000.0*0.35)
 			}
 
 			cost[i] = float32(lit_cost)
@@ -0,0 +1,37 @@
+package lz4
+
+// CostModel is a matchfinder.CostModel for the LZ4 Encoder. Since LZ4
+// doesn't use entropy coding, the costs are exact, except that they don't
+// account for the requirement that the end of each block be literals.
+type CostModel struct{}
+
+// LiteralCosts sets the cost of every byte to 8 bits.
+func (CostModel) LiteralCosts(costs *[256]float32, src []byte) {
+	for i := range costs {
+		costs[i] = 8
+	}
+}
+
+func (CostModel) InsertCost(n int) float32 {
+	return float32(extraLengthBytes(n)) * 8
+}
+
+// LengthCost returns the cost of the match length, including the token
+// byte.
+func (CostModel) LengthCost(length int) float32 {
+	return float32(1+extraLengthBytes(length-minMatch)) * 8
+}
+
+// DistanceCost returns 16, since LZ4 always stores the distance in 2 bytes.
+func (CostModel) DistanceCost(distance int, repeat bool) float32 {
+	return 16
+}
+
+// extraLengthBytes returns the number of bytes that appendLength adds for a
+// length of n.
+func extraLengthBytes(n int) int {
+	if n < 15 {
+		return 0
+	}
+	return (n-15)/255 + 1
+}
@@ -0,0 +1,154 @@
+// Package lz4 implements a matchfinder.Encoder for the LZ4 frame format
+// (https://github.com/lz4/lz4/blob/dev/doc/lz4_Frame_format.md).
+package lz4
+
+import (
+	"encoding/binary"
+
+	"github.com/andybalholm/brotli/matchfinder"
+)
+
+const (
+	frameMagic = 0x184D2204
+
+	// flg is the frame descriptor's FLG byte: version 1, linked blocks, and a
+	// content checksum.
+	flg = 1<<6 | 1<<2
+	// bd is the frame descriptor's BD byte: a maximum block size of 4 MB.
+	bd           = 7 << 4
+	maxBlockSize = 4 << 20
+
+	uncompressedBlock = 1 << 31
+
+	minMatch    = 4
+	maxDistance = 65535
+
+	// A match must start at least mfLimit bytes before the end of a block,
+	// and the last lastLiterals bytes must be literals.
+	mfLimit      = 12
+	lastLiterals = 5
+)
+
+// NewEncoder returns a matchfinder.Encoder that produces the LZ4 frame
+// format.
+//
+// The frame uses linked blocks, so matches may refer to data in previous
+// blocks, but distances are limited to 65535 bytes. Matches that are too
+// far back, or shorter than 4 bytes, are stored as literals, as are the
+// bytes at the end of each block that the format requires to be literals.
+func NewEncoder() matchfinder.Encoder {
+	return new(encoder)
+}
+
+type encoder struct {
+	wroteHeader bool
+	checksum    xxh32
+
+	// copies and block are buffers to reduce allocations.
+	copies []absoluteCopy
+	block  []byte
+}
+
+// An absoluteCopy is a match, with its position in the block.
+type absoluteCopy struct {
+	start, end, distance int
+}
+
+func (e *encoder) Reset() {
+	e.wroteHeader = false
+}
+
+func (e *encoder) Encode(dst []byte, src []byte, matches []matchfinder.Match, lastBlock bool) []byte {
+	if !e.wroteHeader {
+		dst = binary.LittleEndian.AppendUint32(dst, frameMagic)
+		descriptor := []byte{flg, bd}
+		dst = append(dst, descriptor...)
+		dst = append(dst, byte(xxh32Sum(descriptor)>>8))
+		e.checksum.reset()
+		e.wroteHeader = true
+	}
+	e.checksum.write(src)
+
+	copies := e.copies[:0]
+	pos := 0
+	for _, m := range matches {
+		pos += m.Unmatched
+		if m.Length > 0 {
+			copies = append(copies, absoluteCopy{start: pos, end: pos + m.Length, distance: m.Distance})
+			pos += m.Length
+		}
+	}
+	e.copies = copies
+
+	for blockStart := 0; blockStart < len(src); blockStart += maxBlockSize {
+		blockEnd := min(blockStart+maxBlockSize, len(src))
+		block := e.block[:0]
+
+		nextEmit := blockStart
+		for len(copies) > 0 && copies[0].start < blockEnd {
+			c := copies[0]
+			if c.end > blockEnd {
+				// Leave the rest of the match for the next block.
+				copies[0].start = blockEnd
+			} else {
+				copies = copies[1:]
+			}
+			start := max(c.start, blockStart)
+			end := min(c.end, blockEnd-lastLiterals)
+			if c.distance > maxDistance || start > blockEnd-mfLimit || end-start < minMatch {
+				continue
+			}
+			block = appendSequence(block, src[nextEmit:start], end-start, c.distance)
+			nextEmit = end
+		}
+		block = appendLastLiterals(block, src[nextEmit:blockEnd])
+		e.block = block
+
+		if len(block) < blockEnd-blockStart {
+			dst = binary.LittleEndian.AppendUint32(dst, uint32(len(block)))
+			dst = append(dst, block...)
+		} else {
+			dst = binary.LittleEndian.AppendUint32(dst, uint32(blockEnd-blockStart)|uncompressedBlock)
+			dst = append(dst, src[blockStart:blockEnd]...)
+		}
+	}
+
+	if lastBlock {
+		dst = binary.LittleEndian.AppendUint32(dst, 0) // EndMark
+		dst = binary.LittleEndian.AppendUint32(dst, e.checksum.sum())
+	}
+	return dst
+}
+
+// appendSequence appends a sequence with the given literals and match to
+// dst.
+func appendSequence(dst, literals []byte, length, distance int) []byte {
+	matchLen := length - minMatch
+	dst = append(dst, byte(min(len(literals), 15)<<4|min(matchLen, 15)))
+	dst = appendLength(dst, len(literals))
+	dst = append(dst, literals...)
+	dst = binary.LittleEndian.AppendUint16(dst, uint16(distance))
+	return appendLength(dst, matchLen)
+}
+
+// appendLastLiterals appends the final sequence of a block, which has only
+// literals, to dst.
+func appendLastLiterals(dst, literals []byte) []byte {
+	dst = append(dst, byte(min(len(literals), 15)<<4))
+	dst = appendLength(dst, len(literals))
+	return append(dst, literals...)
+}
+
+// appendLength appends the extra bytes needed for a literal length or match
+// length of n, if n doesn't fit in the token.
+func appendLength(dst []byte, n int) []byte {
+	if n < 15 {
+		return dst
+	}
+	n -= 15
+	for n >= 255 {
+		dst = append(dst, 255)
+		n -= 255
+	}
+	return append(dst, byte(n))
+}
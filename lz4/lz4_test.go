@@ -0,0 +1,203 @@
+package lz4
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math/rand"
+	"os"
+	"testing"
+
+	"github.com/andybalholm/brotli/matchfinder"
+)
+
+// decode decompresses an LZ4 frame, as produced by the Encoder. Besides
+// decoding the data, it checks the rules in the block format
+// specification that a decoder doesn't strictly need, but that other
+// decoders may rely on: distances are from 1 to 65535, the last match in a
+// block starts at least 12 bytes before the end of the block, and the last
+// 5 bytes of the block are literals.
+func decode(frame []byte) ([]byte, error) {
+	errCorrupt := errors.New("corrupt frame")
+	if len(frame) < 7 || binary.LittleEndian.Uint32(frame) != frameMagic {
+		return nil, errCorrupt
+	}
+	if frame[4] != flg || frame[5] != bd || frame[6] != byte(xxh32Sum(frame[4:6])>>8) {
+		return nil, errors.New("unexpected frame descriptor")
+	}
+	frame = frame[7:]
+
+	var out []byte
+	for {
+		if len(frame) < 4 {
+			return nil, errCorrupt
+		}
+		size := binary.LittleEndian.Uint32(frame)
+		frame = frame[4:]
+		if size == 0 {
+			break
+		}
+		n := int(size &^ uncompressedBlock)
+		if len(frame) < n || n > maxBlockSize {
+			return nil, errCorrupt
+		}
+		block := frame[:n]
+		frame = frame[n:]
+		if size&uncompressedBlock != 0 {
+			out = append(out, block...)
+			continue
+		}
+
+		readLength := func(n int) int {
+			if n == 15 {
+				for len(block) > 0 {
+					b := block[0]
+					block = block[1:]
+					n += int(b)
+					if b != 255 {
+						break
+					}
+				}
+			}
+			return n
+		}
+		blockStart := len(out)
+		lastMatchStart, lastMatchEnd := -1, 0
+		for {
+			if len(block) == 0 {
+				// The last sequence must have a token, even with no literals.
+				return nil, errCorrupt
+			}
+			token := block[0]
+			block = block[1:]
+			litLen := readLength(int(token >> 4))
+			if len(block) < litLen {
+				return nil, errCorrupt
+			}
+			out = append(out, block[:litLen]...)
+			block = block[litLen:]
+			if len(block) == 0 {
+				break
+			}
+			if len(block) < 2 {
+				return nil, errCorrupt
+			}
+			distance := int(binary.LittleEndian.Uint16(block))
+			block = block[2:]
+			matchLen := readLength(int(token&15)) + minMatch
+			if distance == 0 || distance > len(out) {
+				return nil, errCorrupt
+			}
+			lastMatchStart = len(out) - blockStart
+			for range matchLen {
+				out = append(out, out[len(out)-distance])
+			}
+			lastMatchEnd = len(out) - blockStart
+		}
+
+		blockLen := len(out) - blockStart
+		if blockLen > maxBlockSize {
+			return nil, errors.New("block too large")
+		}
+		if lastMatchStart >= 0 && (lastMatchStart > blockLen-mfLimit || lastMatchEnd > blockLen-lastLiterals) {
+			return nil, errors.New("match too close to the end of the block")
+		}
+	}
+
+	if len(frame) != 4 || binary.LittleEndian.Uint32(frame) != xxh32Sum(out) {
+		return nil, errors.New("bad content checksum")
+	}
+	return out, nil
+}
+
+func TestXXH32(t *testing.T) {
+	for _, c := range []struct {
+		data string
+		sum  uint32
+	}{
+		{"", 0x02CC5D05},
+		{"a", 0x550D7456},
+		{"abc", 0x32D153FF},
+		{"Nobody inspects the spammish repetition", 0xE2293B2F},
+	} {
+		if got := xxh32Sum([]byte(c.data)); got != c.sum {
+			t.Errorf("xxh32(%q) = %#x, want %#x", c.data, got, c.sum)
+		}
+	}
+}
+
+func testData(t *testing.T) [][]byte {
+	text, err := os.ReadFile("../testdata/Isaac.Newton-Opticks.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	random := make([]byte, 100000)
+	rand.New(rand.NewSource(1)).Read(random)
+	return [][]byte{text, random, make([]byte, 100000), nil, []byte("a"), bytes.Repeat([]byte("ab"), 20)}
+}
+
+func TestWriterLevels(t *testing.T) {
+	for _, data := range testData(t) {
+		for level := 1; level < 10; level++ {
+			b := new(bytes.Buffer)
+			w := NewWriter(b, level)
+			w.Write(data)
+			w.Close()
+			decompressed, err := decode(b.Bytes())
+			if err != nil {
+				t.Fatalf("error decompressing level %d: %v", level, err)
+			}
+			if !bytes.Equal(decompressed, data) {
+				t.Fatalf("decompressed output doesn't match on level %d", level)
+			}
+		}
+	}
+}
+
+func TestLongDistanceMatches(t *testing.T) {
+	// With a MaxDistance and BlockSize larger than LZ4 supports, some
+	// matches must be stored as literals, and the block must be split.
+	data, err := os.ReadFile("../testdata/Isaac.Newton-Opticks.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = bytes.Repeat(data, 8)
+	b := new(bytes.Buffer)
+	w := &matchfinder.Writer{
+		Dest:        b,
+		MatchFinder: &matchfinder.ZFast{MaxDistance: 1 << 20},
+		Encoder:     NewEncoder(),
+		BlockSize:   len(data),
+	}
+	w.Write(data)
+	w.Close()
+	decompressed, err := decode(b.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decompressed, data) {
+		t.Fatal("decompressed output doesn't match")
+	}
+}
+
+func BenchmarkWriterLevels(b *testing.B) {
+	data, err := os.ReadFile("../testdata/Isaac.Newton-Opticks.txt")
+	if err != nil {
+		b.Fatal(err)
+	}
+	for level := 1; level < 10; level++ {
+		b.Run(string(rune('0'+level)), func(b *testing.B) {
+			buf := new(bytes.Buffer)
+			w := NewWriter(buf, level)
+			b.SetBytes(int64(len(data)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				buf.Reset()
+				w.Reset(buf)
+				w.Write(data)
+				w.Close()
+			}
+			b.ReportMetric(float64(len(data))/float64(buf.Len()), "ratio")
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package lz4
+
+import (
+	"io"
+
+	"github.com/andybalholm/brotli/matchfinder"
+)
+
+// NewWriter returns a new matchfinder.Writer that compresses data at the
+// given level, in the LZ4 frame format. Levels 1–9 are available; levels
+// outside this range will be replaced with the closest level available.
+func NewWriter(w io.Writer, level int) *matchfinder.Writer {
+	var mf matchfinder.MatchFinder
+	switch {
+	case level < 2:
+		mf = &matchfinder.ZFast{MaxDistance: maxDistance}
+	case level == 2:
+		mf = &matchfinder.ZDFast{MaxDistance: maxDistance}
+	case level == 3:
+		mf = &matchfinder.ZM{MaxDistance: maxDistance}
+	case level == 4:
+		mf = &matchfinder.Trio{MaxDistance: maxDistance}
+	case level < 8:
+		chainLen := 32
+		switch level {
+		case 5:
+			chainLen = 8
+		case 6:
+			chainLen = 16
+		}
+		mf = &matchfinder.M4{
+			MaxDistance: maxDistance,
+			ChainLength: chainLen,
+			HashLen:     5,
+		}
+	case level == 8:
+		mf = &matchfinder.BinaryTree{
+			MaxDistance: maxDistance,
+			MinLength:   minMatch,
+			MaxDepth:    8,
+			CostModel:   CostModel{},
+		}
+	default:
+		mf = &matchfinder.BinaryTree{
+			MaxDistance: maxDistance,
+			MinLength:   minMatch,
+			CostModel:   CostModel{},
+		}
+	}
+
+	return &matchfinder.Writer{
+		Dest:        w,
+		MatchFinder: mf,
+		Encoder:     NewEncoder(),
+		BlockSize:   1 << 16,
+	}
+}
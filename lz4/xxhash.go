@@ -0,0 +1,99 @@
+package lz4
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+const (
+	prime1 uint32 = 2654435761
+	prime2 uint32 = 2246822519
+	prime3 uint32 = 3266489917
+	prime4 uint32 = 668265263
+	prime5 uint32 = 374761393
+)
+
+// xxh32 computes the 32-bit xxHash of a stream of data, with a seed of 0.
+// It is used for the checksums in the frame format.
+type xxh32 struct {
+	v     [4]uint32
+	total uint64
+	buf   [16]byte
+	n     int
+}
+
+func (x *xxh32) reset() {
+	p1 := prime1 // a variable, so that the arithmetic wraps around
+	x.v = [4]uint32{p1 + prime2, prime2, 0, -p1}
+	x.total = 0
+	x.n = 0
+}
+
+func xxh32Round(acc, input uint32) uint32 {
+	return bits.RotateLeft32(acc+input*prime2, 13) * prime1
+}
+
+func (x *xxh32) stripe(b []byte) {
+	x.v[0] = xxh32Round(x.v[0], binary.LittleEndian.Uint32(b[0:]))
+	x.v[1] = xxh32Round(x.v[1], binary.LittleEndian.Uint32(b[4:]))
+	x.v[2] = xxh32Round(x.v[2], binary.LittleEndian.Uint32(b[8:]))
+	x.v[3] = xxh32Round(x.v[3], binary.LittleEndian.Uint32(b[12:]))
+}
+
+func (x *xxh32) write(b []byte) {
+	x.total += uint64(len(b))
+
+	if x.n > 0 {
+		c := copy(x.buf[x.n:], b)
+		x.n += c
+		b = b[c:]
+		if x.n < len(x.buf) {
+			return
+		}
+		x.stripe(x.buf[:])
+		x.n = 0
+	}
+
+	for len(b) >= 16 {
+		x.stripe(b)
+		b = b[16:]
+	}
+	x.n = copy(x.buf[:], b)
+}
+
+func (x *xxh32) sum() uint32 {
+	var h uint32
+	if x.total >= 16 {
+		h = bits.RotateLeft32(x.v[0], 1) + bits.RotateLeft32(x.v[1], 7) +
+			bits.RotateLeft32(x.v[2], 12) + bits.RotateLeft32(x.v[3], 18)
+	} else {
+		h = prime5
+	}
+	h += uint32(x.total)
+
+	b := x.buf[:x.n]
+	for len(b) >= 4 {
+		h += binary.LittleEndian.Uint32(b) * prime3
+		h = bits.RotateLeft32(h, 17) * prime4
+		b = b[4:]
+	}
+	for _, c := range b {
+		h += uint32(c) * prime5
+		h = bits.RotateLeft32(h, 11) * prime1
+	}
+
+	h ^= h >> 15
+	h *= prime2
+	h ^= h >> 13
+	h *= prime3
+	h ^= h >> 16
+	return h
+}
+
+// xxh32Sum returns the 32-bit xxHash of b.
+func xxh32Sum(b []byte) uint32 {
+	var x xxh32
+	x.reset()
+	x.write(b)
+	return x.sum()
+}
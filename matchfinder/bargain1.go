@@ -2,8 +2,6 @@ package matchfinder
 
 import (
 	"encoding/binary"
-	"math"
-	"math/bits"
 	"slices"
 )
 
@@ -21,8 +19,13 @@ type Bargain1 struct {
 	// byte (to increase speed but decrease compression).
 	Skip bool
 
-	history []byte
-	table6  [bargain1TableSize]tableEntry
+	// CostModel estimates the number of bits needed to encode literals and
+	// matches. The default is a generic model based on the logarithms of the
+	// lengths and distances.
+	CostModel CostModel
+
+	h      history
+	table6 [bargain1TableSize]tableEntry
 
 	// holding onto buffers to reduce allocations:
 
@@ -32,7 +35,7 @@ type Bargain1 struct {
 
 func (z *Bargain1) Reset() {
 	z.table6 = [bargain1TableSize]tableEntry{}
-	z.history = z.history[:0]
+	z.h.reset()
 }
 
 func (z *Bargain1) FindMatches(dst []Match, src []byte) []Match {
@@ -40,15 +43,12 @@ func (z *Bargain1) FindMatches(dst []Match, src []byte) []Match {
 		z.MaxDistance = 1 << 16
 	}
 
-	var histogram [256]uint32
-	for _, b := range src {
-		histogram[b]++
+	costs := z.CostModel
+	if costs == nil {
+		costs = defaultCostModel{}
 	}
 	var byteCost [256]float32
-	for b, n := range histogram {
-		cost := max(math.Log2(float64(len(src))/float64(n)), 1)
-		byteCost[b] = float32(cost)
-	}
+	costs.LiteralCosts(&byteCost, src)
 
 	// Each element in arrivals corresponds to the position just after
 	// the corresponding byte in src.
@@ -63,38 +63,20 @@ func (z *Bargain1) FindMatches(dst []Match, src []byte) []Match {
 		}
 	}
 
-	if len(z.history) > z.MaxDistance*2 {
-		delta := len(z.history) - z.MaxDistance
-		copy(z.history, z.history[delta:])
-		z.history = z.history[:z.MaxDistance]
-
-		for i := range z.table6 {
-			v := z.table6[i].offset
-			v -= int32(delta)
-			if v < 0 {
-				z.table6[i] = tableEntry{}
-			} else {
-				z.table6[i].offset = v
-			}
-		}
-	}
+	z.h.trim(z.MaxDistance)
 
-	historyLen := len(z.history)
-	z.history = append(z.history, src...)
-	src = z.history
+	historyLen := len(z.h.buf)
+	z.h.buf = append(z.h.buf, src...)
+	src = z.h.buf
 
 	addMatch := func(m absoluteMatch, unmatched int, repeat bool) {
 		var startCost float32
 		if m.Start > historyLen {
 			startCost = arrivals[m.Start-historyLen-1].cost
 		}
-		insertCost := float32(bits.Len(uint(unmatched)))
-		var distanceCost float32
-		if !repeat {
-			distanceCost = float32(bits.Len(uint(m.Start - m.Match)))
-		}
-		cost := startCost + baseMatchCost + insertCost + distanceCost
+		matchCost := costs.InsertCost(unmatched) + costs.DistanceCost(m.Start-m.Match, repeat)
 		for j := m.End; j >= m.Start+3; j-- {
+			cost := startCost + costs.LengthCost(j-m.Start) + matchCost
 			a := &arrivals[j-historyLen-1]
 			if a.cost > 0 && a.cost <= cost {
 				break
@@ -140,9 +122,9 @@ func (z *Bargain1) FindMatches(dst []Match, src []byte) []Match {
 
 		cv := binary.LittleEndian.Uint64(src[i:])
 		nextHash6 := z.hash6(cv)
-		candidate6 := z.table6[nextHash6]
+		candidate6 := z.h.lookup(z.table6[nextHash6])
 
-		entry := tableEntry{offset: int32(i), val: uint32(cv)}
+		entry := z.h.entry(int32(i), uint32(cv))
 		z.table6[nextHash6] = entry
 
 		// Look for a repeat match, unless there is no previous distance, or a match at
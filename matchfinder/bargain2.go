@@ -2,8 +2,6 @@ package matchfinder
 
 import (
 	"encoding/binary"
-	"math"
-	"math/bits"
 	"slices"
 )
 
@@ -21,9 +19,14 @@ type Bargain2 struct {
 	// byte (to increase speed but decrease compression).
 	Skip bool
 
-	history []byte
-	table5  [bargain2TableSize]tableEntry
-	table8  [bargain2TableSize]tableEntry
+	// CostModel estimates the number of bits needed to encode literals and
+	// matches. The default is a generic model based on the logarithms of the
+	// lengths and distances.
+	CostModel CostModel
+
+	h      history
+	table5 [bargain2TableSize]tableEntry
+	table8 [bargain2TableSize]tableEntry
 
 	// holding onto buffers to reduce allocations:
 
@@ -34,7 +37,7 @@ type Bargain2 struct {
 func (z *Bargain2) Reset() {
 	z.table5 = [bargain2TableSize]tableEntry{}
 	z.table8 = [bargain2TableSize]tableEntry{}
-	z.history = z.history[:0]
+	z.h.reset()
 }
 
 func (z *Bargain2) FindMatches(dst []Match, src []byte) []Match {
@@ -42,15 +45,12 @@ func (z *Bargain2) FindMatches(dst []Match, src []byte) []Match {
 		z.MaxDistance = 1 << 16
 	}
 
-	var histogram [256]uint32
-	for _, b := range src {
-		histogram[b]++
+	costs := z.CostModel
+	if costs == nil {
+		costs = defaultCostModel{}
 	}
 	var byteCost [256]float32
-	for b, n := range histogram {
-		cost := max(math.Log2(float64(len(src))/float64(n)), 1)
-		byteCost[b] = float32(cost)
-	}
+	costs.LiteralCosts(&byteCost, src)
 
 	// Each element in arrivals corresponds to the position just after
 	// the corresponding byte in src.
@@ -65,47 +65,20 @@ func (z *Bargain2) FindMatches(dst []Match, src []byte) []Match {
 		}
 	}
 
-	if len(z.history) > z.MaxDistance*2 {
-		delta := len(z.history) - z.MaxDistance
-		copy(z.history, z.history[delta:])
-		z.history = z.history[:z.MaxDistance]
-
-		for i := range z.table5 {
-			v := z.table5[i].offset
-			v -= int32(delta)
-			if v < 0 {
-				z.table5[i] = tableEntry{}
-			} else {
-				z.table5[i].offset = v
-			}
-		}
-		for i := range z.table8 {
-			v := z.table8[i].offset
-			v -= int32(delta)
-			if v < 0 {
-				z.table8[i] = tableEntry{}
-			} else {
-				z.table8[i].offset = v
-			}
-		}
-	}
+	z.h.trim(z.MaxDistance)
 
-	historyLen := len(z.history)
-	z.history = append(z.history, src...)
-	src = z.history
+	historyLen := len(z.h.buf)
+	z.h.buf = append(z.h.buf, src...)
+	src = z.h.buf
 
 	addMatch := func(m absoluteMatch, unmatched int, repeat bool) {
 		var startCost float32
 		if m.Start > historyLen {
 			startCost = arrivals[m.Start-historyLen-1].cost
 		}
-		insertCost := float32(bits.Len(uint(unmatched)))
-		var distanceCost float32
-		if !repeat {
-			distanceCost = float32(bits.Len(uint(m.Start - m.Match)))
-		}
-		cost := startCost + baseMatchCost + insertCost + distanceCost
+		matchCost := costs.InsertCost(unmatched) + costs.DistanceCost(m.Start-m.Match, repeat)
 		for j := m.End; j >= m.Start+3; j-- {
+			cost := startCost + costs.LengthCost(j-m.Start) + matchCost
 			a := &arrivals[j-historyLen-1]
 			if a.cost > 0 && a.cost <= cost {
 				break
@@ -152,10 +125,10 @@ func (z *Bargain2) FindMatches(dst []Match, src []byte) []Match {
 		cv := binary.LittleEndian.Uint64(src[i:])
 		nextHash5 := z.hash5(cv)
 		nextHash8 := z.hash8(cv)
-		candidate5 := z.table5[nextHash5]
-		candidate8 := z.table8[nextHash8]
+		candidate5 := z.h.lookup(z.table5[nextHash5])
+		candidate8 := z.h.lookup(z.table8[nextHash8])
 
-		entry := tableEntry{offset: int32(i), val: uint32(cv)}
+		entry := z.h.entry(int32(i), uint32(cv))
 		z.table5[nextHash5] = entry
 		z.table8[nextHash8] = entry
 
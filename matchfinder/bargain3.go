@@ -2,8 +2,6 @@ package matchfinder
 
 import (
 	"encoding/binary"
-	"math"
-	"math/bits"
 	"slices"
 )
 
@@ -16,7 +14,12 @@ type Bargain3 struct {
 	// byte (to increase speed but decrease compression).
 	Skip bool
 
-	history []byte
+	// CostModel estimates the number of bits needed to encode literals and
+	// matches. The default is a generic model based on the logarithms of the
+	// lengths and distances.
+	CostModel CostModel
+
+	h       history
 	table5  [1 << 17]tableEntry
 	table8  [1 << 18]tableEntry
 	table12 [1 << 19]tableEntry
@@ -31,7 +34,7 @@ func (z *Bargain3) Reset() {
 	z.table5 = [len(z.table5)]tableEntry{}
 	z.table8 = [len(z.table8)]tableEntry{}
 	z.table12 = [len(z.table12)]tableEntry{}
-	z.history = z.history[:0]
+	z.h.reset()
 }
 
 func (z *Bargain3) FindMatches(dst []Match, src []byte) []Match {
@@ -39,15 +42,12 @@ func (z *Bargain3) FindMatches(dst []Match, src []byte) []Match {
 		z.MaxDistance = 1 << 16
 	}
 
-	var histogram [256]uint32
-	for _, b := range src {
-		histogram[b]++
+	costs := z.CostModel
+	if costs == nil {
+		costs = defaultCostModel{}
 	}
 	var byteCost [256]float32
-	for b, n := range histogram {
-		cost := max(math.Log2(float64(len(src))/float64(n)), 1)
-		byteCost[b] = float32(cost)
-	}
+	costs.LiteralCosts(&byteCost, src)
 
 	// Each element in arrivals corresponds to the position just after
 	// the corresponding byte in src.
@@ -62,56 +62,20 @@ func (z *Bargain3) FindMatches(dst []Match, src []byte) []Match {
 		}
 	}
 
-	if len(z.history) > z.MaxDistance*2 {
-		delta := len(z.history) - z.MaxDistance
-		copy(z.history, z.history[delta:])
-		z.history = z.history[:z.MaxDistance]
-
-		for i := range z.table5 {
-			v := z.table5[i].offset
-			v -= int32(delta)
-			if v < 0 {
-				z.table5[i] = tableEntry{}
-			} else {
-				z.table5[i].offset = v
-			}
-		}
-		for i := range z.table8 {
-			v := z.table8[i].offset
-			v -= int32(delta)
-			if v < 0 {
-				z.table8[i] = tableEntry{}
-			} else {
-				z.table8[i].offset = v
-			}
-		}
-		for i := range z.table12 {
-			v := z.table12[i].offset
-			v -= int32(delta)
-			if v < 0 {
-				z.table12[i] = tableEntry{}
-			} else {
-				z.table12[i].offset = v
-			}
-		}
-	}
+	z.h.trim(z.MaxDistance)
 
-	historyLen := len(z.history)
-	z.history = append(z.history, src...)
-	src = z.history
+	historyLen := len(z.h.buf)
+	z.h.buf = append(z.h.buf, src...)
+	src = z.h.buf
 
 	addMatch := func(m absoluteMatch, unmatched int, repeat bool) {
 		var startCost float32
 		if m.Start > historyLen {
 			startCost = arrivals[m.Start-historyLen-1].cost
 		}
-		insertCost := float32(bits.Len(uint(unmatched)))
-		var distanceCost float32
-		if !repeat {
-			distanceCost = float32(bits.Len(uint(m.Start - m.Match)))
-		}
-		cost := startCost + baseMatchCost + insertCost + distanceCost
+		matchCost := costs.InsertCost(unmatched) + costs.DistanceCost(m.Start-m.Match, repeat)
 		for j := m.End; j >= m.Start+3; j-- {
+			cost := startCost + costs.LengthCost(j-m.Start) + matchCost
 			a := &arrivals[j-historyLen-1]
 			if a.cost > 0 && a.cost <= cost {
 				break
@@ -160,11 +124,11 @@ func (z *Bargain3) FindMatches(dst []Match, src []byte) []Match {
 		nextHash5 := z.hash5(cv)
 		nextHash8 := z.hash8(cv)
 		nextHash12 := z.hash12(cv, extra)
-		candidate5 := z.table5[nextHash5]
-		candidate8 := z.table8[nextHash8]
-		candidate12 := z.table12[nextHash12]
+		candidate5 := z.h.lookup(z.table5[nextHash5])
+		candidate8 := z.h.lookup(z.table8[nextHash8])
+		candidate12 := z.h.lookup(z.table12[nextHash12])
 
-		entry := tableEntry{offset: int32(i), val: uint32(cv)}
+		entry := z.h.entry(int32(i), uint32(cv))
 		z.table5[nextHash5] = entry
 		z.table8[nextHash8] = entry
 		z.table12[nextHash12] = entry
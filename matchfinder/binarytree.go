@@ -0,0 +1,377 @@
+package matchfinder
+
+import (
+	"encoding/binary"
+	"math"
+	"math/bits"
+	"slices"
+)
+
+const (
+	// btMaxCompareLength is how many bytes are compared when sorting
+	// positions into the binary trees.
+	btMaxCompareLength = 128
+
+	// btLongMatch is the length of a match that is long enough that
+	// BinaryTree doesn't search for other matches inside it.
+	btLongMatch = 325
+)
+
+// BinaryTree is a MatchFinder that uses binary trees to find matches, like
+// the H10 hasher in the Brotli reference encoder, and a shortest-path
+// optimizer to choose which matches to use.
+//
+// Each hash bucket holds a binary tree of the previous positions with that
+// hash, sorted by the bytes that follow them. This lets BinaryTree find
+// all the candidate matches at each position (the longest match at each
+// distance that is longer than any match at a shorter distance) with a
+// short walk down the tree, so it can search much larger windows than the
+// hash-chain MatchFinders. It uses 8 bytes of memory per byte of window,
+// plus 4 bytes per hash table entry.
+type BinaryTree struct {
+	// MaxDistance is the maximum distance (in bytes) to look back for
+	// a match. The default is 65535.
+	MaxDistance int
+
+	// MinLength is the length of the shortest match to return.
+	// The default is 4.
+	MinLength int
+
+	// TableBits is the number of bits in the hash table indexes.
+	// The default is 17 (128K entries).
+	TableBits int
+
+	// MaxDepth is how many tree nodes to visit when searching for
+	// matches at each position. The default is 64.
+	MaxDepth int
+
+	// CostModel estimates the number of bits needed to encode literals and
+	// matches. The default is a generic model based on the logarithms of the
+	// lengths and distances.
+	CostModel CostModel
+
+	// The tables store positions in the stream (not in history), plus one,
+	// so that zero means an empty entry.
+	table []uint32
+	// forest holds the left and right child of each position's node,
+	// indexed by 2*(position & windowMask).
+	forest     []uint32
+	windowMask int
+
+	history []byte
+	// base is the offset in the stream of history[0].
+	base int
+	// inserted is the offset in the stream of the first position that has
+	// not been added to the trees yet.
+	inserted int
+
+	// holding onto buffers to reduce allocations:
+
+	arrivals   []arrival
+	candidates []Match
+	matches    []Match
+}
+
+func (t *BinaryTree) Reset() {
+	clear(t.table)
+	t.history = t.history[:0]
+	t.base = 0
+	t.inserted = 0
+}
+
+func (t *BinaryTree) FindMatches(dst []Match, src []byte) []Match {
+	if t.MaxDistance == 0 {
+		t.MaxDistance = 65535
+	}
+	if t.MinLength == 0 {
+		t.MinLength = 4
+	}
+	if t.TableBits == 0 {
+		t.TableBits = 17
+	}
+	if t.MaxDepth == 0 {
+		t.MaxDepth = 64
+	}
+	if len(t.table) < 1<<t.TableBits {
+		t.table = make([]uint32, 1<<t.TableBits)
+	}
+	if t.forest == nil {
+		windowSize := 1 << bits.Len(uint(t.MaxDistance))
+		t.forest = make([]uint32, 2*windowSize)
+		t.windowMask = windowSize - 1
+	}
+
+	costs := t.CostModel
+	if costs == nil {
+		costs = defaultCostModel{shortMatchPenalty: true}
+	}
+	var byteCost [256]float32
+	costs.LiteralCosts(&byteCost, src)
+
+	// Each element in arrivals corresponds to the position just after
+	// the corresponding byte in src.
+	arrivals := t.arrivals
+	if len(arrivals) < len(src) {
+		arrivals = make([]arrival, len(src))
+		t.arrivals = arrivals
+	} else {
+		arrivals = arrivals[:len(src)]
+		clear(arrivals)
+	}
+
+	if len(t.history) > t.MaxDistance*2 {
+		// Trim down the history buffer. The trees use stream positions, so
+		// they don't need to be adjusted.
+		delta := len(t.history) - t.MaxDistance
+		copy(t.history, t.history[delta:])
+		t.history = t.history[:t.MaxDistance]
+		t.base += delta
+	}
+	if uint64(t.base+len(t.history)+len(src)) >= math.MaxUint32-1 {
+		t.rebase()
+	}
+
+	// Append src to the history buffer.
+	historyLen := len(t.history)
+	t.history = append(t.history, src...)
+	src = t.history
+
+	// Add the positions at the end of the previous block that didn't have
+	// enough data after them to be sorted into the trees.
+	t.inserted = max(t.inserted, t.base)
+	for i := t.inserted - t.base; i < historyLen && len(src)-i >= btMaxCompareLength; i++ {
+		if i <= len(src)-4 {
+			t.search(nil, i, true)
+		}
+		t.inserted++
+	}
+
+	skipUntil := historyLen
+	for i := historyLen; i < len(src); i++ {
+		var arrivedHere arrival
+		if i > historyLen {
+			arrivedHere = arrivals[i-historyLen-1]
+		}
+
+		unmatched := 0
+		if arrivedHere.distance == 0 {
+			unmatched = int(arrivedHere.length)
+		}
+		prevDistance := 0
+		if i-unmatched > historyLen {
+			prevDistance = int(arrivals[i-historyLen-1-unmatched].distance)
+		}
+
+		literalCost := byteCost[src[i]]
+		nextArrival := &arrivals[i-historyLen]
+		if nextArrival.cost == 0 || arrivedHere.cost+literalCost < nextArrival.cost {
+			*nextArrival = arrival{
+				cost:   arrivedHere.cost + literalCost,
+				length: uint32(unmatched + 1),
+			}
+		}
+
+		if i > len(src)-4 {
+			continue
+		}
+		insert := len(src)-i >= btMaxCompareLength && t.base+i == t.inserted
+		if insert {
+			t.inserted++
+		}
+		if i < skipUntil {
+			// We're inside a long match, so just add this position to the tree.
+			if insert {
+				t.search(nil, i, true)
+			}
+			continue
+		}
+		candidates := t.search(t.candidates[:0], i, insert)
+		t.candidates = candidates
+
+		// addMatch records the cost of reaching each position from i+minLength
+		// to i+length with a match at the given distance, working backward
+		// until it reaches positions that already have a cheaper arrival.
+		addMatch := func(minLength, length, distance int) {
+			matchCost := costs.InsertCost(unmatched) + costs.DistanceCost(distance, distance == prevDistance)
+			for j := length; j >= minLength; j-- {
+				adjustedCost := matchCost + costs.LengthCost(j)
+				a := &arrivals[i+j-historyLen-1]
+				if a.cost != 0 && a.cost <= arrivedHere.cost+adjustedCost {
+					break
+				}
+				*a = arrival{
+					length:   uint32(j),
+					distance: uint32(distance),
+					cost:     arrivedHere.cost + adjustedCost,
+				}
+			}
+		}
+
+		longest := 0
+		if prevDistance != 0 && i-prevDistance >= 0 {
+			// Try a repeat match with the distance of the last match.
+			length := extendMatch(src, i-prevDistance, i) - i
+			if length >= t.MinLength {
+				addMatch(t.MinLength, length, prevDistance)
+				longest = length
+			}
+		}
+
+		// Each candidate is longer than the one before it, and it is the
+		// closest match for the lengths between the two.
+		shorter := 0
+		for _, c := range candidates {
+			if c.Length >= t.MinLength {
+				addMatch(max(shorter+1, t.MinLength), c.Length, c.Distance)
+			}
+			shorter = c.Length
+		}
+		longest = max(longest, shorter)
+
+		if longest >= btLongMatch {
+			skipUntil = i + longest
+		}
+	}
+
+	// We've found the shortest path; now walk it backward and store the matches.
+	matches := t.matches[:0]
+	i := len(arrivals) - 1
+	for i >= 0 {
+		a := arrivals[i]
+		if a.distance > 0 {
+			matches = append(matches, Match{
+				Length:   int(a.length),
+				Distance: int(a.distance),
+			})
+			i -= int(a.length)
+		} else {
+			if len(matches) == 0 {
+				matches = append(matches, Match{})
+			}
+			matches[len(matches)-1].Unmatched = int(a.length)
+			i -= int(a.length)
+		}
+	}
+	t.matches = matches
+
+	slices.Reverse(matches)
+
+	return append(dst, matches...)
+}
+
+// search walks the binary tree for the hash of the four bytes at
+// t.history[i], and appends the matches it finds to dst, in order of
+// increasing length (and distance). If insert is true, it also makes i
+// the root of the tree, splitting the old tree into its left and right
+// subtrees; this requires at least btMaxCompareLength bytes of history
+// after i.
+func (t *BinaryTree) search(dst []Match, i int, insert bool) []Match {
+	h := t.history
+	pos := t.base + i
+	maxLength := len(h) - i
+	compareLength := min(maxLength, btMaxCompareLength)
+
+	key := (binary.LittleEndian.Uint32(h[i:]) * 0x1E35A7BD) >> (32 - t.TableBits)
+	prev := int(t.table[key]) - 1
+	if insert {
+		t.table[key] = uint32(pos + 1)
+	}
+
+	// nodeLeft and nodeRight are the forest entries where the next nodes
+	// found to sort before and after i will be linked.
+	nodeLeft := 2 * (pos & t.windowMask)
+	nodeRight := nodeLeft + 1
+	bestLeft, bestRight := 0, 0
+	bestLength := 0
+
+	for depth := t.MaxDepth; ; depth-- {
+		distance := pos - prev
+		if prev < t.base || distance > t.MaxDistance || depth == 0 {
+			if insert {
+				t.forest[nodeLeft] = 0
+				t.forest[nodeRight] = 0
+			}
+			break
+		}
+
+		candidate := prev - t.base
+		length := min(bestLeft, bestRight)
+		length += matchLength(h[i+length:i+compareLength], h[candidate+length:])
+		if length == btMaxCompareLength {
+			length = extendMatch(h, candidate+length, i+length) - i
+		}
+		if length > bestLength {
+			bestLength = length
+			dst = append(dst, Match{Length: length, Distance: distance})
+		}
+
+		prevNode := 2 * (prev & t.windowMask)
+		if length >= compareLength {
+			// The candidate is equal to i as far as the tree is sorted, so i
+			// takes its place, and inherits its children.
+			if insert {
+				t.forest[nodeLeft] = t.forest[prevNode]
+				t.forest[nodeRight] = t.forest[prevNode+1]
+			}
+			break
+		}
+
+		if h[i+length] > h[candidate+length] {
+			bestLeft = length
+			if insert {
+				t.forest[nodeLeft] = uint32(prev + 1)
+			}
+			nodeLeft = prevNode + 1
+			prev = int(t.forest[nodeLeft]) - 1
+		} else {
+			bestRight = length
+			if insert {
+				t.forest[nodeRight] = uint32(prev + 1)
+			}
+			nodeRight = prevNode
+			prev = int(t.forest[nodeRight]) - 1
+		}
+	}
+
+	return dst
+}
+
+// rebase reduces the stream positions stored in the trees, to keep them
+// from overflowing 32 bits. They are reduced by a multiple of the window
+// size, so that each position keeps its place in t.forest.
+func (t *BinaryTree) rebase() {
+	delta := t.base &^ t.windowMask
+	adjust := func(s []uint32) {
+		for i, v := range s {
+			if int(v)-1 < delta {
+				s[i] = 0
+			} else {
+				s[i] = v - uint32(delta)
+			}
+		}
+	}
+	adjust(t.table)
+	adjust(t.forest)
+	t.base -= delta
+	t.inserted -= delta
+}
+
+// matchLength returns the number of bytes at the start of a that match the
+// corresponding bytes in b.
+func matchLength(a, b []byte) int {
+	n := 0
+	for len(a) >= 8 && len(b) >= 8 {
+		if diff := binary.LittleEndian.Uint64(a) ^ binary.LittleEndian.Uint64(b); diff != 0 {
+			return n + bits.TrailingZeros64(diff)/8
+		}
+		n += 8
+		a, b = a[8:], b[8:]
+	}
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			break
+		}
+		n++
+	}
+	return n
+}
@@ -0,0 +1,138 @@
+package matchfinder
+
+import "fmt"
+
+// Checked is a MatchFinder that verifies the matches returned by another
+// MatchFinder. It is meant for testing and debugging MatchFinders.
+//
+// It checks that the matches cover the block exactly, that only the last
+// match in a block has a Length of zero, that each distance is within the
+// data seen so far (and within MaxDistance, if it is set), and that the
+// bytes really match.
+//
+// When a block has an invalid match, Checked records the error in Err and
+// replaces the block's matches with a single run of literals, so that the
+// output is still correct.
+type Checked struct {
+	MatchFinder MatchFinder
+
+	// MaxDistance is the maximum distance that a match may have.
+	// If it is zero, any distance within the data seen so far is allowed,
+	// and Checked keeps the entire stream in memory.
+	MaxDistance int
+
+	// Panic is whether to panic with the *MatchError instead of recording it
+	// in Err.
+	Panic bool
+
+	// Err is the first error found since the last call to Reset.
+	Err error
+
+	h      history
+	blocks int
+}
+
+// A MatchError describes an invalid Match returned by a MatchFinder.
+type MatchError struct {
+	Block    int    // the index of the block (the number of previous calls to FindMatches)
+	Index    int    // the index of the Match in the block's matches
+	Position int    // the position in the block where the match's Unmatched bytes start
+	Match    Match  // the invalid Match
+	Problem  string // a description of what is wrong with it
+}
+
+func (e *MatchError) Error() string {
+	return fmt.Sprintf("matchfinder: invalid match %+v (index %d at position %d in block %d): %s",
+		e.Match, e.Index, e.Position, e.Block, e.Problem)
+}
+
+func (c *Checked) Reset() {
+	c.MatchFinder.Reset()
+	c.h.reset()
+	c.blocks = 0
+	c.Err = nil
+}
+
+func (c *Checked) FindMatches(dst []Match, src []byte) []Match {
+	start := len(dst)
+	dst = c.MatchFinder.FindMatches(dst, src)
+
+	if c.MaxDistance > 0 {
+		c.h.trim(c.MaxDistance)
+	}
+	historyLen := len(c.h.buf)
+	c.h.buf = append(c.h.buf, src...)
+
+	err := checkMatches(dst[start:], c.h.buf, historyLen, c.MaxDistance, c.blocks)
+	c.blocks++
+	if err == nil {
+		return dst
+	}
+
+	if c.Panic {
+		panic(err)
+	}
+	if c.Err == nil {
+		c.Err = err
+	}
+	return append(dst[:start], Match{Unmatched: len(src)})
+}
+
+// checkMatches verifies the matches for the block that starts at
+// h[historyLen] and runs to the end of h. maxDistance is the maximum
+// distance allowed (or 0 for no limit), and block is the block's index,
+// for the MatchError.
+func checkMatches(matches []Match, h []byte, historyLen, maxDistance, block int) *MatchError {
+	pos := historyLen
+	for i, m := range matches {
+		fail := func(format string, args ...any) *MatchError {
+			return &MatchError{
+				Block:    block,
+				Index:    i,
+				Position: pos - historyLen,
+				Match:    m,
+				Problem:  fmt.Sprintf(format, args...),
+			}
+		}
+
+		switch {
+		case m.Unmatched < 0:
+			return fail("negative Unmatched")
+		case m.Length < 0:
+			return fail("negative Length")
+		case pos+m.Unmatched+m.Length > len(h):
+			return fail("extends %d bytes past the end of the block", pos+m.Unmatched+m.Length-len(h))
+		case m.Length == 0 && i != len(matches)-1:
+			return fail("zero Length before the end of the block")
+		}
+
+		if m.Length > 0 {
+			start := pos + m.Unmatched
+			switch {
+			case m.Distance <= 0:
+				return fail("Distance is not positive")
+			case m.Distance > start:
+				return fail("Distance is %d bytes before the start of the data", m.Distance-start)
+			case maxDistance > 0 && m.Distance > maxDistance:
+				return fail("Distance is more than MaxDistance (%d)", maxDistance)
+			}
+			for j := range m.Length {
+				if h[start+j] != h[start+j-m.Distance] {
+					return fail("byte %d of the match doesn't match (%#x != %#x)", j, h[start+j], h[start+j-m.Distance])
+				}
+			}
+		}
+
+		pos += m.Unmatched + m.Length
+	}
+
+	if pos != len(h) {
+		return &MatchError{
+			Block:    block,
+			Index:    len(matches),
+			Position: pos - historyLen,
+			Problem:  fmt.Sprintf("matches end %d bytes before the end of the block", len(h)-pos),
+		}
+	}
+	return nil
+}
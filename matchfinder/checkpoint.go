@@ -0,0 +1,186 @@
+package matchfinder
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+	"math"
+)
+
+// writerCheckpointMagic starts a marshaled WriterCheckpoint. The last
+// byte is the format version.
+const writerCheckpointMagic = "MFCKPT\x01"
+
+var (
+	errCheckpointUnsupported = errors.New("matchfinder: the Encoder or Filter doesn't support checkpoints")
+	errInvalidCheckpoint     = errors.New("matchfinder: invalid checkpoint")
+)
+
+// A WriterCheckpoint is a saved state of a Writer, from which compression
+// can be resumed (for example, after the program restarts).
+type WriterCheckpoint struct {
+	// InputOffset is the number of bytes of uncompressed data that had
+	// been written to the Writer. A restored Writer expects the data that
+	// comes after this point.
+	InputOffset int64
+
+	// OutputOffset is the number of bytes of compressed data that had
+	// been written to Dest. A restored Writer writes the part of the
+	// compressed stream that comes after this point.
+	OutputOffset int64
+
+	encoderState []byte
+	filterState  []byte // nil if there is no Filter
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler.
+func (c *WriterCheckpoint) MarshalBinary() ([]byte, error) {
+	b := []byte(writerCheckpointMagic)
+	b = binary.AppendUvarint(b, uint64(c.InputOffset))
+	b = binary.AppendUvarint(b, uint64(c.OutputOffset))
+	b = binary.AppendUvarint(b, uint64(len(c.encoderState)))
+	b = append(b, c.encoderState...)
+	if c.filterState != nil {
+		b = append(b, 1)
+		b = append(b, c.filterState...)
+	} else {
+		b = append(b, 0)
+	}
+	return binary.LittleEndian.AppendUint32(b, crc32.ChecksumIEEE(b)), nil
+}
+
+// UnmarshalBinary implements encoding.BinaryUnmarshaler.
+func (c *WriterCheckpoint) UnmarshalBinary(data []byte) error {
+	if len(data) < 4 {
+		return errInvalidCheckpoint
+	}
+	body := data[:len(data)-4]
+	if crc32.ChecksumIEEE(body) != binary.LittleEndian.Uint32(data[len(body):]) {
+		return errInvalidCheckpoint
+	}
+	body, ok := bytes.CutPrefix(body, []byte(writerCheckpointMagic))
+	if !ok {
+		return errInvalidCheckpoint
+	}
+
+	var fields [3]uint64
+	for i := range fields {
+		v, n := binary.Uvarint(body)
+		if n <= 0 || v > math.MaxInt64 {
+			return errInvalidCheckpoint
+		}
+		fields[i] = v
+		body = body[n:]
+	}
+	encoderLen := fields[2]
+	if encoderLen >= uint64(len(body)) {
+		return errInvalidCheckpoint
+	}
+	c.InputOffset = int64(fields[0])
+	c.OutputOffset = int64(fields[1])
+	c.encoderState = bytes.Clone(body[:encoderLen])
+	body = body[encoderLen:]
+	switch body[0] {
+	case 0:
+		if len(body) > 1 {
+			return errInvalidCheckpoint
+		}
+		c.filterState = nil
+	case 1:
+		c.filterState = append([]byte{}, body[1:]...)
+	default:
+		return errInvalidCheckpoint
+	}
+	return nil
+}
+
+// Checkpoint compresses the data that has been written so far (ending the
+// current block early if necessary), and saves the Writer's state, so
+// that compression can be resumed later with Restore.
+//
+// The Encoder must implement encoding.BinaryMarshaler and
+// encoding.BinaryUnmarshaler to save its state, and so must the Filter if
+// there is one. The MatchFinder's state is not saved. So after a Writer
+// is restored, it can't find matches in the data from before the
+// checkpoint, and compression is somewhat worse until it has built up a
+// new history.
+func (w *Writer) Checkpoint() (*WriterCheckpoint, error) {
+	enc, ok := w.Encoder.(encoding.BinaryMarshaler)
+	if !ok {
+		return nil, errCheckpointUnsupported
+	}
+	var filter encoding.BinaryMarshaler
+	if w.Filter != nil {
+		if filter, ok = w.Filter.(encoding.BinaryMarshaler); !ok {
+			return nil, errCheckpointUnsupported
+		}
+	}
+
+	if err := w.getErr(); err != nil {
+		return nil, err
+	}
+	if len(w.inBuf) > 0 {
+		w.writeBlock(w.inBuf, false)
+		w.inBuf = w.inBuf[:0]
+	}
+	w.stopPipeline(false)
+	if w.err != nil {
+		return nil, w.err
+	}
+
+	c := &WriterCheckpoint{
+		InputOffset:  w.stats.BytesIn,
+		OutputOffset: w.stats.BytesOut,
+	}
+	var err error
+	if c.encoderState, err = enc.MarshalBinary(); err != nil {
+		return nil, err
+	}
+	if filter != nil {
+		if c.filterState, err = filter.MarshalBinary(); err != nil {
+			return nil, err
+		}
+		if c.filterState == nil {
+			c.filterState = []byte{}
+		}
+	}
+	return c, nil
+}
+
+// Restore resets w, and restores the state saved in c, so that it
+// continues compressing the stream, writing to dest. The Writer must be
+// set up the same way as the one that made the checkpoint (with the same
+// kind of Encoder, Filter, and settings).
+func (w *Writer) Restore(c *WriterCheckpoint, dest io.Writer) error {
+	enc, ok := w.Encoder.(encoding.BinaryUnmarshaler)
+	if !ok {
+		return errCheckpointUnsupported
+	}
+	var filter encoding.BinaryUnmarshaler
+	if w.Filter != nil {
+		if filter, ok = w.Filter.(encoding.BinaryUnmarshaler); !ok {
+			return errCheckpointUnsupported
+		}
+	}
+	if (filter != nil) != (c.filterState != nil) {
+		return errInvalidCheckpoint
+	}
+
+	w.Reset(dest)
+	if err := enc.UnmarshalBinary(c.encoderState); err != nil {
+		w.Reset(dest)
+		return err
+	}
+	if filter != nil {
+		if err := filter.UnmarshalBinary(c.filterState); err != nil {
+			w.Reset(dest)
+			return err
+		}
+	}
+	w.stats.BytesIn = c.InputOffset
+	w.stats.BytesOut = c.OutputOffset
+	return nil
+}
@@ -0,0 +1,109 @@
+package matchfinder
+
+import "math/bits"
+
+// A CostModel estimates how many bits an Encoder will use for each part of
+// the compressed data. The MatchFinders that use a shortest-path optimizer
+// (Pathfinder, Bargain1, Bargain2, Bargain3, and BinaryTree) use it to
+// compare different ways of encoding a block, so using the model that
+// matches the Encoder will tune the parse for that Encoder.
+type CostModel interface {
+	// LiteralCosts sets costs[b] to the cost of encoding b as a literal
+	// in the block src.
+	LiteralCosts(costs *[256]float32, src []byte)
+
+	// InsertCost returns the cost of the length of a run of n literals
+	// before a match (not including the literals themselves).
+	InsertCost(n int) float32
+
+	// LengthCost returns the cost of the length of a match.
+	LengthCost(length int) float32
+
+	// DistanceCost returns the cost of the distance of a match. If repeat
+	// is true, the distance is the same as the previous match's.
+	DistanceCost(distance int, repeat bool) float32
+}
+
+// defaultCostModel is the CostModel used when none is specified. It is a
+// rough approximation that works well enough for most Encoders.
+type defaultCostModel struct {
+	// shortMatchPenalty is whether matches shorter than 6 bytes should have
+	// higher costs.
+	shortMatchPenalty bool
+}
+
+func (defaultCostModel) LiteralCosts(costs *[256]float32, src []byte) {
+	LiteralCosts(costs, src)
+}
+
+func (defaultCostModel) InsertCost(n int) float32 {
+	return float32(bits.Len(uint(n)))
+}
+
+func (c defaultCostModel) LengthCost(length int) float32 {
+	if c.shortMatchPenalty && length < 6 {
+		// Matches shorter than 6 are comparatively rare, and therefore
+		// have longer codes.
+		return baseMatchCost + float32(6-length)*2
+	}
+	return baseMatchCost
+}
+
+func (defaultCostModel) DistanceCost(distance int, repeat bool) float32 {
+	if repeat {
+		return 0
+	}
+	return float32(bits.Len(uint(distance)))
+}
+
+// LiteralCosts sets costs[b] to the number of bits that an entropy coder
+// would use for b, based on how often it occurs in src (but at least 1
+// bit). CostModels for Encoders that use Huffman codes for literals can
+// call it from their LiteralCosts method.
+func LiteralCosts(costs *[256]float32, src []byte) {
+	var histogram [256]uint
+	for _, b := range src {
+		histogram[b]++
+	}
+	for b, n := range histogram {
+		cost := max(Log2(uint(len(src)))-Log2(n), 1)
+		costs[b] = float32(cost)
+	}
+}
+
+// Log2 returns the base-2 logarithm of n, or 0 if n is 0. The result is
+// within 1e-7 of the exact value.
+//
+// It uses only integer arithmetic (interpolating between the entries of a
+// table), so (unlike math.Log2, which has
+// assembly implementations on some architectures and can be compiled with
+// fused multiply-add instructions on others) it gives exactly the same
+// result on every platform. CostModels should use it instead of math.Log2,
+// so that the compressed output doesn't depend on GOARCH.
+func Log2(n uint) float64 {
+	if n == 0 {
+		return 0
+	}
+	k := bits.Len(n) - 1
+
+	// f is the fractional part of n/2^k, in 0.32 fixed-point format.
+	f := uint32(uint64(n) << (63 - k) >> 31)
+
+	// Interpolate linearly between the table entries on either side.
+	i := f >> (32 - log2TableBits)
+	t := uint64(f & (1<<(32-log2TableBits) - 1))
+	lo, hi := log2Table[i], log2Table[i+1]
+	frac := uint64(lo) + (uint64(hi-lo)*t)>>(32-log2TableBits)
+
+	return float64(uint64(k)<<log2FracBits|frac) / (1 << log2FracBits)
+}
+
+const (
+	// log2TableBits is the number of bits of the mantissa that are used to
+	// index log2Table.
+	log2TableBits = 11
+
+	// log2FracBits is the number of fractional bits in the entries of
+	// log2Table.
+	log2FracBits = 31
+)
@@ -0,0 +1,321 @@
+package matchfinder
+
+import "encoding/binary"
+
+// A Filter is a reversible transformation that a Writer applies to the
+// data before looking for matches, to make it more compressible. Filters
+// are mostly useful for binary formats, where the same value is often
+// stored with different bytes each time it appears.
+type Filter interface {
+	// Encode appends the filtered form of src to dst and returns the
+	// result. The Filter may keep state from one call to the next, and it
+	// may hold back some bytes from the end of src until more data is
+	// available. When final is true, src is the end of the stream, and all
+	// the remaining data is written.
+	Encode(dst, src []byte, final bool) []byte
+
+	// Decode reverses Encode. Like Encode, it may hold back data until the
+	// next call; the output doesn't depend on how the stream is divided
+	// between calls.
+	Decode(dst, src []byte, final bool) []byte
+
+	// Reset clears any internal state, preparing the Filter to be used with
+	// a new stream.
+	Reset()
+}
+
+// A DeltaFilter replaces each byte with its difference from the byte
+// Distance positions earlier. It helps with sampled data like audio,
+// uncompressed images, and sensor readings, where neighboring values are
+// similar.
+type DeltaFilter struct {
+	// Distance is the size of each sample in bytes (times the number of
+	// channels, if they are interleaved). If it is zero, 1 is used.
+	Distance int
+
+	// prev holds the last Distance bytes of unfiltered data.
+	prev []byte
+}
+
+func (f *DeltaFilter) distance() int {
+	if f.Distance <= 0 {
+		return 1
+	}
+	return f.Distance
+}
+
+func (f *DeltaFilter) Reset() {
+	f.prev = f.prev[:0]
+}
+
+func (f *DeltaFilter) Encode(dst, src []byte, final bool) []byte {
+	d := f.distance()
+	for i, b := range src {
+		dst = append(dst, b-f.before(src, i, d))
+	}
+	f.savePrev(src, d)
+	return dst
+}
+
+func (f *DeltaFilter) Decode(dst, src []byte, final bool) []byte {
+	d := f.distance()
+	start := len(dst)
+	for i, b := range src {
+		dst = append(dst, b+f.before(dst[start:], i, d))
+	}
+	f.savePrev(dst[start:], d)
+	return dst
+}
+
+// MarshalBinary saves the DeltaFilter's state (but not its Distance), for
+// Writer.Checkpoint.
+func (f *DeltaFilter) MarshalBinary() ([]byte, error) {
+	return append([]byte{}, f.prev...), nil
+}
+
+func (f *DeltaFilter) UnmarshalBinary(data []byte) error {
+	if len(data) > f.distance() {
+		return errInvalidCheckpoint
+	}
+	f.prev = append(f.prev[:0], data...)
+	return nil
+}
+
+// before returns the unfiltered byte d positions before data[i], or 0 if
+// it would be before the start of the stream.
+func (f *DeltaFilter) before(data []byte, i, d int) byte {
+	if i >= d {
+		return data[i-d]
+	}
+	if j := len(f.prev) - d + i; j >= 0 {
+		return f.prev[j]
+	}
+	return 0
+}
+
+// savePrev updates f.prev after data has been processed.
+func (f *DeltaFilter) savePrev(data []byte, d int) {
+	if len(data) >= d {
+		f.prev = append(f.prev[:0], data[len(data)-d:]...)
+		return
+	}
+	f.prev = append(f.prev, data...)
+	if len(f.prev) > d {
+		n := copy(f.prev, f.prev[len(f.prev)-d:])
+		f.prev = f.prev[:n]
+	}
+}
+
+// strideGroupSize is the approximate number of bytes that a StrideFilter
+// rearranges at a time.
+const strideGroupSize = 1 << 16
+
+// A StrideFilter is for data made of fixed-size records, like tables of
+// binary numbers. It rearranges the records so that the first byte of
+// every record comes first, then the second byte of every record, and so
+// on. Fields that change slowly from one record to the next end up in
+// long, repetitive runs.
+//
+// The data is rearranged in groups of about 64 KB (a whole number of
+// records), so a StrideFilter holds back up to that much data until the
+// group is complete. A partial group at the end of the stream is
+// rearranged the same way, with any partial record left at the end.
+type StrideFilter struct {
+	// Width is the size of each record in bytes. If it is less than 2,
+	// the data is not changed.
+	Width int
+
+	// buf holds the part of the current group that has been received so
+	// far.
+	buf []byte
+}
+
+func (f *StrideFilter) Reset() {
+	f.buf = f.buf[:0]
+}
+
+func (f *StrideFilter) Encode(dst, src []byte, final bool) []byte {
+	return f.process(dst, src, final, transpose)
+}
+
+func (f *StrideFilter) Decode(dst, src []byte, final bool) []byte {
+	return f.process(dst, src, final, untranspose)
+}
+
+// MarshalBinary saves the StrideFilter's state (but not its Width), for
+// Writer.Checkpoint.
+func (f *StrideFilter) MarshalBinary() ([]byte, error) {
+	return append([]byte{}, f.buf...), nil
+}
+
+func (f *StrideFilter) UnmarshalBinary(data []byte) error {
+	if len(data) > 0 && (f.Width < 2 || len(data) >= f.Width*max(strideGroupSize/f.Width, 1)) {
+		return errInvalidCheckpoint
+	}
+	f.buf = append(f.buf[:0], data...)
+	return nil
+}
+
+// process splits the stream into groups, and calls fn to rearrange each
+// group.
+func (f *StrideFilter) process(dst, src []byte, final bool, fn func(dst, src []byte, width int) []byte) []byte {
+	width := f.Width
+	if width < 2 {
+		return append(dst, src...)
+	}
+	groupSize := width * max(strideGroupSize/width, 1)
+
+	if len(f.buf) > 0 {
+		n := min(groupSize-len(f.buf), len(src))
+		f.buf = append(f.buf, src[:n]...)
+		src = src[n:]
+		if len(f.buf) < groupSize && !final {
+			return dst
+		}
+		dst = fn(dst, f.buf, width)
+		f.buf = f.buf[:0]
+	}
+
+	for len(src) >= groupSize {
+		dst = fn(dst, src[:groupSize], width)
+		src = src[groupSize:]
+	}
+
+	if final {
+		return fn(dst, src, width)
+	}
+	f.buf = append(f.buf, src...)
+	return dst
+}
+
+// transpose appends src to dst, with the records of width bytes rearranged
+// by column. A partial record at the end is copied unchanged.
+func transpose(dst, src []byte, width int) []byte {
+	n := len(src) / width
+	start := len(dst)
+	dst = append(dst, src...)
+	out := dst[start:]
+	for i := 0; i < n; i++ {
+		rec := src[i*width : i*width+width]
+		for j, b := range rec {
+			out[j*n+i] = b
+		}
+	}
+	return dst
+}
+
+// untranspose reverses transpose.
+func untranspose(dst, src []byte, width int) []byte {
+	n := len(src) / width
+	start := len(dst)
+	dst = append(dst, src...)
+	out := dst[start:]
+	for i := 0; i < n; i++ {
+		rec := out[i*width : i*width+width]
+		for j := range rec {
+			rec[j] = src[j*n+i]
+		}
+	}
+	return dst
+}
+
+// An X86Filter converts the relative target addresses in x86 (and x86-64)
+// CALL and JMP instructions into absolute addresses, so that repeated calls
+// to the same function are encoded with the same bytes. This is the same
+// transformation as the BCJ filter in xz, but without its heuristics for
+// skipping bytes that probably aren't instructions.
+type X86Filter struct {
+	// pos is the stream position of the first byte of held.
+	pos uint32
+
+	// held is data at the end of the previous call that might be part of
+	// an instruction that was cut off.
+	held []byte
+}
+
+func (f *X86Filter) Reset() {
+	f.pos = 0
+	f.held = f.held[:0]
+}
+
+func (f *X86Filter) Encode(dst, src []byte, final bool) []byte {
+	return f.convert(dst, src, final, true)
+}
+
+func (f *X86Filter) Decode(dst, src []byte, final bool) []byte {
+	return f.convert(dst, src, final, false)
+}
+
+// MarshalBinary saves the X86Filter's state, for Writer.Checkpoint.
+func (f *X86Filter) MarshalBinary() ([]byte, error) {
+	b := binary.LittleEndian.AppendUint32(nil, f.pos)
+	return append(b, f.held...), nil
+}
+
+func (f *X86Filter) UnmarshalBinary(data []byte) error {
+	if len(data) < 4 || len(data) > 8 {
+		return errInvalidCheckpoint
+	}
+	f.pos = binary.LittleEndian.Uint32(data)
+	f.held = append(f.held[:0], data[4:]...)
+	return nil
+}
+
+func (f *X86Filter) convert(dst, src []byte, final, encoding bool) []byte {
+	data := src
+	if len(f.held) > 0 {
+		f.held = append(f.held, src...)
+		data = f.held
+	}
+
+	start := len(dst)
+	dst = append(dst, data...)
+	out := dst[start:]
+
+	// An instruction is converted if it starts with E8 (CALL) or E9 (JMP)
+	// and its 32-bit displacement is in the range of a sign-extended 25-bit
+	// number. The converted address is truncated to 25 bits and sign
+	// extended again, so the decoder sees a displacement in the same
+	// range, and it makes the same decisions as the encoder. The four bytes
+	// after E8 or E9 are skipped even if they aren't converted; otherwise
+	// converting a later instruction could change the byte that the
+	// decision was based on.
+	i := 0
+	for ; i < len(out); i++ {
+		if out[i]&0xfe != 0xe8 {
+			continue
+		}
+		if i+5 > len(out) {
+			if final {
+				continue
+			}
+			break
+		}
+		if msb := out[i+4]; msb != 0 && msb != 0xff {
+			i += 4
+			continue
+		}
+		v := binary.LittleEndian.Uint32(out[i+1:])
+		next := f.pos + uint32(i+5)
+		if encoding {
+			v += next
+		} else {
+			v -= next
+		}
+		v &= 0x1ffffff
+		if v&0x1000000 != 0 {
+			v |= 0xfe000000
+		}
+		binary.LittleEndian.PutUint32(out[i+1:], v)
+		i += 4
+	}
+
+	if i >= len(out) {
+		f.pos += uint32(len(out))
+		f.held = f.held[:0]
+		return dst
+	}
+	f.pos += uint32(i)
+	f.held = append(f.held[:0], data[i:]...)
+	return dst[:start+i]
+}
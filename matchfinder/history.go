@@ -0,0 +1,66 @@
+package matchfinder
+
+// A history is the buffer of recent data that a MatchFinder searches for
+// matches.
+//
+// The hash tables that index the history store stream positions (modulo
+// 1<<32) rather than indexes into buf. So when old data is discarded from
+// the start of the buffer, only base changes; the tables don't need to be
+// walked and adjusted. Entries that refer to discarded data are detected
+// when they are looked up.
+type history struct {
+	buf []byte
+
+	// base is the stream position of buf[0], modulo 1<<32.
+	base uint32
+}
+
+func (h *history) reset() {
+	h.buf = h.buf[:0]
+	h.base = 0
+}
+
+// trim discards old data from the start of the buffer if it is more than
+// twice maxDistance bytes long. It returns the number of bytes discarded.
+func (h *history) trim(maxDistance int) int {
+	if len(h.buf) <= maxDistance*2 {
+		return 0
+	}
+	delta := len(h.buf) - maxDistance
+	copy(h.buf, h.buf[delta:])
+	h.buf = h.buf[:maxDistance]
+	h.base += uint32(delta)
+	return delta
+}
+
+// pos returns the stream position of buf[i].
+func (h *history) pos(i int) uint32 {
+	return h.base + uint32(i)
+}
+
+// index returns the index in buf of stream position p, which was stored
+// in a hash table before buf[i] was added. If the data at p has been
+// discarded, it returns 0, which the hash tables use for empty entries.
+func (h *history) index(p uint32, i int) int {
+	d := h.pos(i) - p
+	if d > uint32(i) {
+		return 0
+	}
+	return i - int(d)
+}
+
+// entry returns a tableEntry for buf[i].
+func (h *history) entry(i int32, val uint32) tableEntry {
+	return tableEntry{offset: int32(h.pos(int(i))), val: val}
+}
+
+// lookup converts the offset of e from a stream position to an index in
+// buf. If the data at e.offset has been discarded, it returns an empty
+// tableEntry.
+func (h *history) lookup(e tableEntry) tableEntry {
+	e.offset = int32(uint32(e.offset) - h.base)
+	if e.offset < 0 {
+		return tableEntry{}
+	}
+	return e
+}
@@ -0,0 +1,280 @@
+package matchfinder
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+const (
+	ldmBucketBits = 3
+	ldmBucketSize = 1 << ldmBucketBits
+
+	// ldmMinTrimmedLength is the shortest that a match from the wrapped
+	// MatchFinder is allowed to become when it is shortened to make room
+	// for a long-distance match.
+	ldmMinTrimmedLength = 4
+)
+
+// LDM is a MatchFinder that adds long-distance matching to another
+// MatchFinder, like zstd's --long option. It keeps a sparse index of
+// positions in the last MaxDistance bytes, chosen with a rolling hash, so
+// that it can find long repeated sequences much farther back than the
+// hash tables of ordinary MatchFinders can reach. Those matches are merged
+// with the ones found by MatchFinder.
+//
+// LDM keeps MaxDistance bytes of history in memory, plus an index of
+// 16 << HashBits bytes.
+type LDM struct {
+	// MatchFinder finds the short-distance matches.
+	MatchFinder MatchFinder
+
+	// MaxDistance is the maximum distance for long-distance matches.
+	// The default is 128 MB.
+	MaxDistance int
+
+	// MinLength is the minimum length of a long-distance match.
+	// The default is 64.
+	MinLength int
+
+	// HashBits is the base-2 logarithm of the number of entries in the
+	// index. One position in 2^(log2(MaxDistance) - HashBits) is indexed.
+	// The default is log2(MaxDistance) - 7.
+	HashBits int
+
+	history []byte
+	// base is the offset in the stream of history[0].
+	base int64
+
+	table      []ldmEntry
+	bucketNext []uint8
+	stopMask   uint64
+
+	// hash is the rolling hash of the bytes before the end of history, and
+	// hashed is how many bytes have gone into it.
+	hash   uint64
+	hashed int
+
+	long  []absoluteMatch
+	short []Match
+}
+
+type ldmEntry struct {
+	// pos is the offset in the stream of the start of the indexed sequence,
+	// plus one (so that zero means an empty entry).
+	pos      int64
+	checksum uint32
+}
+
+// ldmGear is the table of random values for the rolling gear hash.
+var ldmGear [256]uint64
+
+func init() {
+	// Fill ldmGear with the output of splitmix64, so that it is the same on
+	// every run.
+	var x uint64
+	for i := range ldmGear {
+		x += 0x9E3779B97F4A7C15
+		z := x
+		z = (z ^ (z >> 30)) * 0xBF58476D1CE4E5B9
+		z = (z ^ (z >> 27)) * 0x94D049BB133111EB
+		ldmGear[i] = z ^ (z >> 31)
+	}
+}
+
+func (l *LDM) Reset() {
+	l.MatchFinder.Reset()
+	l.history = l.history[:0]
+	l.base = 0
+	clear(l.table)
+	clear(l.bucketNext)
+	l.hash = 0
+	l.hashed = 0
+}
+
+func (l *LDM) FindMatches(dst []Match, src []byte) []Match {
+	if l.MaxDistance == 0 {
+		l.MaxDistance = 1 << 27
+	}
+	if l.MinLength == 0 {
+		l.MinLength = 64
+	}
+	windowBits := bits.Len(uint(l.MaxDistance - 1))
+	if l.HashBits == 0 {
+		l.HashBits = max(windowBits-7, ldmBucketBits+8)
+	}
+	if l.table == nil {
+		l.table = make([]ldmEntry, 1<<l.HashBits)
+		l.bucketNext = make([]uint8, 1<<(l.HashBits-ldmBucketBits))
+		rateBits := max(windowBits-l.HashBits, 0)
+		l.stopMask = ^uint64(0) << (64 - rateBits)
+		if rateBits == 0 {
+			l.stopMask = 0
+		}
+	}
+
+	l.short = l.MatchFinder.FindMatches(l.short[:0], src)
+
+	start := l.addHistory(src)
+	l.long = l.findLongMatches(l.long[:0], start)
+	for i := range l.long {
+		l.long[i].Start -= start
+		l.long[i].End -= start
+		l.long[i].Match -= start
+	}
+
+	return l.merge(dst, len(src))
+}
+
+// addHistory appends src to l.history, discarding old data as needed, and
+// returns the index in l.history where src starts.
+func (l *LDM) addHistory(src []byte) int {
+	slack := max(l.MaxDistance/8, len(src))
+	if len(l.history)+len(src) > l.MaxDistance+slack && len(l.history) > l.MaxDistance {
+		delta := len(l.history) - l.MaxDistance
+		copy(l.history, l.history[delta:])
+		l.history = l.history[:l.MaxDistance]
+		l.base += int64(delta)
+	}
+
+	if need := len(l.history) + len(src); need > cap(l.history) {
+		newCap := max(2*cap(l.history), 1<<20)
+		newCap = min(newCap, l.MaxDistance+slack)
+		newCap = max(newCap, need)
+		newHistory := make([]byte, len(l.history), newCap)
+		copy(newHistory, l.history)
+		l.history = newHistory
+	}
+
+	start := len(l.history)
+	l.history = append(l.history, src...)
+	return start
+}
+
+// findLongMatches looks for long-distance matches in l.history[start:],
+// and appends them to dst.
+func (l *LDM) findLongMatches(dst []absoluteMatch, start int) []absoluteMatch {
+	h := l.history
+	minLength := l.MinLength
+	hashShift := 64 - (l.HashBits - ldmBucketBits)
+	nextEmit := start
+
+	for i := start; i < len(h); i++ {
+		l.hash = l.hash<<1 + ldmGear[h[i]]
+		l.hashed++
+		if l.hashed < minLength || l.hash&l.stopMask != 0 {
+			continue
+		}
+
+		// Index (and look up) the minLength bytes ending at i.
+		seq := i + 1 - minLength
+		checksum := ldmChecksum(h[seq : i+1])
+		bucketIndex := checksum >> hashShift
+		bucket := l.table[bucketIndex<<ldmBucketBits:][:ldmBucketSize]
+
+		if seq >= nextEmit {
+			var best absoluteMatch
+			for _, e := range bucket {
+				if e.pos == 0 || e.checksum != uint32(checksum) {
+					continue
+				}
+				candidate := int(e.pos - 1 - l.base)
+				if candidate < 0 || seq-candidate > l.MaxDistance {
+					continue
+				}
+				end := extendMatch(h, candidate, seq)
+				if end-seq < minLength {
+					continue
+				}
+				m := absoluteMatch{Start: seq, End: end, Match: candidate}
+				for m.Start > nextEmit && m.Match > 0 && h[m.Start-1] == h[m.Match-1] {
+					m.Start--
+					m.Match--
+				}
+				if m.End-m.Start > best.End-best.Start {
+					best = m
+				}
+			}
+			if best.End > 0 {
+				dst = append(dst, best)
+				nextEmit = best.End
+			}
+		}
+
+		next := &l.bucketNext[bucketIndex]
+		bucket[*next] = ldmEntry{
+			pos:      l.base + int64(seq) + 1,
+			checksum: uint32(checksum),
+		}
+		*next = (*next + 1) % ldmBucketSize
+	}
+
+	return dst
+}
+
+// merge combines the long-distance matches in l.long with the short ones in
+// l.short, and appends the result to dst. When they overlap, the long
+// matches take priority, and the short ones are shortened or dropped.
+func (l *LDM) merge(dst []Match, n int) []Match {
+	e := matchEmitter{Dst: dst}
+	long := l.long
+
+	// emitShort emits m after trimming the beginning to avoid overlapping
+	// what has already been emitted.
+	emitShort := func(m absoluteMatch, trimmed bool) {
+		if m.Start < e.NextEmit {
+			m.Match += e.NextEmit - m.Start
+			m.Start = e.NextEmit
+			trimmed = true
+		}
+		if m.End-m.Start >= ldmMinTrimmedLength || (!trimmed && m.End > m.Start) {
+			e.emit(m)
+		}
+	}
+
+	pos := 0
+	for _, s := range l.short {
+		pos += s.Unmatched
+		if s.Length == 0 {
+			continue
+		}
+		m := absoluteMatch{
+			Start: pos,
+			End:   pos + s.Length,
+			Match: pos - s.Distance,
+		}
+		pos = m.End
+
+		for len(long) > 0 && long[0].Start < m.End {
+			if long[0].Start > m.Start {
+				emitShort(absoluteMatch{Start: m.Start, End: long[0].Start, Match: m.Match}, true)
+			}
+			e.emit(long[0])
+			long = long[1:]
+		}
+		emitShort(m, false)
+	}
+
+	for _, m := range long {
+		e.emit(m)
+	}
+	if e.NextEmit < n {
+		e.Dst = append(e.Dst, Match{
+			Unmatched: n - e.NextEmit,
+		})
+	}
+	return e.Dst
+}
+
+// ldmChecksum returns a 64-bit hash of b.
+func ldmChecksum(b []byte) uint64 {
+	h := uint64(len(b)) * hashMul64
+	for len(b) >= 8 {
+		h = (h ^ binary.LittleEndian.Uint64(b)) * hashMul64
+		h ^= h >> 29
+		b = b[8:]
+	}
+	for _, c := range b {
+		h = (h ^ uint64(c)) * hashMul64
+	}
+	return h ^ h>>32
+}
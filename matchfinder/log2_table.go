@@ -0,0 +1,265 @@
+package matchfinder
+
+// log2Table holds log2(1 + i/2^log2TableBits), rounded to log2FracBits
+// fractional bits. It was computed with integer arithmetic, by repeatedly
+// squaring 1 + i/2^log2TableBits (in 1.63 fixed-point format) and
+// collecting one bit of the logarithm each time x reached 2.
+var log2Table = [1<<log2TableBits + 1]uint32{
+	0x00000000, 0x001713d6, 0x002e24ca, 0x004532dd, 0x005c3e10, 0x00734663, 0x008a4bd6, 0x00a14e6c,
+	0x00b84e23, 0x00cf4afe, 0x00e644fd, 0x00fd3c20, 0x01143068, 0x012b21d6, 0x0142106b, 0x0158fc27,
+	0x016fe50b, 0x0186cb18, 0x019dae4f, 0x01b48eaf, 0x01cb6c3b, 0x01e246f2, 0x01f91ed5, 0x020ff3e5,
+	0x0226c623, 0x023d958f, 0x0254622a, 0x026b2bf5, 0x0281f2f1, 0x0298b71d, 0x02af787c, 0x02c6370d,
+	0x02dcf2d1, 0x02f3abc9, 0x030a61f5, 0x03211557, 0x0337c5ef, 0x034e73bd, 0x03651ec3, 0x037bc701,
+	0x03926c77, 0x03a90f27, 0x03bfaf11, 0x03d64c36, 0x03ece696, 0x04037e32, 0x041a130b, 0x0430a521,
+	0x04473475, 0x045dc108, 0x04744adb, 0x048ad1ee, 0x04a15641, 0x04b7d7d6, 0x04ce56ae, 0x04e4d2c8,
+	0x04fb4c25, 0x0511c2c7, 0x052836ad, 0x053ea7d9, 0x0555164b, 0x056b8204, 0x0581eb04, 0x0598514c,
+	0x05aeb4dd, 0x05c515b8, 0x05db73dd, 0x05f1cf4c, 0x06082807, 0x061e7e0e, 0x0634d161, 0x064b2202,
+	0x06616ff1, 0x0677bb2e, 0x068e03bb, 0x06a44998, 0x06ba8cc6, 0x06d0cd44, 0x06e70b15, 0x06fd4638,
+	0x07137eae, 0x0729b478, 0x073fe797, 0x0756180b, 0x076c45d4, 0x078270f4, 0x0798996b, 0x07aebf3a,
+	0x07c4e261, 0x07db02e1, 0x07f120ba, 0x08073bee, 0x081d547c, 0x08336a66, 0x08497dad, 0x085f8e50,
+	0x08759c50, 0x088ba7ae, 0x08a1b06b, 0x08b7b687, 0x08cdba03, 0x08e3bae0, 0x08f9b91e, 0x090fb4bd,
+	0x0925adbf, 0x093ba424, 0x095197ec, 0x09678919, 0x097d77ab, 0x099363a2, 0x09a94cff, 0x09bf33c3,
+	0x09d517ef, 0x09eaf982, 0x0a00d87e, 0x0a16b4e3, 0x0a2c8eb2, 0x0a4265eb, 0x0a583a8f, 0x0a6e0c9f,
+	0x0a83dc1b, 0x0a99a904, 0x0aaf735a, 0x0ac53b1f, 0x0adb0052, 0x0af0c2f4, 0x0b068307, 0x0b1c408a,
+	0x0b31fb7d, 0x0b47b3e3, 0x0b5d69bb, 0x0b731d06, 0x0b88cdc4, 0x0b9e7bf6, 0x0bb4279d, 0x0bc9d0b9,
+	0x0bdf774b, 0x0bf51b54, 0x0c0abcd4, 0x0c205bcb, 0x0c35f83b, 0x0c4b9223, 0x0c612985, 0x0c76be61,
+	0x0c8c50b7, 0x0ca1e089, 0x0cb76dd6, 0x0cccf8a0, 0x0ce280e7, 0x0cf806ab, 0x0d0d89ee, 0x0d230aaf,
+	0x0d3888f0, 0x0d4e04b0, 0x0d637df1, 0x0d78f4b3, 0x0d8e68f6, 0x0da3dabc, 0x0db94a05, 0x0dceb6d1,
+	0x0de42120, 0x0df988f5, 0x0e0eee4e, 0x0e24512d, 0x0e39b193, 0x0e4f0f7f, 0x0e646af2, 0x0e79c3ee,
+	0x0e8f1a72, 0x0ea46e7f, 0x0eb9c016, 0x0ecf0f37, 0x0ee45be2, 0x0ef9a619, 0x0f0eeddd, 0x0f24332c,
+	0x0f397609, 0x0f4eb673, 0x0f63f46b, 0x0f792ff3, 0x0f8e6909, 0x0fa39fb0, 0x0fb8d3e6, 0x0fce05ae,
+	0x0fe33508, 0x0ff861f3, 0x100d8c71, 0x1022b482, 0x1037da28, 0x104cfd61, 0x10621e2f, 0x10773c93,
+	0x108c588d, 0x10a1721d, 0x10b68945, 0x10cb9e04, 0x10e0b05b, 0x10f5c04b, 0x110acdd4, 0x111fd8f7,
+	0x1134e1b5, 0x1149e80d, 0x115eec01, 0x1173ed91, 0x1188ecbd, 0x119de987, 0x11b2e3ee, 0x11c7dbf3,
+	0x11dcd197, 0x11f1c4db, 0x1206b5be, 0x121ba441, 0x12309066, 0x12457a2c, 0x125a6193, 0x126f469e,
+	0x1284294b, 0x1299099c, 0x12ade791, 0x12c2c32a, 0x12d79c69, 0x12ec734e, 0x130147d8, 0x13161a0a,
+	0x132ae9e2, 0x133fb763, 0x1354828c, 0x13694b5e, 0x137e11d9, 0x1392d5fe, 0x13a797cd, 0x13bc5748,
+	0x13d1146e, 0x13e5cf40, 0x13fa87be, 0x140f3dea, 0x1423f1c3, 0x1438a34a, 0x144d5280, 0x1461ff65,
+	0x1476a9fa, 0x148b523e, 0x149ff834, 0x14b49bdb, 0x14c93d33, 0x14dddc3e, 0x14f278fb, 0x1507136c,
+	0x151bab90, 0x15304169, 0x1544d4f7, 0x1559663a, 0x156df533, 0x158281e2, 0x15970c48, 0x15ab9465,
+	0x15c01a3a, 0x15d49dc7, 0x15e91f0e, 0x15fd9e0e, 0x16121ac7, 0x1626953b, 0x163b0d6a, 0x164f8355,
+	0x1663f6fb, 0x1678685d, 0x168cd77d, 0x16a1445a, 0x16b5aef5, 0x16ca174e, 0x16de7d66, 0x16f2e13e,
+	0x170742d5, 0x171ba22d, 0x172fff45, 0x17445a1f, 0x1758b2bb, 0x176d091a, 0x17815d3b, 0x1795af1f,
+	0x17a9fec8, 0x17be4c35, 0x17d29766, 0x17e6e05d, 0x17fb271a, 0x180f6b9d, 0x1823ade6, 0x1837edf7,
+	0x184c2bd0, 0x18606771, 0x1874a0db, 0x1888d80e, 0x189d0d0b, 0x18b13fd2, 0x18c57063, 0x18d99ec0,
+	0x18edcae8, 0x1901f4dd, 0x19161c9e, 0x192a422c, 0x193e6588, 0x195286b1, 0x1966a5aa, 0x197ac271,
+	0x198edd07, 0x19a2f56e, 0x19b70ba5, 0x19cb1fad, 0x19df3187, 0x19f34132, 0x1a074eaf, 0x1a1b5a00,
+	0x1a2f6323, 0x1a436a1a, 0x1a576ee6, 0x1a6b7186, 0x1a7f71fb, 0x1a937046, 0x1aa76c67, 0x1abb665f,
+	0x1acf5e2e, 0x1ae353d4, 0x1af74752, 0x1b0b38a8, 0x1b1f27d8, 0x1b3314e0, 0x1b46ffc3, 0x1b5ae880,
+	0x1b6ecf17, 0x1b82b38a, 0x1b9695d9, 0x1baa7603, 0x1bbe540a, 0x1bd22fee, 0x1be609b0, 0x1bf9e150,
+	0x1c0db6ce, 0x1c218a2b, 0x1c355b67, 0x1c492a83, 0x1c5cf780, 0x1c70c25d, 0x1c848b1b, 0x1c9851bb,
+	0x1cac163c, 0x1cbfd8a1, 0x1cd398e8, 0x1ce75713, 0x1cfb1322, 0x1d0ecd15, 0x1d2284ed, 0x1d363aaa,
+	0x1d49ee4c, 0x1d5d9fd5, 0x1d714f44, 0x1d84fc9b, 0x1d98a7d9, 0x1dac50fe, 0x1dbff80d, 0x1dd39d04,
+	0x1de73fe4, 0x1dfae0ae, 0x1e0e7f62, 0x1e221c00, 0x1e35b68a, 0x1e494eff, 0x1e5ce560, 0x1e7079ad,
+	0x1e840be7, 0x1e979c0f, 0x1eab2a24, 0x1ebeb627, 0x1ed24018, 0x1ee5c7f9, 0x1ef94dc9, 0x1f0cd189,
+	0x1f205339, 0x1f33d2da, 0x1f47506c, 0x1f5acbf0, 0x1f6e4565, 0x1f81bcce, 0x1f953229, 0x1fa8a577,
+	0x1fbc16b9, 0x1fcf85ef, 0x1fe2f31a, 0x1ff65e3a, 0x2009c750, 0x201d2e5b, 0x2030935d, 0x2043f655,
+	0x20575745, 0x206ab62c, 0x207e130b, 0x20916de3, 0x20a4c6b3, 0x20b81d7d, 0x20cb7241, 0x20dec4ff,
+	0x20f215b7, 0x2105646b, 0x2118b11a, 0x212bfbc4, 0x213f446b, 0x21528b0f, 0x2165cfb0, 0x2179124e,
+	0x218c52eb, 0x219f9185, 0x21b2ce1f, 0x21c608b8, 0x21d94150, 0x21ec77e8, 0x21ffac81, 0x2212df1b,
+	0x22260fb6, 0x22393e52, 0x224c6af1, 0x225f9592, 0x2272be37, 0x2285e4de, 0x2299098a, 0x22ac2c39,
+	0x22bf4ced, 0x22d26ba7, 0x22e58865, 0x22f8a32a, 0x230bbbf4, 0x231ed2c5, 0x2331e79e, 0x2344fa7e,
+	0x23580b65, 0x236b1a55, 0x237e274e, 0x2391324f, 0x23a43b5a, 0x23b7426f, 0x23ca478f, 0x23dd4ab9,
+	0x23f04bee, 0x24034b2e, 0x2416487b, 0x242943d4, 0x243c3d39, 0x244f34ac, 0x24622a2c, 0x24751dba,
+	0x24880f56, 0x249aff01, 0x24adecbb, 0x24c0d885, 0x24d3c25e, 0x24e6aa48, 0x24f99043, 0x250c744e,
+	0x251f566b, 0x2532369a, 0x254514dc, 0x2557f12f, 0x256acb96, 0x257da411, 0x25907a9f, 0x25a34f42,
+	0x25b621f9, 0x25c8f2c5, 0x25dbc1a6, 0x25ee8e9e, 0x260159ab, 0x261422cf, 0x2626ea0a, 0x2639af5d,
+	0x264c72c7, 0x265f3449, 0x2671f3e4, 0x2684b198, 0x26976d65, 0x26aa274b, 0x26bcdf4c, 0x26cf9567,
+	0x26e2499d, 0x26f4fbef, 0x2707ac5b, 0x271a5ae4, 0x272d0789, 0x273fb24b, 0x27525b2a, 0x27650227,
+	0x2777a741, 0x278a4a7a, 0x279cebd1, 0x27af8b48, 0x27c228dd, 0x27d4c493, 0x27e75e69, 0x27f9f65f,
+	0x280c8c76, 0x281f20af, 0x2831b309, 0x28444385, 0x2856d224, 0x28695ee5, 0x287be9ca, 0x288e72d2,
+	0x28a0f9fe, 0x28b37f4e, 0x28c602c3, 0x28d8845d, 0x28eb041c, 0x28fd8202, 0x290ffe0d, 0x2922783f,
+	0x2934f098, 0x29476718, 0x2959dbbf, 0x296c4e8f, 0x297ebf87, 0x29912ea8, 0x29a39bf1, 0x29b60765,
+	0x29c87102, 0x29dad8c9, 0x29ed3ebb, 0x29ffa2d8, 0x2a120520, 0x2a246594, 0x2a36c433, 0x2a492100,
+	0x2a5b7bf9, 0x2a6dd51f, 0x2a802c72, 0x2a9281f3, 0x2aa4d5a3, 0x2ab72781, 0x2ac9778e, 0x2adbc5ca,
+	0x2aee1236, 0x2b005cd2, 0x2b12a59f, 0x2b24ec9c, 0x2b3731ca, 0x2b497529, 0x2b5bb6bb, 0x2b6df67e,
+	0x2b803474, 0x2b92709d, 0x2ba4aaf9, 0x2bb6e388, 0x2bc91a4c, 0x2bdb4f44, 0x2bed8270, 0x2bffb3d2,
+	0x2c11e368, 0x2c241135, 0x2c363d37, 0x2c486770, 0x2c5a8fe0, 0x2c6cb687, 0x2c7edb65, 0x2c90fe7b,
+	0x2ca31fc9, 0x2cb53f4f, 0x2cc75d0f, 0x2cd97908, 0x2ceb933a, 0x2cfdaba6, 0x2d0fc24c, 0x2d21d72d,
+	0x2d33ea49, 0x2d45fba0, 0x2d580b33, 0x2d6a1902, 0x2d7c250d, 0x2d8e2f55, 0x2da037d9, 0x2db23e9b,
+	0x2dc4439b, 0x2dd646d9, 0x2de84855, 0x2dfa4810, 0x2e0c460a, 0x2e1e4244, 0x2e303cbd, 0x2e423576,
+	0x2e542c70, 0x2e6621aa, 0x2e781526, 0x2e8a06e3, 0x2e9bf6e1, 0x2eade522, 0x2ebfd1a6, 0x2ed1bc6c,
+	0x2ee3a575, 0x2ef58cc2, 0x2f077252, 0x2f195627, 0x2f2b3840, 0x2f3d189e, 0x2f4ef741, 0x2f60d42a,
+	0x2f72af59, 0x2f8488cd, 0x2f966088, 0x2fa8368a, 0x2fba0ad4, 0x2fcbdd64, 0x2fddae3d, 0x2fef7d5d,
+	0x30014ac6, 0x30131678, 0x3024e073, 0x3036a8b8, 0x30486f46, 0x305a341e, 0x306bf741, 0x307db8af,
+	0x308f7868, 0x30a1366c, 0x30b2f2bc, 0x30c4ad58, 0x30d66641, 0x30e81d76, 0x30f9d2f8, 0x310b86c8,
+	0x311d38e6, 0x312ee951, 0x3140980b, 0x31524514, 0x3163f06c, 0x31759a13, 0x3187420a, 0x3198e850,
+	0x31aa8ce7, 0x31bc2fcf, 0x31cdd108, 0x31df7092, 0x31f10e6d, 0x3202aa9b, 0x3214451b, 0x3225dded,
+	0x32377512, 0x32490a8b, 0x325a9e57, 0x326c3076, 0x327dc0ea, 0x328f4fb3, 0x32a0dcd0, 0x32b26843,
+	0x32c3f20a, 0x32d57a28, 0x32e7009b, 0x32f88565, 0x330a0886, 0x331b89fe, 0x332d09cd, 0x333e87f4,
+	0x33500472, 0x33617f49, 0x3372f879, 0x33847001, 0x3395e5e3, 0x33a75a1e, 0x33b8ccb3, 0x33ca3da2,
+	0x33dbaceb, 0x33ed1a90, 0x33fe868f, 0x340ff0ea, 0x342159a0, 0x3432c0b3, 0x34442621, 0x345589ed,
+	0x3466ec15, 0x34784c9b, 0x3489ab7e, 0x349b08bf, 0x34ac645e, 0x34bdbe5b, 0x34cf16b8, 0x34e06d73,
+	0x34f1c28e, 0x35031608, 0x351467e3, 0x3525b81e, 0x353706b9, 0x354853b6, 0x35599f13, 0x356ae8d2,
+	0x357c30f3, 0x358d7776, 0x359ebc5b, 0x35afffa4, 0x35c1414f, 0x35d2815d, 0x35e3bfcf, 0x35f4fca5,
+	0x360637e0, 0x3617717f, 0x3628a982, 0x3639dfeb, 0x364b14b9, 0x365c47ed, 0x366d7987, 0x367ea988,
+	0x368fd7ee, 0x36a104bc, 0x36b22ff1, 0x36c3598e, 0x36d48192, 0x36e5a7ff, 0x36f6ccd4, 0x3707f011,
+	0x371911b8, 0x372a31c8, 0x373b5041, 0x374c6d25, 0x375d8872, 0x376ea22a, 0x377fba4d, 0x3790d0db,
+	0x37a1e5d4, 0x37b2f939, 0x37c40b09, 0x37d51b46, 0x37e629f0, 0x37f73706, 0x38084289, 0x38194c7a,
+	0x382a54d8, 0x383b5ba5, 0x384c60df, 0x385d6488, 0x386e66a0, 0x387f6727, 0x3890661e, 0x38a16384,
+	0x38b25f5a, 0x38c359a1, 0x38d45258, 0x38e54980, 0x38f63f19, 0x39073323, 0x3918259f, 0x3929168d,
+	0x393a05ee, 0x394af3c1, 0x395be006, 0x396ccabf, 0x397db3eb, 0x398e9b8b, 0x399f819f, 0x39b06628,
+	0x39c14924, 0x39d22a96, 0x39e30a7d, 0x39f3e8d9, 0x3a04c5ab, 0x3a15a0f2, 0x3a267ab0, 0x3a3752e5,
+	0x3a482990, 0x3a58feb2, 0x3a69d24c, 0x3a7aa45d, 0x3a8b74e7, 0x3a9c43e8, 0x3aad1162, 0x3abddd55,
+	0x3acea7c0, 0x3adf70a5, 0x3af03804, 0x3b00fddc, 0x3b11c22f, 0x3b2284fc, 0x3b334644, 0x3b440606,
+	0x3b54c444, 0x3b6580fd, 0x3b763c33, 0x3b86f5e4, 0x3b97ae11, 0x3ba864bc, 0x3bb919e3, 0x3bc9cd87,
+	0x3bda7fa9, 0x3beb3048, 0x3bfbdf65, 0x3c0c8d01, 0x3c1d391b, 0x3c2de3b4, 0x3c3e8ccc, 0x3c4f3463,
+	0x3c5fda7a, 0x3c707f11, 0x3c812228, 0x3c91c3bf, 0x3ca263d7, 0x3cb30270, 0x3cc39f8b, 0x3cd43b26,
+	0x3ce4d544, 0x3cf56de3, 0x3d060505, 0x3d169aaa, 0x3d272ed1, 0x3d37c17b, 0x3d4852a9, 0x3d58e25a,
+	0x3d697090, 0x3d79fd49, 0x3d8a8887, 0x3d9b124a, 0x3dab9a91, 0x3dbc215e, 0x3dcca6b0, 0x3ddd2a88,
+	0x3dedace6, 0x3dfe2dcb, 0x3e0ead36, 0x3e1f2b27, 0x3e2fa7a0, 0x3e4022a0, 0x3e509c28, 0x3e611438,
+	0x3e718acf, 0x3e81fff0, 0x3e927398, 0x3ea2e5ca, 0x3eb35685, 0x3ec3c5c9, 0x3ed43397, 0x3ee49fef,
+	0x3ef50ad2, 0x3f05743e, 0x3f15dc36, 0x3f2642b8, 0x3f36a7c6, 0x3f470b5f, 0x3f576d84, 0x3f67ce35,
+	0x3f782d72, 0x3f888b3c, 0x3f98e792, 0x3fa94276, 0x3fb99be7, 0x3fc9f3e5, 0x3fda4a72, 0x3fea9f8c,
+	0x3ffaf335, 0x400b456c, 0x401b9633, 0x402be588, 0x403c336d, 0x404c7fe1, 0x405ccae5, 0x406d1479,
+	0x407d5c9e, 0x408da353, 0x409de899, 0x40ae2c70, 0x40be6ed9, 0x40ceafd3, 0x40deef5f, 0x40ef2d7e,
+	0x40ff6a2e, 0x410fa572, 0x411fdf48, 0x413017b1, 0x41404eae, 0x4150843e, 0x4160b863, 0x4170eb1b,
+	0x41811c68, 0x41914c49, 0x41a17ac0, 0x41b1a7cb, 0x41c1d36c, 0x41d1fda2, 0x41e2266f, 0x41f24dd1,
+	0x420273ca, 0x42129859, 0x4222bb80, 0x4232dd3d, 0x4242fd92, 0x42531c7f, 0x42633a03, 0x4273561f,
+	0x428370d4, 0x42938a21, 0x42a3a207, 0x42b3b887, 0x42c3cd9f, 0x42d3e151, 0x42e3f39d, 0x42f40483,
+	0x43041403, 0x4314221e, 0x43242ed4, 0x43343a24, 0x43444410, 0x43544c98, 0x436453bb, 0x4374597a,
+	0x43845dd5, 0x439460cd, 0x43a46262, 0x43b46293, 0x43c46162, 0x43d45ece, 0x43e45ad8, 0x43f4557f,
+	0x44044ec5, 0x441446aa, 0x44243d2c, 0x4434324e, 0x4444260f, 0x4454186f, 0x4464096f, 0x4473f90f,
+	0x4483e74e, 0x4493d42e, 0x44a3bfaf, 0x44b3a9d0, 0x44c39293, 0x44d379f6, 0x44e35ffb, 0x44f344a2,
+	0x450327eb, 0x451309d5, 0x4522ea63, 0x4532c993, 0x4542a765, 0x455283db, 0x45625ef5, 0x457238b2,
+	0x45821112, 0x4591e817, 0x45a1bdc0, 0x45b1920e, 0x45c16500, 0x45d13698, 0x45e106d4, 0x45f0d5b6,
+	0x4600a33e, 0x46106f6b, 0x46203a3f, 0x463003b9, 0x463fcbda, 0x464f92a2, 0x465f5810, 0x466f1c26,
+	0x467edee4, 0x468ea049, 0x469e6056, 0x46ae1f0c, 0x46bddc6a, 0x46cd9870, 0x46dd5320, 0x46ed0c78,
+	0x46fcc47a, 0x470c7b26, 0x471c307b, 0x472be47b, 0x473b9724, 0x474b4879, 0x475af878, 0x476aa721,
+	0x477a5476, 0x478a0077, 0x4799ab23, 0x47a9547b, 0x47b8fc7f, 0x47c8a32f, 0x47d8488c, 0x47e7ec96,
+	0x47f78f4c, 0x480730b0, 0x4816d0c1, 0x48266f80, 0x48360ced, 0x4845a908, 0x485543d1, 0x4864dd48,
+	0x4874756f, 0x48840c44, 0x4893a1c9, 0x48a335fd, 0x48b2c8e1, 0x48c25a74, 0x48d1eab8, 0x48e179ac,
+	0x48f10751, 0x490093a6, 0x49101eac, 0x491fa864, 0x492f30cd, 0x493eb7e7, 0x494e3db4, 0x495dc232,
+	0x496d4563, 0x497cc746, 0x498c47dc, 0x499bc725, 0x49ab4521, 0x49bac1d1, 0x49ca3d34, 0x49d9b74b,
+	0x49e93016, 0x49f8a795, 0x4a081dc9, 0x4a1792b2, 0x4a27064f, 0x4a3678a1, 0x4a45e9a9, 0x4a555967,
+	0x4a64c7da, 0x4a743503, 0x4a83a0e2, 0x4a930b78, 0x4aa274c5, 0x4ab1dcc8, 0x4ac14382, 0x4ad0a8f4,
+	0x4ae00d1d, 0x4aef6ffe, 0x4afed197, 0x4b0e31e7, 0x4b1d90f1, 0x4b2ceeb2, 0x4b3c4b2d, 0x4b4ba661,
+	0x4b5b004d, 0x4b6a58f3, 0x4b79b053, 0x4b89066d, 0x4b985b40, 0x4ba7aece, 0x4bb70117, 0x4bc6521a,
+	0x4bd5a1d8, 0x4be4f051, 0x4bf43d85, 0x4c038975, 0x4c12d421, 0x4c221d88, 0x4c3165ac, 0x4c40ac8c,
+	0x4c4ff228, 0x4c5f3682, 0x4c6e7998, 0x4c7dbb6c, 0x4c8cfbfd, 0x4c9c3b4b, 0x4cab7958, 0x4cbab622,
+	0x4cc9f1ab, 0x4cd92bf2, 0x4ce864f8, 0x4cf79cbc, 0x4d06d340, 0x4d160883, 0x4d253c85, 0x4d346f47,
+	0x4d43a0c9, 0x4d52d10b, 0x4d62000e, 0x4d712dd1, 0x4d805a54, 0x4d8f8599, 0x4d9eaf9e, 0x4dadd865,
+	0x4dbcffee, 0x4dcc2638, 0x4ddb4b44, 0x4dea6f12, 0x4df991a3, 0x4e08b2f6, 0x4e17d30c, 0x4e26f1e5,
+	0x4e360f81, 0x4e452be0, 0x4e544703, 0x4e6360ea, 0x4e727994, 0x4e819103, 0x4e90a736, 0x4e9fbc2e,
+	0x4eaecfeb, 0x4ebde26c, 0x4eccf3b3, 0x4edc03bf, 0x4eeb1290, 0x4efa2028, 0x4f092c85, 0x4f1837a8,
+	0x4f274192, 0x4f364a43, 0x4f4551ba, 0x4f5457f8, 0x4f635cfd, 0x4f7260ca, 0x4f81635e, 0x4f9064ba,
+	0x4f9f64de, 0x4fae63cb, 0x4fbd617f, 0x4fcc5dfc, 0x4fdb5942, 0x4fea5351, 0x4ff94c29, 0x500843ca,
+	0x50173a35, 0x50262f69, 0x50352368, 0x50441631, 0x505307c3, 0x5061f821, 0x5070e749, 0x507fd53c,
+	0x508ec1fa, 0x509dad84, 0x50ac97d9, 0x50bb80fa, 0x50ca68e6, 0x50d94f9f, 0x50e83524, 0x50f71975,
+	0x5105fc93, 0x5114de7e, 0x5123bf36, 0x51329ebb, 0x51417d0e, 0x51505a2e, 0x515f361c, 0x516e10d8,
+	0x517cea63, 0x518bc2bb, 0x519a99e3, 0x51a96fd9, 0x51b8449e, 0x51c71832, 0x51d5ea95, 0x51e4bbc8,
+	0x51f38bcb, 0x52025a9e, 0x52112841, 0x521ff4b4, 0x522ebff8, 0x523d8a0c, 0x524c52f1, 0x525b1aa8,
+	0x5269e12f, 0x5278a688, 0x52876ab3, 0x52962daf, 0x52a4ef7e, 0x52b3b01e, 0x52c26f92, 0x52d12dd7,
+	0x52dfeaf0, 0x52eea6db, 0x52fd6199, 0x530c1b2b, 0x531ad391, 0x53298aca, 0x533840d7, 0x5346f5b8,
+	0x5355a96d, 0x53645bf7, 0x53730d55, 0x5381bd88, 0x53906c90, 0x539f1a6e, 0x53adc720, 0x53bc72a9,
+	0x53cb1d07, 0x53d9c63b, 0x53e86e45, 0x53f71525, 0x5405badc, 0x54145f6a, 0x542302ce, 0x5431a509,
+	0x5440461c, 0x544ee606, 0x545d84c8, 0x546c2261, 0x547abed3, 0x54895a1c, 0x5497f43e, 0x54a68d39,
+	0x54b5250c, 0x54c3bbb7, 0x54d2513c, 0x54e0e59b, 0x54ef78d2, 0x54fe0ae4, 0x550c9bcf, 0x551b2b93,
+	0x5529ba33, 0x553847ac, 0x5546d400, 0x55555f2f, 0x5563e938, 0x5572721d, 0x5580f9dc, 0x558f8077,
+	0x559e05ee, 0x55ac8a41, 0x55bb0d6f, 0x55c98f7a, 0x55d81060, 0x55e69024, 0x55f50ec4, 0x56038c41,
+	0x5612089a, 0x562083d1, 0x562efde6, 0x563d76d8, 0x564beea7, 0x565a6555, 0x5668dae1, 0x56774f4b,
+	0x5685c293, 0x569434ba, 0x56a2a5c0, 0x56b115a4, 0x56bf8468, 0x56cdf20c, 0x56dc5e8e, 0x56eac9f1,
+	0x56f93433, 0x57079d55, 0x57160558, 0x57246c3a, 0x5732d1fe, 0x574136a2, 0x574f9a27, 0x575dfc8d,
+	0x576c5dd4, 0x577abdfd, 0x57891d07, 0x57977af3, 0x57a5d7c1, 0x57b43372, 0x57c28e04, 0x57d0e779,
+	0x57df3fd0, 0x57ed970b, 0x57fbed28, 0x580a4229, 0x5818960d, 0x5826e8d4, 0x58353a7f, 0x58438b0e,
+	0x5851da81, 0x586028d8, 0x586e7613, 0x587cc233, 0x588b0d38, 0x58995722, 0x58a79ff0, 0x58b5e7a4,
+	0x58c42e3d, 0x58d273bc, 0x58e0b821, 0x58eefb6b, 0x58fd3d9c, 0x590b7eb2, 0x5919beb0, 0x5927fd93,
+	0x59363b5e, 0x5944780f, 0x5952b3a8, 0x5960ee27, 0x596f278f, 0x597d5fdd, 0x598b9714, 0x5999cd33,
+	0x59a80239, 0x59b63628, 0x59c468ff, 0x59d29abf, 0x59e0cb68, 0x59eefafa, 0x59fd2974, 0x5a0b56d8,
+	0x5a198326, 0x5a27ae5d, 0x5a35d87e, 0x5a440188, 0x5a52297d, 0x5a60505d, 0x5a6e7626, 0x5a7c9ada,
+	0x5a8abe79, 0x5a98e103, 0x5aa70278, 0x5ab522d9, 0x5ac34225, 0x5ad1605c, 0x5adf7d7f, 0x5aed998e,
+	0x5afbb489, 0x5b09ce71, 0x5b17e745, 0x5b25ff05, 0x5b3415b2, 0x5b422b4c, 0x5b503fd4, 0x5b5e5348,
+	0x5b6c65aa, 0x5b7a76f9, 0x5b888736, 0x5b969662, 0x5ba4a47b, 0x5bb2b182, 0x5bc0bd78, 0x5bcec85c,
+	0x5bdcd22f, 0x5beadaf1, 0x5bf8e2a2, 0x5c06e942, 0x5c14eed2, 0x5c22f351, 0x5c30f6bf, 0x5c3ef91e,
+	0x5c4cfa6c, 0x5c5afaab, 0x5c68f9da, 0x5c76f7fa, 0x5c84f50a, 0x5c92f10b, 0x5ca0ebfd, 0x5caee5e0,
+	0x5cbcdeb4, 0x5ccad67a, 0x5cd8cd32, 0x5ce6c2db, 0x5cf4b776, 0x5d02ab03, 0x5d109d83, 0x5d1e8ef5,
+	0x5d2c7f59, 0x5d3a6eb0, 0x5d485cfb, 0x5d564a38, 0x5d643668, 0x5d72218c, 0x5d800ba3, 0x5d8df4ae,
+	0x5d9bdcad, 0x5da9c39f, 0x5db7a986, 0x5dc58e61, 0x5dd37231, 0x5de154f5, 0x5def36ae, 0x5dfd175c,
+	0x5e0af6ff, 0x5e18d598, 0x5e26b326, 0x5e348fa9, 0x5e426b22, 0x5e504591, 0x5e5e1ef6, 0x5e6bf751,
+	0x5e79cea2, 0x5e87a4ea, 0x5e957a29, 0x5ea34e5e, 0x5eb1218b, 0x5ebef3ae, 0x5eccc4c9, 0x5eda94db,
+	0x5ee863e5, 0x5ef631e6, 0x5f03fee0, 0x5f11cad1, 0x5f1f95bb, 0x5f2d5f9d, 0x5f3b2877, 0x5f48f04b,
+	0x5f56b717, 0x5f647cdb, 0x5f72419a, 0x5f800551, 0x5f8dc802, 0x5f9b89ac, 0x5fa94a50, 0x5fb709ee,
+	0x5fc4c886, 0x5fd28618, 0x5fe042a5, 0x5fedfe2c, 0x5ffbb8ad, 0x6009722a, 0x60172aa1, 0x6024e214,
+	0x60329882, 0x60404deb, 0x604e0250, 0x605bb5b0, 0x6069680c, 0x60771965, 0x6084c9b9, 0x6092790a,
+	0x60a02757, 0x60add4a1, 0x60bb80e7, 0x60c92c2b, 0x60d6d66b, 0x60e47fa9, 0x60f227e4, 0x60ffcf1d,
+	0x610d7553, 0x611b1a87, 0x6128beb9, 0x613661e9, 0x61440418, 0x6151a545, 0x615f4570, 0x616ce49a,
+	0x617a82c3, 0x61881feb, 0x6195bc12, 0x61a35738, 0x61b0f15e, 0x61be8a83, 0x61cc22a8, 0x61d9b9cd,
+	0x61e74ff2, 0x61f4e517, 0x6202793c, 0x62100c62, 0x621d9e89, 0x622b2fb0, 0x6238bfd8, 0x62464f02,
+	0x6253dd2c, 0x62616a58, 0x626ef685, 0x627c81b4, 0x628a0be5, 0x62979517, 0x62a51d4c, 0x62b2a483,
+	0x62c02abc, 0x62cdaff8, 0x62db3437, 0x62e8b778, 0x62f639bc, 0x6303bb03, 0x63113b4e, 0x631eba9c,
+	0x632c38ed, 0x6339b642, 0x6347329b, 0x6354adf8, 0x63622859, 0x636fa1be, 0x637d1a28, 0x638a9196,
+	0x63980809, 0x63a57d80, 0x63b2f1fd, 0x63c0657e, 0x63cdd805, 0x63db4991, 0x63e8ba23, 0x63f629bb,
+	0x64039858, 0x641105fb, 0x641e72a5, 0x642bde54, 0x6439490a, 0x6446b2c7, 0x64541b8a, 0x64618354,
+	0x646eea24, 0x647c4ffc, 0x6489b4dc, 0x649718c2, 0x64a47bb0, 0x64b1dda6, 0x64bf3ea4, 0x64cc9ea9,
+	0x64d9fdb7, 0x64e75bcc, 0x64f4b8ea, 0x65021511, 0x650f7040, 0x651cca78, 0x652a23b9, 0x65377c03,
+	0x6544d356, 0x655229b3, 0x655f7f18, 0x656cd388, 0x657a2701, 0x65877984, 0x6594cb12, 0x65a21ba9,
+	0x65af6b4b, 0x65bcb9f7, 0x65ca07ad, 0x65d7546f, 0x65e4a03b, 0x65f1eb12, 0x65ff34f5, 0x660c7de2,
+	0x6619c5db, 0x66270ce0, 0x663452f0, 0x6641980c, 0x664edc34, 0x665c1f68, 0x666961a9, 0x6676a2f6,
+	0x6683e34f, 0x669122b5, 0x669e6127, 0x66ab9ea7, 0x66b8db33, 0x66c616cd, 0x66d35174, 0x66e08b29,
+	0x66edc3eb, 0x66fafbbb, 0x67083298, 0x67156884, 0x67229d7e, 0x672fd186, 0x673d049d, 0x674a36c2,
+	0x675767f5, 0x67649838, 0x6771c789, 0x677ef5ea, 0x678c2359, 0x67994fd9, 0x67a67b67, 0x67b3a605,
+	0x67c0cfb3, 0x67cdf871, 0x67db203f, 0x67e8471d, 0x67f56d0b, 0x68029209, 0x680fb619, 0x681cd939,
+	0x6829fb69, 0x68371cab, 0x68443cfd, 0x68515c61, 0x685e7ad7, 0x686b985d, 0x6878b4f6, 0x6885d0a0,
+	0x6892eb5c, 0x68a0052a, 0x68ad1e0a, 0x68ba35fc, 0x68c74d01, 0x68d46318, 0x68e17842, 0x68ee8c7f,
+	0x68fb9fce, 0x6908b231, 0x6915c3a7, 0x6922d430, 0x692fe3cd, 0x693cf27d, 0x694a0041, 0x69570d19,
+	0x69641904, 0x69712404, 0x697e2e18, 0x698b3741, 0x69983f7e, 0x69a546cf, 0x69b24d35, 0x69bf52b1,
+	0x69cc5741, 0x69d95ae6, 0x69e65da1, 0x69f35f71, 0x6a006056, 0x6a0d6051, 0x6a1a5f62, 0x6a275d89,
+	0x6a345ac6, 0x6a415719, 0x6a4e5282, 0x6a5b4d02, 0x6a684699, 0x6a753f46, 0x6a823709, 0x6a8f2de4,
+	0x6a9c23d6, 0x6aa918df, 0x6ab60cff, 0x6ac30037, 0x6acff287, 0x6adce3ee, 0x6ae9d46d, 0x6af6c403,
+	0x6b03b2b2, 0x6b10a07a, 0x6b1d8d59, 0x6b2a7951, 0x6b376462, 0x6b444e8b, 0x6b5137cd, 0x6b5e2028,
+	0x6b6b079c, 0x6b77ee2a, 0x6b84d3d0, 0x6b91b891, 0x6b9e9c6b, 0x6bab7f5e, 0x6bb8616b, 0x6bc54293,
+	0x6bd222d4, 0x6bdf0230, 0x6bebe0a6, 0x6bf8be37, 0x6c059ae2, 0x6c1276a8, 0x6c1f5188, 0x6c2c2b84,
+	0x6c39049b, 0x6c45dccd, 0x6c52b41a, 0x6c5f8a83, 0x6c6c6008, 0x6c7934a8, 0x6c860864, 0x6c92db3c,
+	0x6c9fad30, 0x6cac7e40, 0x6cb94e6d, 0x6cc61db6, 0x6cd2ec1c, 0x6cdfb99e, 0x6cec863d, 0x6cf951f9,
+	0x6d061cd3, 0x6d12e6c9, 0x6d1fafdd, 0x6d2c780e, 0x6d393f5d, 0x6d4605c9, 0x6d52cb54, 0x6d5f8ffc,
+	0x6d6c53c2, 0x6d7916a7, 0x6d85d8aa, 0x6d9299cb, 0x6d9f5a0a, 0x6dac1969, 0x6db8d7e6, 0x6dc59582,
+	0x6dd2523d, 0x6ddf0e18, 0x6debc911, 0x6df8832a, 0x6e053c63, 0x6e11f4bb, 0x6e1eac33, 0x6e2b62ca,
+	0x6e381882, 0x6e44cd5a, 0x6e518152, 0x6e5e346b, 0x6e6ae6a4, 0x6e7797fd, 0x6e844877, 0x6e90f812,
+	0x6e9da6ce, 0x6eaa54ac, 0x6eb701aa, 0x6ec3adca, 0x6ed0590b, 0x6edd036d, 0x6ee9acf2, 0x6ef65598,
+	0x6f02fd60, 0x6f0fa44a, 0x6f1c4a56, 0x6f28ef85, 0x6f3593d5, 0x6f423749, 0x6f4ed9df, 0x6f5b7b98,
+	0x6f681c73, 0x6f74bc72, 0x6f815b93, 0x6f8df9d8, 0x6f9a9740, 0x6fa733cc, 0x6fb3cf7b, 0x6fc06a4e,
+	0x6fcd0445, 0x6fd99d60, 0x6fe6359e, 0x6ff2cd01, 0x6fff6388, 0x700bf934, 0x70188e04, 0x702521f9,
+	0x7031b512, 0x703e4750, 0x704ad8b3, 0x7057693c, 0x7063f8e9, 0x707087bc, 0x707d15b4, 0x7089a2d2,
+	0x70962f16, 0x70a2ba7f, 0x70af450e, 0x70bbcec3, 0x70c8579f, 0x70d4dfa0, 0x70e166c8, 0x70eded17,
+	0x70fa728c, 0x7106f727, 0x71137aea, 0x711ffdd3, 0x712c7fe4, 0x7139011c, 0x7145817b, 0x71520101,
+	0x715e7faf, 0x716afd84, 0x71777a82, 0x7183f6a7, 0x719071f4, 0x719cec69, 0x71a96606, 0x71b5decc,
+	0x71c256ba, 0x71cecdd1, 0x71db4410, 0x71e7b978, 0x71f42e09, 0x7200a1c3, 0x720d14a6, 0x721986b3,
+	0x7225f7e8, 0x72326847, 0x723ed7d0, 0x724b4682, 0x7257b45e, 0x72642164, 0x72708d94, 0x727cf8ee,
+	0x72896373, 0x7295cd21, 0x72a235fa, 0x72ae9dfe, 0x72bb052d, 0x72c76b86, 0x72d3d10a, 0x72e035b9,
+	0x72ec9993, 0x72f8fc99, 0x73055eca, 0x7311c026, 0x731e20ae, 0x732a8061, 0x7336df41, 0x73433d4c,
+	0x734f9a83, 0x735bf6e7, 0x73685276, 0x7374ad32, 0x7381071b, 0x738d6030, 0x7399b871, 0x73a60fe0,
+	0x73b2667b, 0x73bebc44, 0x73cb1139, 0x73d7655c, 0x73e3b8ac, 0x73f00b2a, 0x73fc5cd5, 0x7408adae,
+	0x7414fdb5, 0x74214ce9, 0x742d9b4c, 0x7439e8dc, 0x7446359b, 0x74528188, 0x745ecca4, 0x746b16ee,
+	0x74776067, 0x7483a90e, 0x748ff0e5, 0x749c37ea, 0x74a87e1f, 0x74b4c383, 0x74c10816, 0x74cd4bd8,
+	0x74d98eca, 0x74e5d0ec, 0x74f2123d, 0x74fe52be, 0x750a926f, 0x7516d150, 0x75230f62, 0x752f4ca4,
+	0x753b8916, 0x7547c4b8, 0x7553ff8b, 0x7560398f, 0x756c72c4, 0x7578ab29, 0x7584e2c0, 0x75911988,
+	0x759d4f81, 0x75a984ab, 0x75b5b907, 0x75c1ec94, 0x75ce1f53, 0x75da5144, 0x75e68267, 0x75f2b2bc,
+	0x75fee242, 0x760b10fb, 0x76173ee7, 0x76236c04, 0x762f9855, 0x763bc3d8, 0x7647ee8d, 0x76541876,
+	0x76604191, 0x766c69df, 0x76789161, 0x7684b816, 0x7690ddfe, 0x769d031a, 0x76a92769, 0x76b54aec,
+	0x76c16da3, 0x76cd8f8d, 0x76d9b0ac, 0x76e5d0fe, 0x76f1f085, 0x76fe0f41, 0x770a2d30, 0x77164a54,
+	0x772266ad, 0x772e823a, 0x773a9cfd, 0x7746b6f4, 0x7752d020, 0x775ee882, 0x776b0018, 0x777716e4,
+	0x77832ce6, 0x778f421d, 0x779b5689, 0x77a76a2c, 0x77b37d04, 0x77bf8f12, 0x77cba057, 0x77d7b0d1,
+	0x77e3c082, 0x77efcf69, 0x77fbdd87, 0x7807eadb, 0x7813f766, 0x78200328, 0x782c0e20, 0x78381850,
+	0x784421b7, 0x78502a55, 0x785c322a, 0x78683936, 0x78743f7b, 0x788044f6, 0x788c49aa, 0x78984d95,
+	0x78a450b8, 0x78b05313, 0x78bc54a7, 0x78c85572, 0x78d45576, 0x78e054b2, 0x78ec5327, 0x78f850d5,
+	0x79044dbb, 0x791049da, 0x791c4532, 0x79283fc3, 0x7934398d, 0x79403290, 0x794c2acc, 0x79582243,
+	0x796418f2, 0x79700edb, 0x797c03fe, 0x7987f85b, 0x7993ebf2, 0x799fdec3, 0x79abd0cd, 0x79b7c213,
+	0x79c3b292, 0x79cfa24c, 0x79db9140, 0x79e77f6f, 0x79f36cd9, 0x79ff597e, 0x7a0b455d, 0x7a173078,
+	0x7a231ace, 0x7a2f045e, 0x7a3aed2b, 0x7a46d532, 0x7a52bc76, 0x7a5ea2f4, 0x7a6a88af, 0x7a766da5,
+	0x7a8251d8, 0x7a8e3546, 0x7a9a17f0, 0x7aa5f9d7, 0x7ab1dafa, 0x7abdbb5a, 0x7ac99af5, 0x7ad579ce,
+	0x7ae157e3, 0x7aed3535, 0x7af911c4, 0x7b04ed90, 0x7b10c899, 0x7b1ca2df, 0x7b287c63, 0x7b345524,
+	0x7b402d22, 0x7b4c045e, 0x7b57dad8, 0x7b63b08f, 0x7b6f8585, 0x7b7b59b8, 0x7b872d29, 0x7b92ffd9,
+	0x7b9ed1c7, 0x7baaa2f3, 0x7bb6735e, 0x7bc24307, 0x7bce11ef, 0x7bd9e015, 0x7be5ad7b, 0x7bf17a1f,
+	0x7bfd4603, 0x7c091125, 0x7c14db87, 0x7c20a528, 0x7c2c6e09, 0x7c383629, 0x7c43fd89, 0x7c4fc428,
+	0x7c5b8a07, 0x7c674f27, 0x7c731386, 0x7c7ed725, 0x7c8a9a04, 0x7c965c24, 0x7ca21d84, 0x7cadde25,
+	0x7cb99e06, 0x7cc55d28, 0x7cd11b8a, 0x7cdcd92e, 0x7ce89612, 0x7cf45238, 0x7d000d9e, 0x7d0bc846,
+	0x7d17822f, 0x7d233b5a, 0x7d2ef3c6, 0x7d3aab74, 0x7d466263, 0x7d521894, 0x7d5dce07, 0x7d6982bd,
+	0x7d7536b4, 0x7d80e9ed, 0x7d8c9c69, 0x7d984e27, 0x7da3ff27, 0x7dafaf6a, 0x7dbb5ef0, 0x7dc70db8,
+	0x7dd2bbc4, 0x7dde6912, 0x7dea15a3, 0x7df5c178, 0x7e016c8f, 0x7e0d16ea, 0x7e18c088, 0x7e24696a,
+	0x7e30118f, 0x7e3bb8f8, 0x7e475fa5, 0x7e530596, 0x7e5eaaca, 0x7e6a4f43, 0x7e75f300, 0x7e819601,
+	0x7e8d3846, 0x7e98d9d0, 0x7ea47a9e, 0x7eb01ab1, 0x7ebbba08, 0x7ec758a5, 0x7ed2f686, 0x7ede93ac,
+	0x7eea3017, 0x7ef5cbc7, 0x7f0166bd, 0x7f0d00f8, 0x7f189a78, 0x7f24333e, 0x7f2fcb4a, 0x7f3b629b,
+	0x7f46f932, 0x7f528f0f, 0x7f5e2432, 0x7f69b89b, 0x7f754c4a, 0x7f80df3f, 0x7f8c717b, 0x7f9802fd,
+	0x7fa393c5, 0x7faf23d5, 0x7fbab32b, 0x7fc641c7, 0x7fd1cfab, 0x7fdd5cd6, 0x7fe8e947, 0x7ff47500,
+	0x80000000,
+}
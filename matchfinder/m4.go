@@ -45,14 +45,14 @@ type M4 struct {
 	table []uint32
 	chain []uint32
 
-	history []byte
+	h history
 }
 
 func (q *M4) Reset() {
 	for i := range q.table {
 		q.table[i] = 0
 	}
-	q.history = q.history[:0]
+	q.h.reset()
 	q.chain = q.chain[:0]
 }
 
@@ -79,32 +79,19 @@ func (q *M4) FindMatches(dst []Match, src []byte) []Match {
 
 	e := matchEmitter{Dst: dst}
 
-	if len(q.history) > q.MaxDistance*2 {
-		// Trim down the history buffer.
-		delta := len(q.history) - q.MaxDistance
-		copy(q.history, q.history[delta:])
-		q.history = q.history[:q.MaxDistance]
-		if q.ChainLength > 0 {
-			copy(q.chain, q.chain[delta:])
-			q.chain = q.chain[:q.MaxDistance]
-		}
-
-		for i, v := range q.table {
-			newV := int(v) - delta
-			if newV < 0 {
-				newV = 0
-			}
-			q.table[i] = uint32(newV)
-		}
+	// Trim down the history buffer.
+	if delta := q.h.trim(q.MaxDistance); delta > 0 && q.ChainLength > 0 {
+		copy(q.chain, q.chain[delta:])
+		q.chain = q.chain[:q.MaxDistance]
 	}
 
 	// Append src to the history buffer.
-	e.NextEmit = len(q.history)
-	q.history = append(q.history, src...)
+	e.NextEmit = len(q.h.buf)
+	q.h.buf = append(q.h.buf, src...)
 	if q.ChainLength > 0 {
 		q.chain = append(q.chain, make([]uint32, len(src))...)
 	}
-	src = q.history
+	src = q.h.buf
 
 	// matches stores the matches that have been found but not emitted,
 	// in reverse order. (matches[0] is the most recent one.)
@@ -139,8 +126,8 @@ func (q *M4) FindMatches(dst []Match, src []byte) []Match {
 
 		// Calculate and store the hash.
 		h := ((binary.LittleEndian.Uint64(src[i:]) & (1<<(8*q.HashLen) - 1)) * hashMul64) >> (64 - q.TableBits)
-		candidate := int(q.table[h])
-		q.table[h] = uint32(i)
+		candidate := q.h.index(q.table[h], i)
+		q.table[h] = q.h.pos(i)
 		if q.ChainLength > 0 && candidate != 0 {
 			delta := i - candidate
 			q.chain[i] = uint32(delta)
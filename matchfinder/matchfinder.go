@@ -10,7 +10,10 @@
 // representation to allow mixing and matching compression components.
 package matchfinder
 
-import "io"
+import (
+	"io"
+	"time"
+)
 
 // A Match is the basic unit of LZ77 compression.
 type Match struct {
@@ -50,54 +53,197 @@ type Writer struct {
 	// each Write operation will be treated as one block.
 	BlockSize int
 
-	err     error
-	inBuf   []byte
-	outBuf  []byte
-	matches []Match
+	// BlockSplitter, if it is not nil, chooses where each block ends,
+	// instead of always using blocks of BlockSize bytes. It is given
+	// BlockSize bytes at a time (or what is left at the end of the
+	// stream), and it can make blocks shorter, to put the boundaries
+	// where the content changes. It is not used if BlockSize is zero.
+	BlockSplitter BlockSplitter
+
+	// Pipeline, if positive, makes the Writer run the Encoder and write to
+	// Dest on a separate goroutine, so that finding matches in one block
+	// overlaps with encoding the previous one. It is the number of blocks
+	// that can be waiting to be encoded before Write blocks.
+	//
+	// In pipelined mode, errors from Dest may not be returned until a
+	// later call to Write or Close, and Close must be called (or the Writer
+	// Reset) to stop the goroutine. The output is the same as without
+	// pipelining.
+	Pipeline int
+
+	// Filter, if it is not nil, transforms the data before it is divided
+	// into blocks and searched for matches. The Encoder needs to record
+	// which filter was used, so that the decoder can reverse it.
+	Filter Filter
+
+	err       error
+	inBuf     []byte
+	filterBuf []byte
+	outBuf    []byte
+	matches   []Match
+	stats     Stats
+	pipe      *pipeline
+}
+
+// Stats holds statistics about the data a compressor has processed.
+type Stats struct {
+	BytesIn  int64 // the number of uncompressed bytes written
+	BytesOut int64 // the number of compressed bytes produced
+	Blocks   int64 // the number of blocks compressed
+
+	Literals int64 // the number of bytes that were not part of a match
+	Commands int64 // the number of Matches, including ones with only literals
+	Matches  int64 // the number of Matches with a non-zero Length
+
+	MatchLength   int64 // the total Length of all the matches
+	MatchDistance int64 // the total Distance of all the matches
+
+	MatchFindingTime time.Duration // time spent looking for matches
+	EncodingTime     time.Duration // time spent on entropy coding
+}
+
+// AverageMatchLength returns the mean length of the matches found.
+func (s Stats) AverageMatchLength() float64 {
+	if s.Matches == 0 {
+		return 0
+	}
+	return float64(s.MatchLength) / float64(s.Matches)
+}
+
+// AverageMatchDistance returns the mean distance of the matches found.
+func (s Stats) AverageMatchDistance() float64 {
+	if s.Matches == 0 {
+		return 0
+	}
+	return float64(s.MatchDistance) / float64(s.Matches)
+}
+
+// Stats returns statistics about the data w has compressed since it was
+// created or last Reset.
+func (w *Writer) Stats() Stats {
+	if w.pipe != nil {
+		w.pipe.mu.Lock()
+		defer w.pipe.mu.Unlock()
+	}
+	return w.stats
 }
 
 func (w *Writer) Write(p []byte) (n int, err error) {
-	if w.err != nil {
-		return 0, w.err
+	if err := w.getErr(); err != nil {
+		return 0, err
+	}
+
+	w.stats.BytesIn += int64(len(p))
+	n = len(p)
+
+	if w.Filter != nil {
+		w.filterBuf = w.Filter.Encode(w.filterBuf[:0], p, false)
+		p = w.filterBuf
+		if len(p) == 0 {
+			return n, nil
+		}
 	}
 
 	if w.BlockSize == 0 {
-		return w.writeBlock(p, false)
+		_, err = w.writeBlock(p, false)
+		return n, err
 	}
 
 	w.inBuf = append(w.inBuf, p...)
+	w.writeFullBlocks()
+	return n, w.getErr()
+}
+
+// writeFullBlocks writes blocks from w.inBuf until less than BlockSize
+// bytes are left.
+func (w *Writer) writeFullBlocks() {
 	var pos int
-	for pos = 0; pos+w.BlockSize <= len(w.inBuf) && w.err == nil; pos += w.BlockSize {
-		w.writeBlock(w.inBuf[pos:pos+w.BlockSize], false)
+	for pos = 0; pos+w.BlockSize <= len(w.inBuf) && w.getErr() == nil; {
+		n := w.blockLength(w.inBuf[pos : pos+w.BlockSize])
+		w.writeBlock(w.inBuf[pos:pos+n], false)
+		pos += n
 	}
 	if pos > 0 {
 		n := copy(w.inBuf, w.inBuf[pos:])
 		w.inBuf = w.inBuf[:n]
 	}
+}
 
-	return len(p), w.err
+// blockLength returns the length of the next block to write from p.
+func (w *Writer) blockLength(p []byte) int {
+	if w.BlockSplitter == nil {
+		return len(p)
+	}
+	return min(max(w.BlockSplitter.Split(p), 1), len(p))
 }
 
 func (w *Writer) writeBlock(p []byte, lastBlock bool) (n int, err error) {
+	if w.Pipeline > 0 {
+		return w.writeBlockPipelined(p, lastBlock)
+	}
+
 	w.outBuf = w.outBuf[:0]
+	start := time.Now()
 	w.matches = w.MatchFinder.FindMatches(w.matches[:0], p)
+	found := time.Now()
 	w.outBuf = w.Encoder.Encode(w.outBuf, p, w.matches, lastBlock)
-	_, w.err = w.Dest.Write(w.outBuf)
+	w.stats.EncodingTime += time.Since(found)
+	w.stats.MatchFindingTime += found.Sub(start)
+	w.stats.addMatches(w.matches)
+	w.stats.Blocks++
+
+	var written int
+	written, w.err = w.Dest.Write(w.outBuf)
+	w.stats.BytesOut += int64(written)
 	return len(p), w.err
 }
 
+func (s *Stats) addMatches(matches []Match) {
+	for _, m := range matches {
+		s.Literals += int64(m.Unmatched)
+		if m.Length > 0 {
+			s.Matches++
+			s.MatchLength += int64(m.Length)
+			s.MatchDistance += int64(m.Distance)
+		}
+	}
+	s.Commands += int64(len(matches))
+}
+
 func (w *Writer) Close() error {
-	w.writeBlock(w.inBuf, true)
+	if w.Filter != nil {
+		w.inBuf = w.Filter.Encode(w.inBuf, nil, true)
+		if w.BlockSize > 0 {
+			w.writeFullBlocks()
+		}
+	}
+
+	rest := w.inBuf
+	for len(rest) > 0 && w.getErr() == nil {
+		n := w.blockLength(rest)
+		if n == len(rest) {
+			break
+		}
+		w.writeBlock(rest[:n], false)
+		rest = rest[n:]
+	}
+	w.writeBlock(rest, true)
 	w.inBuf = w.inBuf[:0]
+	w.stopPipeline(false)
 	return w.err
 }
 
 func (w *Writer) Reset(newDest io.Writer) {
+	w.stopPipeline(true)
 	w.MatchFinder.Reset()
 	w.Encoder.Reset()
+	if w.Filter != nil {
+		w.Filter.Reset()
+	}
 	w.err = nil
 	w.inBuf = w.inBuf[:0]
 	w.outBuf = w.outBuf[:0]
 	w.matches = w.matches[:0]
+	w.stats = Stats{}
 	w.Dest = newDest
 }
@@ -0,0 +1,253 @@
+package matchfinder
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"math"
+)
+
+// The match stream format records the blocks passed to an Encoder. Each
+// block is stored as:
+//   - the length of the block (uvarint)
+//   - a flags byte (1 if it is the last block)
+//   - the number of matches (uvarint)
+//   - for each match, Unmatched, Length, and (if Length > 0) Distance
+//     (uvarints)
+//   - the literal bytes: the bytes of the block that are not covered by
+//     matches, in order
+
+const matchStreamLastBlock = 1
+
+// ErrCorruptMatchStream is returned when a match stream can't be parsed.
+var ErrCorruptMatchStream = errors.New("matchfinder: corrupt match stream")
+
+// A MatchStreamEncoder is an Encoder that records the matches and literals
+// in a compact binary format instead of compressing them. The recording can
+// be read back with a MatchStreamReader, to run several Encoders on the
+// output of one MatchFinder, or with a ReplayMatchFinder.
+type MatchStreamEncoder struct{}
+
+func (MatchStreamEncoder) Reset() {}
+
+func (MatchStreamEncoder) Encode(dst []byte, src []byte, matches []Match, lastBlock bool) []byte {
+	dst = binary.AppendUvarint(dst, uint64(len(src)))
+	var flags byte
+	if lastBlock {
+		flags |= matchStreamLastBlock
+	}
+	dst = append(dst, flags)
+	dst = binary.AppendUvarint(dst, uint64(len(matches)))
+	for _, m := range matches {
+		dst = binary.AppendUvarint(dst, uint64(m.Unmatched))
+		dst = binary.AppendUvarint(dst, uint64(m.Length))
+		if m.Length > 0 {
+			dst = binary.AppendUvarint(dst, uint64(m.Distance))
+		}
+	}
+
+	pos := 0
+	for _, m := range matches {
+		dst = append(dst, src[pos:pos+m.Unmatched]...)
+		pos += m.Unmatched + m.Length
+	}
+	if pos < len(src) {
+		dst = append(dst, src[pos:]...)
+	}
+	return dst
+}
+
+// matchStreamBlock is a block parsed from a match stream.
+type matchStreamBlock struct {
+	length    int
+	lastBlock bool
+	matches   []Match
+	literals  []byte
+}
+
+// parseMatchStreamBlock parses the block at the start of stream, appending
+// its matches to b.matches, and returns the rest of the stream.
+func parseMatchStreamBlock(b *matchStreamBlock, stream []byte) ([]byte, error) {
+	uvarint := func() int {
+		v, n := binary.Uvarint(stream)
+		if n <= 0 || v > math.MaxInt32 {
+			stream = nil
+			return -1
+		}
+		stream = stream[n:]
+		return int(v)
+	}
+
+	b.length = uvarint()
+	if b.length < 0 || len(stream) == 0 {
+		return nil, ErrCorruptMatchStream
+	}
+	b.lastBlock = stream[0]&matchStreamLastBlock != 0
+	stream = stream[1:]
+	n := uvarint()
+	if n < 0 {
+		return nil, ErrCorruptMatchStream
+	}
+
+	literals := b.length
+	covered := 0
+	for range n {
+		var m Match
+		m.Unmatched = uvarint()
+		m.Length = uvarint()
+		if m.Length > 0 {
+			m.Distance = uvarint()
+		}
+		if m.Unmatched < 0 || m.Length < 0 || m.Distance < 0 {
+			return nil, ErrCorruptMatchStream
+		}
+		covered += m.Unmatched + m.Length
+		if covered > b.length {
+			return nil, ErrCorruptMatchStream
+		}
+		literals -= m.Length
+		b.matches = append(b.matches, m)
+	}
+
+	if len(stream) < literals {
+		return nil, ErrCorruptMatchStream
+	}
+	b.literals = stream[:literals]
+	return stream[literals:], nil
+}
+
+// A MatchStreamReader reads the blocks recorded by a MatchStreamEncoder,
+// reconstructing the original data.
+type MatchStreamReader struct {
+	stream  []byte
+	history []byte
+	block   matchStreamBlock
+}
+
+// NewMatchStreamReader returns a MatchStreamReader that reads the match
+// stream in stream.
+func NewMatchStreamReader(stream []byte) *MatchStreamReader {
+	return &MatchStreamReader{stream: stream}
+}
+
+// Next returns the data and matches of the next block in the stream, and
+// whether it is the last block. The slices are only valid until the next
+// call to Next. At the end of the stream, it returns io.EOF.
+func (r *MatchStreamReader) Next() (src []byte, matches []Match, lastBlock bool, err error) {
+	if len(r.stream) == 0 {
+		return nil, nil, false, io.EOF
+	}
+
+	b := &r.block
+	b.matches = b.matches[:0]
+	rest, err := parseMatchStreamBlock(b, r.stream)
+	if err != nil {
+		r.stream = nil
+		return nil, nil, false, err
+	}
+	r.stream = rest
+
+	// The history is kept for the whole stream, since the match stream
+	// doesn't record the maximum distance.
+	start := len(r.history)
+	literals := b.literals
+	for _, m := range b.matches {
+		r.history = append(r.history, literals[:m.Unmatched]...)
+		literals = literals[m.Unmatched:]
+		pos := len(r.history) - m.Distance
+		if m.Length > 0 && (pos < 0 || m.Distance == 0) {
+			r.stream = nil
+			return nil, nil, false, ErrCorruptMatchStream
+		}
+		for i := range m.Length {
+			r.history = append(r.history, r.history[pos+i])
+		}
+	}
+	r.history = append(r.history, literals...)
+
+	return r.history[start:], b.matches, b.lastBlock, nil
+}
+
+// A ReplayMatchFinder is a MatchFinder that returns the matches recorded
+// in a match stream by a MatchStreamEncoder, instead of searching for them.
+// It must be used with the same data and block size as the recording.
+//
+// Each block's matches are checked against the data, as in Checked. If the
+// stream is corrupt or doesn't match the data, ReplayMatchFinder sets Err,
+// and returns only literals from then on.
+type ReplayMatchFinder struct {
+	// Stream is the match stream to replay.
+	Stream []byte
+
+	// MaxDistance is the maximum distance that a match may have.
+	// If it is zero, any distance within the data seen so far is allowed,
+	// and ReplayMatchFinder keeps the entire stream in memory.
+	MaxDistance int
+
+	// Err is the first error encountered in replaying Stream.
+	Err error
+
+	pos    int
+	block  matchStreamBlock
+	h      history
+	blocks int
+}
+
+func (r *ReplayMatchFinder) Reset() {
+	r.pos = 0
+	r.Err = nil
+	r.h.reset()
+	r.blocks = 0
+}
+
+func (r *ReplayMatchFinder) FindMatches(dst []Match, src []byte) []Match {
+	if r.Err == nil {
+		r.Err = r.next(src)
+	}
+	if r.Err != nil {
+		return append(dst, Match{Unmatched: len(src)})
+	}
+	return append(dst, r.block.matches...)
+}
+
+// next reads the next block from r.Stream, and checks that its literals
+// and matches agree with src.
+func (r *ReplayMatchFinder) next(src []byte) error {
+	if r.pos == len(r.Stream) {
+		return errors.New("matchfinder: match stream has fewer blocks than the data")
+	}
+	b := &r.block
+	b.matches = b.matches[:0]
+	rest, err := parseMatchStreamBlock(b, r.Stream[r.pos:])
+	if err != nil {
+		return err
+	}
+	r.pos = len(r.Stream) - len(rest)
+
+	if b.length != len(src) {
+		return errors.New("matchfinder: match stream block size doesn't match the data")
+	}
+	pos := 0
+	literals := b.literals
+	for _, m := range b.matches {
+		if string(src[pos:pos+m.Unmatched]) != string(literals[:m.Unmatched]) {
+			return errors.New("matchfinder: match stream literals don't match the data")
+		}
+		literals = literals[m.Unmatched:]
+		pos += m.Unmatched + m.Length
+	}
+	if string(src[pos:]) != string(literals) {
+		return errors.New("matchfinder: match stream literals don't match the data")
+	}
+
+	if r.MaxDistance > 0 {
+		r.h.trim(r.MaxDistance)
+	}
+	historyLen := len(r.h.buf)
+	r.h.buf = append(r.h.buf, src...)
+	if err := checkMatches(b.matches, r.h.buf, historyLen, r.MaxDistance, r.blocks); err != nil {
+		return err
+	}
+	r.blocks++
+	return nil
+}
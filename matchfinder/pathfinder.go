@@ -2,8 +2,6 @@ package matchfinder
 
 import (
 	"encoding/binary"
-	"math"
-	"math/bits"
 	"slices"
 )
 
@@ -30,10 +28,15 @@ type Pathfinder struct {
 	// locations with the same hash as the current location.
 	ChainLength int
 
+	// CostModel estimates the number of bits needed to encode literals and
+	// matches. The default is a generic model based on the logarithms of the
+	// lengths and distances.
+	CostModel CostModel
+
 	table []uint32
 	chain []uint32
 
-	history []byte
+	h history
 
 	// holding onto buffers to reduce allocations:
 
@@ -46,7 +49,7 @@ func (q *Pathfinder) Reset() {
 	for i := range q.table {
 		q.table[i] = 0
 	}
-	q.history = q.history[:0]
+	q.h.reset()
 	q.chain = q.chain[:0]
 }
 
@@ -81,15 +84,12 @@ func (q *Pathfinder) FindMatches(dst []Match, src []byte) []Match {
 		q.table = make([]uint32, 1<<q.TableBits)
 	}
 
-	var histogram [256]uint32
-	for _, b := range src {
-		histogram[b]++
+	costs := q.CostModel
+	if costs == nil {
+		costs = defaultCostModel{shortMatchPenalty: true}
 	}
 	var byteCost [256]float32
-	for b, n := range histogram {
-		cost := max(math.Log2(float64(len(src))/float64(n)), 1)
-		byteCost[b] = float32(cost)
-	}
+	costs.LiteralCosts(&byteCost, src)
 
 	// Each element in arrivals corresponds to the position just after
 	// the corresponding byte in src.
@@ -104,30 +104,23 @@ func (q *Pathfinder) FindMatches(dst []Match, src []byte) []Match {
 		}
 	}
 
-	if len(q.history) > q.MaxDistance*2 {
-		// Trim down the history buffer.
-		delta := len(q.history) - q.MaxDistance
-		copy(q.history, q.history[delta:])
-		q.history = q.history[:q.MaxDistance]
+	// Trim down the history buffer.
+	if delta := q.h.trim(q.MaxDistance); delta > 0 {
+		copy(q.chain, q.chain[delta:])
 		q.chain = q.chain[:q.MaxDistance]
-
-		for i, v := range q.table {
-			newV := max(int(v)-delta, 0)
-			q.table[i] = uint32(newV)
-		}
 	}
 
 	// Append src to the history buffer.
-	historyLen := len(q.history)
-	q.history = append(q.history, src...)
+	historyLen := len(q.h.buf)
+	q.h.buf = append(q.h.buf, src...)
 	q.chain = append(q.chain, make([]uint32, len(src))...)
-	src = q.history
+	src = q.h.buf
 
 	// Calculate hashes and build the chain.
 	for i := historyLen; i < len(src)-7; i++ {
 		h := ((binary.LittleEndian.Uint64(src[i:]) & (1<<(8*q.HashLen) - 1)) * hashMul64) >> (64 - q.TableBits)
-		candidate := int(q.table[h])
-		q.table[h] = uint32(i)
+		candidate := q.h.index(q.table[h], i)
+		q.table[h] = q.h.pos(i)
 		if candidate != 0 {
 			delta := i - candidate
 			q.chain[i] = uint32(delta)
@@ -212,7 +205,7 @@ func (q *Pathfinder) FindMatches(dst []Match, src []byte) []Match {
 
 	q.foundMatches = foundMatches
 
-	slices.SortFunc(foundMatches, func(a, b absoluteMatch) int { return a.Start - b.Start })
+	slices.SortStableFunc(foundMatches, func(a, b absoluteMatch) int { return a.Start - b.Start })
 	matchIndex := 0
 	var pending absoluteMatch
 
@@ -246,17 +239,9 @@ func (q *Pathfinder) FindMatches(dst []Match, src []byte) []Match {
 			if m.End > pending.End {
 				pending = m
 			}
-			matchCost := baseMatchCost + float32(bits.Len(uint(unmatched)))
-			if m.Start-m.Match != prevDistance {
-				matchCost += float32(bits.Len(uint(m.Start - m.Match)))
-			}
+			matchCost := costs.InsertCost(unmatched) + costs.DistanceCost(m.Start-m.Match, m.Start-m.Match == prevDistance)
 			for j := m.Start + q.MinLength; j <= m.End; j++ {
-				adjustedCost := matchCost
-				if j-m.Start < 6 {
-					// Matches shorter than 6 are comparatively rare, and therefore
-					// have longer codes.
-					adjustedCost += float32(6-(j-m.Start)) * 2
-				}
+				adjustedCost := matchCost + costs.LengthCost(j-m.Start)
 				a := &arrivals[j-historyLen-1]
 				if a.cost == 0 || arrivedHere.cost+adjustedCost < a.cost {
 					*a = arrival{
@@ -272,14 +257,9 @@ func (q *Pathfinder) FindMatches(dst []Match, src []byte) []Match {
 		// position, try using the tail of it, starting from here.
 		if unmatched == 0 && pending.Start != i && pending.End >= i+q.MinLength &&
 			!(arrivedHere.length != 0 && arrivedHere.distance == uint32(pending.Start-pending.Match)) {
-			matchCost := baseMatchCost + float32(bits.Len(uint(pending.Start-pending.Match)))
+			matchCost := costs.InsertCost(0) + costs.DistanceCost(pending.Start-pending.Match, false)
 			for j := i + q.MinLength; j <= pending.End; j++ {
-				adjustedCost := matchCost
-				if j-i < 6 {
-					// Matches shorter than 6 are comparatively rare, and therefore
-					// have longer codes.
-					adjustedCost += float32(6-(j-i)) * 2
-				}
+				adjustedCost := matchCost + costs.LengthCost(j-i)
 				a := &arrivals[j-historyLen-1]
 				if a.cost == 0 || arrivedHere.cost+adjustedCost < a.cost {
 					*a = arrival{
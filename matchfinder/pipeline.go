@@ -0,0 +1,131 @@
+package matchfinder
+
+import (
+	"sync"
+	"time"
+)
+
+// A pipeline runs the Encoder and writes to Dest on a separate goroutine,
+// so that match finding for the next block can overlap with encoding the
+// current one.
+type pipeline struct {
+	blocks  chan *pipelineBlock // blocks waiting to be encoded
+	recycle chan *pipelineBlock // blocks that can be reused
+	done    chan struct{}       // closed when the encoding goroutine exits
+
+	// mu protects the Writer's err and stats while the pipeline is running.
+	mu sync.Mutex
+
+	// discard is set by Reset to tell the encoding goroutine to drop the
+	// blocks that are still waiting.
+	discard bool
+}
+
+type pipelineBlock struct {
+	src       []byte
+	matches   []Match
+	lastBlock bool
+}
+
+func (w *Writer) startPipeline() {
+	p := &pipeline{
+		blocks:  make(chan *pipelineBlock, w.Pipeline),
+		recycle: make(chan *pipelineBlock, w.Pipeline+2),
+		done:    make(chan struct{}),
+	}
+	w.pipe = p
+	go w.encodeBlocks(p)
+}
+
+// encodeBlocks is the main loop of the encoding goroutine.
+func (w *Writer) encodeBlocks(p *pipeline) {
+	defer close(p.done)
+	var outBuf []byte
+	for b := range p.blocks {
+		p.mu.Lock()
+		skip := p.discard || w.err != nil
+		p.mu.Unlock()
+
+		if !skip {
+			start := time.Now()
+			outBuf = w.Encoder.Encode(outBuf[:0], b.src, b.matches, b.lastBlock)
+			encodingTime := time.Since(start)
+			written, err := w.Dest.Write(outBuf)
+
+			p.mu.Lock()
+			w.stats.EncodingTime += encodingTime
+			w.stats.BytesOut += int64(written)
+			if err != nil && w.err == nil {
+				w.err = err
+			}
+			p.mu.Unlock()
+		}
+
+		select {
+		case p.recycle <- b:
+		default:
+		}
+	}
+}
+
+// writeBlockPipelined finds the matches in src, and sends them to the
+// encoding goroutine.
+func (w *Writer) writeBlockPipelined(src []byte, lastBlock bool) (n int, err error) {
+	if w.pipe == nil {
+		w.startPipeline()
+	}
+	p := w.pipe
+
+	var b *pipelineBlock
+	select {
+	case b = <-p.recycle:
+	default:
+		b = new(pipelineBlock)
+	}
+	// The Encoder will still be using the block after this function
+	// returns, so it needs its own copy.
+	b.src = append(b.src[:0], src...)
+	b.lastBlock = lastBlock
+
+	start := time.Now()
+	b.matches = w.MatchFinder.FindMatches(b.matches[:0], b.src)
+	matchFindingTime := time.Since(start)
+
+	p.mu.Lock()
+	w.stats.MatchFindingTime += matchFindingTime
+	w.stats.addMatches(b.matches)
+	w.stats.Blocks++
+	err = w.err
+	p.mu.Unlock()
+
+	p.blocks <- b
+	return len(src), err
+}
+
+// stopPipeline waits for the encoding goroutine to finish. If discard is
+// true, blocks that have not been encoded yet are dropped.
+func (w *Writer) stopPipeline(discard bool) {
+	p := w.pipe
+	if p == nil {
+		return
+	}
+	if discard {
+		p.mu.Lock()
+		p.discard = true
+		p.mu.Unlock()
+	}
+	close(p.blocks)
+	<-p.done
+	w.pipe = nil
+}
+
+// getErr returns w.err, synchronizing with the encoding goroutine if
+// necessary.
+func (w *Writer) getErr() error {
+	if w.pipe == nil {
+		return w.err
+	}
+	w.pipe.mu.Lock()
+	defer w.pipe.mu.Unlock()
+	return w.err
+}
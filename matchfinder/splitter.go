@@ -0,0 +1,176 @@
+package matchfinder
+
+import "sync"
+
+// A BlockSplitter chooses where a Writer ends each block, so that blocks
+// can follow changes in the statistics of the data.
+type BlockSplitter interface {
+	// Split returns the length of the block that should be encoded from
+	// the start of src. It must be between 1 and len(src), inclusive.
+	Split(src []byte) int
+}
+
+// An EntropySplitter is a BlockSplitter that looks for the place where the
+// byte frequencies change the most. If encoding the data on each side of
+// that point with separate statistics is estimated to save enough bits
+// to pay for another block, the block ends there.
+//
+// It is a much cheaper (and rougher) version of the block splitting that
+// the original brotli encoder does within each meta-block; it only looks
+// at the order-0 entropy of the bytes, so it is mainly useful for data
+// that switches between different kinds of content, like text, base64,
+// and binary data in a multipart message.
+type EntropySplitter struct {
+	// MinBlockSize is the size of the smallest block that will be split
+	// off. Possible split points are at multiples of MinBlockSize/4
+	// (or farther apart, for very large blocks). If it is zero, 4096 is
+	// used.
+	MinBlockSize int
+
+	// SplitCost is the estimated cost, in bits, of starting a new block
+	// (for its header and entropy codes). If it is zero, 4096 is used.
+	SplitCost float64
+
+	// chunks holds the byte counts for each stride of the block.
+	chunks [][256]uint32
+}
+
+func (s *EntropySplitter) Split(src []byte) int {
+	minBlockSize := s.MinBlockSize
+	if minBlockSize <= 0 {
+		minBlockSize = 4096
+	}
+	splitCost := s.SplitCost
+	if splitCost == 0 {
+		splitCost = 4096
+	}
+	if len(src) < 2*minBlockSize {
+		return len(src)
+	}
+
+	// Split points are at multiples of stride. For large blocks, the
+	// stride is increased to limit the number of candidates.
+	stride := max(minBlockSize/4, len(src)/64, 1)
+
+	// Count the bytes in each stride.
+	chunks := s.chunks[:0]
+	for start := 0; start < len(src); start += stride {
+		chunks = append(chunks, [256]uint32{})
+		countBytes(&chunks[len(chunks)-1], src[start:min(start+stride, len(src))])
+	}
+	s.chunks = chunks
+
+	// After finding the best place to split, look for a split point in
+	// the first part too, so that the block ends at the first change.
+	n := len(src)
+	for {
+		k := bestSplit(chunks, n, stride, minBlockSize, splitCost)
+		if k == 0 {
+			return n
+		}
+		chunks = chunks[:k]
+		n = k * stride
+	}
+}
+
+// bestSplit finds the best place to split the first n bytes of a block,
+// given the byte counts for each stride of the block in chunks. It
+// returns the number of chunks before the split point, or 0 if splitting
+// isn't expected to save more than splitCost bits.
+func bestSplit(chunks [][256]uint32, n, stride, minBlockSize int, splitCost float64) int {
+	var total [256]uint32
+	for i := range chunks {
+		for b, c := range chunks[i] {
+			total[b] += c
+		}
+	}
+	var presentBuf [256]byte
+	present := presentBuf[:0]
+	for b, c := range total {
+		if c > 0 {
+			present = append(present, byte(b))
+		}
+	}
+	table := nLog2nTable()
+	wholeCost := entropyBits(table, &total, present, n)
+
+	var left [256]uint32
+	bestGain := splitCost
+	best := 0
+	for i, pos := 0, stride; pos <= n-minBlockSize; i, pos = i+1, pos+stride {
+		for _, b := range present {
+			left[b] += chunks[i][b]
+		}
+		if pos < minBlockSize {
+			continue
+		}
+		gain := wholeCost - splitBits(table, &total, &left, present, n, pos)
+		if gain > bestGain {
+			bestGain = gain
+			best = i + 1
+		}
+	}
+	return best
+}
+
+// countBytes adds the number of times each byte value occurs in src to
+// histogram.
+func countBytes(histogram *[256]uint32, src []byte) {
+	// Counting into several histograms at once avoids waiting for the
+	// previous increment when the same byte value is repeated.
+	var h [4][256]uint32
+	for len(src) >= 4 {
+		h[0][src[0]]++
+		h[1][src[1]]++
+		h[2][src[2]]++
+		h[3][src[3]]++
+		src = src[4:]
+	}
+	for _, b := range src {
+		h[0][b]++
+	}
+	for i := range histogram {
+		histogram[i] += h[0][i] + h[1][i] + h[2][i] + h[3][i]
+	}
+}
+
+// entropyBits returns the number of bits needed to encode the n bytes
+// counted in histogram with an ideal order-0 entropy coder. Only the bytes
+// listed in present can have non-zero counts.
+func entropyBits(table *[nLog2nTableSize]float64, histogram *[256]uint32, present []byte, n int) float64 {
+	bits := nLog2n(table, uint(n))
+	for _, b := range present {
+		bits -= nLog2n(table, uint(histogram[b]))
+	}
+	return bits
+}
+
+// splitBits is like entropyBits, but it returns the number of bits needed
+// if the first nLeft bytes (counted in left) and the rest are encoded
+// separately.
+func splitBits(table *[nLog2nTableSize]float64, total, left *[256]uint32, present []byte, n, nLeft int) float64 {
+	bits := nLog2n(table, uint(nLeft)) + nLog2n(table, uint(n-nLeft))
+	for _, b := range present {
+		l := left[b]
+		bits -= nLog2n(table, uint(l)) + nLog2n(table, uint(total[b]-l))
+	}
+	return bits
+}
+
+const nLog2nTableSize = 1 << 12
+
+var nLog2nTable = sync.OnceValue(func() *[nLog2nTableSize]float64 {
+	t := new([nLog2nTableSize]float64)
+	for n := range t {
+		t[n] = float64(float64(n) * Log2(uint(n)))
+	}
+	return t
+})
+
+// nLog2n returns n * Log2(n), using table for small values of n.
+func nLog2n(table *[nLog2nTableSize]float64, n uint) float64 {
+	if n < nLog2nTableSize {
+		return table[n]
+	}
+	return float64(float64(n) * Log2(n))
+}
@@ -0,0 +1,160 @@
+package matchfinder
+
+import "io"
+
+// A MatchReader decompresses data and reports the matches that it was
+// compressed with, so that it can be converted to another format without
+// searching for matches again.
+type MatchReader interface {
+	// ReadMatches decompresses the next part of the stream, appends the
+	// data to data and the matches to matches, and returns the updated
+	// slices. The new matches cover exactly the new data (the last one may
+	// have a Length of 0), but their distances may refer to data from
+	// previous calls. Data may be returned along with an error; at the end
+	// of the stream, the error is io.EOF.
+	ReadMatches(data []byte, matches []Match) ([]byte, []Match, error)
+}
+
+// A Transcoder converts compressed data from a MatchReader to another
+// format, using the matches from the original stream instead of searching
+// for new ones.
+type Transcoder struct {
+	Dest    io.Writer
+	Encoder Encoder
+
+	// MatchFinder, if it is not nil, looks for more matches in the parts
+	// of the data that the original stream stored as literals. A fast
+	// MatchFinder with a longer MaxDistance than the original format allowed
+	// can improve compression considerably, at some cost in speed.
+	MatchFinder MatchFinder
+
+	// BlockSize is the number of bytes to collect from the MatchReader
+	// before encoding a block. If it is zero, 64 KB is used.
+	BlockSize int
+
+	// MaxDistance, if it is greater than zero, is the longest distance
+	// that Encoder supports. Matches that are farther back are stored as
+	// literals.
+	MaxDistance int
+
+	// MinLength, if it is greater than zero, is the shortest match that
+	// Encoder supports. Shorter matches are stored as literals.
+	MinLength int
+
+	data    []byte
+	matches []Match
+	found   []Match
+	merged  []Match
+	outBuf  []byte
+}
+
+// Transcode reads all the data from r, and writes it to t.Dest as a
+// complete stream.
+func (t *Transcoder) Transcode(r MatchReader) error {
+	t.Encoder.Reset()
+	if t.MatchFinder != nil {
+		t.MatchFinder.Reset()
+	}
+	blockSize := t.BlockSize
+	if blockSize <= 0 {
+		blockSize = 1 << 16
+	}
+
+	for {
+		t.data, t.matches = t.data[:0], t.matches[:0]
+		var err error
+		for len(t.data) < blockSize && err == nil {
+			t.data, t.matches, err = r.ReadMatches(t.data, t.matches)
+		}
+		if err != nil && err != io.EOF {
+			return err
+		}
+
+		matches := t.matches
+		if t.MatchFinder != nil {
+			t.found = t.MatchFinder.FindMatches(t.found[:0], t.data)
+			t.merged = fillGaps(t.merged[:0], matches, t.found)
+			matches = t.merged
+		}
+		matches = cleanMatches(matches, t.MaxDistance, t.MinLength)
+
+		t.outBuf = t.Encoder.Encode(t.outBuf[:0], t.data, matches, err == io.EOF)
+		if _, werr := t.Dest.Write(t.outBuf); werr != nil {
+			return werr
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+// minGapMatch is the shortest match that fillGaps will use.
+const minGapMatch = 4
+
+// fillGaps returns the matches from orig, with the runs of literals
+// between them replaced by the matches from found (which describe the same
+// data), where there are any. The matches from found are trimmed as
+// needed so that they don't overlap the matches from orig.
+func fillGaps(dst, orig, found []Match) []Match {
+	j := 0
+	fpos := 0 // the position where found[j] starts (including literals)
+	pos := 0
+	for _, m := range orig {
+		gapEnd := pos + m.Unmatched
+		lit := pos // the start of the literals that aren't covered yet
+		for j < len(found) {
+			f := found[j]
+			start := fpos + f.Unmatched
+			end := start + f.Length
+			if start >= gapEnd {
+				break
+			}
+			// Trimming the start of a match doesn't change its distance.
+			s, e := max(start, lit), min(end, gapEnd)
+			if e-s >= minGapMatch {
+				dst = append(dst, Match{Unmatched: s - lit, Length: e - s, Distance: f.Distance})
+				lit = e
+			}
+			if end > gapEnd {
+				// The rest of the match might be usable in the next gap.
+				break
+			}
+			fpos = end
+			j++
+		}
+		dst = append(dst, Match{Unmatched: gapEnd - lit, Length: m.Length, Distance: m.Distance})
+		pos = gapEnd + m.Length
+	}
+	return dst
+}
+
+// cleanMatches combines matches that can be encoded as one (a match with
+// a Length of 0 and the match after it, or consecutive matches with the
+// same distance), and converts matches that are farther back than
+// maxDistance or shorter than minLength (if they are positive) to
+// literals. It modifies matches in place.
+func cleanMatches(matches []Match, maxDistance, minLength int) []Match {
+	dst := matches[:0]
+	pending := 0
+	for _, m := range matches {
+		m.Unmatched += pending
+		pending = 0
+		if m.Length == 0 || maxDistance > 0 && m.Distance > maxDistance {
+			pending = m.Unmatched + m.Length
+			continue
+		}
+		if n := len(dst); n > 0 && m.Unmatched == 0 && dst[n-1].Distance == m.Distance {
+			dst[n-1].Length += m.Length
+			continue
+		}
+		if m.Length < minLength {
+			pending = m.Unmatched + m.Length
+			continue
+		}
+		dst = append(dst, m)
+	}
+	if pending > 0 {
+		dst = append(dst, Match{Unmatched: pending})
+	}
+	return dst
+}
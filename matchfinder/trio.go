@@ -6,7 +6,7 @@ import "encoding/binary"
 // overlap parsing.
 type Trio struct {
 	MaxDistance int
-	history     []byte
+	h           history
 	table5      [1 << 16]tableEntry
 	table8      [1 << 17]tableEntry
 	table12     [1 << 18]tableEntry
@@ -16,7 +16,7 @@ func (z *Trio) Reset() {
 	z.table5 = [len(z.table5)]tableEntry{}
 	z.table8 = [len(z.table8)]tableEntry{}
 	z.table12 = [len(z.table12)]tableEntry{}
-	z.history = z.history[:0]
+	z.h.reset()
 }
 
 func (z *Trio) FindMatches(dst []Match, src []byte) []Match {
@@ -24,52 +24,20 @@ func (z *Trio) FindMatches(dst []Match, src []byte) []Match {
 		z.MaxDistance = 1 << 16
 	}
 
-	if len(z.history) > z.MaxDistance*2 {
-		delta := len(z.history) - z.MaxDistance
-		copy(z.history, z.history[delta:])
-		z.history = z.history[:z.MaxDistance]
-
-		for i := range z.table5 {
-			v := z.table5[i].offset
-			v -= int32(delta)
-			if v < 0 {
-				z.table5[i] = tableEntry{}
-			} else {
-				z.table5[i].offset = v
-			}
-		}
-		for i := range z.table8 {
-			v := z.table8[i].offset
-			v -= int32(delta)
-			if v < 0 {
-				z.table8[i] = tableEntry{}
-			} else {
-				z.table8[i].offset = v
-			}
-		}
-		for i := range z.table12 {
-			v := z.table12[i].offset
-			v -= int32(delta)
-			if v < 0 {
-				z.table12[i] = tableEntry{}
-			} else {
-				z.table12[i].offset = v
-			}
-		}
+	z.h.trim(z.MaxDistance)
+
+	e := matchEmitter{
+		Dst:      dst,
+		NextEmit: len(z.h.buf),
 	}
+	z.h.buf = append(z.h.buf, src...)
 
 	if len(src) < 20 {
 		return append(dst, Match{
 			Unmatched: len(src),
 		})
 	}
-
-	e := matchEmitter{
-		Dst:      dst,
-		NextEmit: len(z.history),
-	}
-	z.history = append(z.history, src...)
-	src = z.history
+	src = z.h.buf
 
 	// matches stores the matches that have been found but not emitted,
 	// in reverse order. (matches[0] is the most recent one.)
@@ -96,11 +64,11 @@ mainLoop:
 			nextHash12 := z.hash12(cv, extra)
 			nextHash8 := z.hash8(cv)
 			nextHash5 := z.hash5(cv)
-			candidate12 := z.table12[nextHash12]
-			candidate8 := z.table8[nextHash8]
-			candidate5 := z.table5[nextHash5]
+			candidate12 := z.h.lookup(z.table12[nextHash12])
+			candidate8 := z.h.lookup(z.table8[nextHash8])
+			candidate5 := z.h.lookup(z.table5[nextHash5])
 
-			entry := tableEntry{offset: s, val: uint32(cv)}
+			entry := z.h.entry(s, uint32(cv))
 			z.table12[nextHash12] = entry
 			z.table8[nextHash8] = entry
 			z.table5[nextHash5] = entry
@@ -153,11 +121,11 @@ mainLoop:
 			extra := binary.LittleEndian.Uint32(src[s+9:])
 			nextHash12 := z.hash12(cv, extra)
 			nextHash8 := z.hash8(cv)
-			candidate12 := z.table12[nextHash12]
-			candidate8 := z.table8[nextHash8]
+			candidate12 := z.h.lookup(z.table12[nextHash12])
+			candidate8 := z.h.lookup(z.table8[nextHash8])
 			coffset12 := s - candidate12.offset + 1
 			coffset8 := s - candidate8.offset + 1
-			entry := tableEntry{offset: s + 1, val: uint32(cv)}
+			entry := z.h.entry(s+1, uint32(cv))
 			z.table12[nextHash12] = entry
 			z.table8[nextHash8] = entry
 			if candidate12.offset < s+1 && coffset12 < int32(z.MaxDistance) && uint32(cv) == candidate12.val &&
@@ -189,7 +157,7 @@ mainLoop:
 			for index0 < s-1 {
 				cv0 := binary.LittleEndian.Uint64(src[index0:])
 				extra0 := binary.LittleEndian.Uint32(src[index0+8:])
-				te0 := tableEntry{offset: index0, val: uint32(cv0)}
+				te0 := z.h.entry(index0, uint32(cv0))
 				z.table5[z.hash5(cv0)] = te0
 				z.table8[z.hash8(cv0)] = te0
 				z.table12[z.hash12(cv0, extra0)] = te0
@@ -202,10 +170,10 @@ mainLoop:
 			nextHash12 := z.hash12(cv, extra)
 			nextHash8 := z.hash8(cv)
 			nextHash5 := z.hash5(cv)
-			candidate12 := z.table12[nextHash12]
-			candidate8 := z.table8[nextHash8]
+			candidate12 := z.h.lookup(z.table12[nextHash12])
+			candidate8 := z.h.lookup(z.table8[nextHash8])
 
-			entry := tableEntry{offset: s, val: uint32(cv)}
+			entry := z.h.entry(s, uint32(cv))
 			z.table12[nextHash12] = entry
 			z.table8[nextHash8] = entry
 			z.table5[nextHash5] = entry
@@ -282,7 +250,7 @@ mainLoop:
 		for index0 < int32(matches[0].End) && index0 < sLimit {
 			cv0 := binary.LittleEndian.Uint64(src[index0:])
 			extra0 := binary.LittleEndian.Uint32(src[index0+8:])
-			te0 := tableEntry{offset: index0, val: uint32(cv0)}
+			te0 := z.h.entry(index0, uint32(cv0))
 			z.table5[z.hash5(cv0)] = te0
 			z.table8[z.hash8(cv0)] = te0
 			z.table12[z.hash12(cv0, extra0)] = te0
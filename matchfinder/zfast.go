@@ -126,7 +126,7 @@ mainLoop:
 
 			coffset0 := s - (candidate.offset - z.current)
 			coffset1 := s - (candidate2.offset - z.current) + 1
-			if coffset0 < int32(z.MaxDistance) && uint32(cv) == candidate.val {
+			if coffset0 > 0 && coffset0 < int32(z.MaxDistance) && uint32(cv) == candidate.val {
 				t = candidate.offset - z.current
 				if binary.LittleEndian.Uint32(src[t:]) == uint32(cv) {
 					// found a regular match
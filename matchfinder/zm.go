@@ -13,7 +13,7 @@ const (
 // overlap-based parsing of M4.
 type ZM struct {
 	MaxDistance int
-	history     []byte
+	h           history
 	table       [zmTableSize]tableEntry
 	longTable   [zmLongTableSize]tableEntry
 }
@@ -21,7 +21,7 @@ type ZM struct {
 func (z *ZM) Reset() {
 	z.table = [zmTableSize]tableEntry{}
 	z.longTable = [zmLongTableSize]tableEntry{}
-	z.history = z.history[:0]
+	z.h.reset()
 }
 
 func (z *ZM) FindMatches(dst []Match, src []byte) []Match {
@@ -29,43 +29,20 @@ func (z *ZM) FindMatches(dst []Match, src []byte) []Match {
 		z.MaxDistance = 1 << 16
 	}
 
-	if len(z.history) > z.MaxDistance*2 {
-		delta := len(z.history) - z.MaxDistance
-		copy(z.history, z.history[delta:])
-		z.history = z.history[:z.MaxDistance]
-
-		for i := range z.table {
-			v := z.table[i].offset
-			v -= int32(delta)
-			if v < 0 {
-				z.table[i] = tableEntry{}
-			} else {
-				z.table[i].offset = v
-			}
-		}
-		for i := range z.longTable {
-			v := z.longTable[i].offset
-			v -= int32(delta)
-			if v < 0 {
-				z.longTable[i] = tableEntry{}
-			} else {
-				z.longTable[i].offset = v
-			}
-		}
+	z.h.trim(z.MaxDistance)
+
+	e := matchEmitter{
+		Dst:      dst,
+		NextEmit: len(z.h.buf),
 	}
+	z.h.buf = append(z.h.buf, src...)
 
 	if len(src) < 16 {
 		return append(dst, Match{
 			Unmatched: len(src),
 		})
 	}
-
-	e := matchEmitter{
-		Dst:      dst,
-		NextEmit: len(z.history),
-	}
-	z.history = append(z.history, src...)
-	src = z.history
+	src = z.h.buf
 
 	// matches stores the matches that have been found but not emitted,
 	// in reverse order. (matches[0] is the most recent one.)
@@ -89,10 +66,10 @@ mainLoop:
 		for {
 			nextHashL := z.hashLong(cv)
 			nextHashS := z.hashShort(cv)
-			candidateL := z.longTable[nextHashL]
-			candidateS := z.table[nextHashS]
+			candidateL := z.h.lookup(z.longTable[nextHashL])
+			candidateS := z.h.lookup(z.table[nextHashS])
 
-			entry := tableEntry{offset: s, val: uint32(cv)}
+			entry := z.h.entry(s, uint32(cv))
 			z.longTable[nextHashL] = entry
 			z.table[nextHashS] = entry
 
@@ -122,9 +99,9 @@ mainLoop:
 				// See if we can find a long match at s+1.
 				cv := binary.LittleEndian.Uint64(src[s+1:])
 				nextHashL = z.hashLong(cv)
-				candidateL = z.longTable[nextHashL]
+				candidateL = z.h.lookup(z.longTable[nextHashL])
 				coffsetL := s - candidateL.offset + 1
-				z.longTable[nextHashL] = tableEntry{offset: s + 1, val: uint32(cv)}
+				z.longTable[nextHashL] = z.h.entry(s+1, uint32(cv))
 				if candidateL.offset < s+1 && coffsetL < int32(z.MaxDistance) && uint32(cv) == candidateL.val &&
 					binary.LittleEndian.Uint32(src[candidateL.offset:]) == uint32(cv) {
 					// We found a long match at s+1, so we'll use that instead
@@ -151,7 +128,7 @@ mainLoop:
 		// Store some table entries after s.
 		index0 := s + 1
 		cv0 := binary.LittleEndian.Uint64(src[index0:])
-		te0 := tableEntry{offset: index0, val: uint32(cv0)}
+		te0 := z.h.entry(index0, uint32(cv0))
 		z.longTable[z.hashLong(cv0)] = te0
 		cv0 >>= 8
 		te0.offset++
@@ -170,10 +147,10 @@ mainLoop:
 
 			nextHashL := z.hashLong(cv)
 			nextHashS := z.hashShort(cv)
-			candidateL := z.longTable[nextHashL]
-			candidateS := z.table[nextHashS]
+			candidateL := z.h.lookup(z.longTable[nextHashL])
+			candidateS := z.h.lookup(z.table[nextHashS])
 
-			entry := tableEntry{offset: s, val: uint32(cv)}
+			entry := z.h.entry(s, uint32(cv))
 			z.longTable[nextHashL] = entry
 			z.table[nextHashS] = entry
 
@@ -189,9 +166,9 @@ mainLoop:
 				// See if we can find a long match at s+1.
 				cv := binary.LittleEndian.Uint64(src[s+1:])
 				nextHashL = z.hashLong(cv)
-				candidateL = z.longTable[nextHashL]
+				candidateL = z.h.lookup(z.longTable[nextHashL])
 				coffsetL := s - candidateL.offset + 1
-				z.longTable[nextHashL] = tableEntry{offset: s + 1, val: uint32(cv)}
+				z.longTable[nextHashL] = z.h.entry(s+1, uint32(cv))
 				if candidateL.offset < s+1 && coffsetL < int32(z.MaxDistance) && uint32(cv) == candidateL.val &&
 					binary.LittleEndian.Uint32(src[candidateL.offset:]) == uint32(cv) {
 					// We found a long match at s+1, so we'll use that instead
@@ -260,7 +237,7 @@ mainLoop:
 		index1 := int32(matches[0].End - 2)
 		if index1 < sLimit {
 			cv1 := binary.LittleEndian.Uint64(src[index1:])
-			te1 := tableEntry{offset: index1, val: uint32(cv1)}
+			te1 := z.h.entry(index1, uint32(cv1))
 			z.longTable[z.hashLong(cv1)] = te1
 			cv1 >>= 8
 			te1.offset++
@@ -1,5 +1,7 @@
 package brotli
 
+import "context"
+
 /* Copyright 2017 Google Inc. All Rights Reserved.
 
    Distributed under MIT license.
@@ -34,4 +36,8 @@ type encoderParams struct {
 	hasher                           hasherParams
 	dist                             distanceParams
 	dictionary                       encoderDictionary
+	ctx                              context.Context
+
+	/* If nonzero, limits hasher.block_bits to reduce memory usage. */
+	max_hasher_block_bits int
 }
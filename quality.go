@@ -174,6 +174,10 @@ func chooseHasher(params *encoderParams, hparams *hasherParams) {
 		}
 	}
 
+	if params.max_hasher_block_bits != 0 && hparams.block_bits > params.max_hasher_block_bits {
+		hparams.block_bits = params.max_hasher_block_bits
+	}
+
 	if params.lgwin > 24 {
 		/* Different hashers for large window brotli: not for qualities <= 2,
 		   these are too fast for large window. Not for qualities >= 10: their
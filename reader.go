@@ -3,6 +3,9 @@ package brotli
 import (
 	"errors"
 	"io"
+	"slices"
+
+	"github.com/andybalholm/brotli/matchfinder"
 )
 
 type decodeError int
@@ -26,15 +29,50 @@ func NewReader(src io.Reader) *Reader {
 	return r
 }
 
+// ReaderOptions configures Reader.
+type ReaderOptions struct {
+	// LargeWindow allows decoding "Large Window Brotli" streams, with a
+	// window of up to 1 GB. This format is an extension to RFC 7932; it is
+	// produced by Writer when WriterOptions.LargeWindow is set, and by
+	// Encoder when WindowBits is greater than 24.
+	LargeWindow bool
+}
+
+// NewReaderOptions is like NewReader but specifies ReaderOptions.
+func NewReaderOptions(src io.Reader, options ReaderOptions) *Reader {
+	r := new(Reader)
+	r.options = options
+	r.Reset(src)
+	return r
+}
+
 // Reset discards the Reader's state and makes it equivalent to the result of
 // its original state from NewReader, but reading from src instead.
 // This permits reusing a Reader rather than allocating a new one.
 // Error is always nil
 func (r *Reader) Reset(src io.Reader) error {
+	r.resetState()
+	r.src = src
+	r.in = nil
+	r.inputRead = 0
+	r.pending = nil
+	if r.buf == nil {
+		r.buf = make([]byte, readBufSize)
+	}
+	return nil
+}
+
+// resetState prepares the decoder to start a new stream, keeping its
+// options and buffers.
+func (r *Reader) resetState() {
 	if r.error_code < 0 {
 		// There was an unrecoverable error, leaving the Reader's state
 		// undefined. Clear out everything but the buffers.
 		*r = Reader{
+			options:          r.options,
+			saveMetadata:     r.saveMetadata,
+			recordCopies:     r.recordCopies,
+			copies:           r.copies[:0],
 			buf:              r.buf,
 			block_type_trees: r.block_type_trees,
 			literal_hgroup: huffmanTreeGroup{
@@ -53,16 +91,26 @@ func (r *Reader) Reset(src io.Reader) error {
 	}
 
 	decoderStateInit(r)
-	r.src = src
-	if r.buf == nil {
-		r.buf = make([]byte, readBufSize)
-	}
-	return nil
+	r.large_window = r.options.LargeWindow
 }
 
 func (r *Reader) Read(p []byte) (n int, err error) {
+	// The copies are only recorded while ReadMatches is being used.
+	r.recordCopies = false
+	r.copies = r.copies[:0]
+	return r.read(p)
+}
+
+func (r *Reader) read(p []byte) (n int, err error) {
+	if len(r.pending) > 0 {
+		n = copy(p, r.pending)
+		r.pending = r.pending[n:]
+		return n, nil
+	}
+
 	if !decoderHasMoreOutput(r) && len(r.in) == 0 {
 		m, readErr := r.src.Read(r.buf)
+		r.inputRead += int64(m)
 		if m == 0 {
 			if readErr == io.EOF && r.state != stateDone {
 				readErr = io.ErrUnexpectedEOF
@@ -114,6 +162,7 @@ func (r *Reader) Read(p []byte) (n int, err error) {
 
 		// Top off the buffer.
 		encN, err := r.src.Read(r.buf)
+		r.inputRead += int64(encN)
 		if encN == 0 {
 			// Not enough data to complete decoding.
 			if err == io.EOF {
@@ -124,3 +173,67 @@ func (r *Reader) Read(p []byte) (n int, err error) {
 		r.in = r.buf[:encN]
 	}
 }
+
+// ReadMatches decompresses the next part of the stream, appends it to
+// data, and appends the commands that it was encoded with to matches, as
+// LZ77 matches. The new matches cover exactly the new data (the last one
+// may have a Length of 0, for literals at the end), but their distances
+// may refer to data from previous calls. Literals and references to the
+// static dictionary are both reported as unmatched bytes.
+//
+// This makes a Reader a matchfinder.MatchReader, so a brotli stream can be
+// converted to another format (with a matchfinder.Transcoder) without
+// searching for matches again. It is also useful for seeing how a stream
+// was compressed.
+//
+// Data may be returned along with an error; at the end of the stream, the
+// error is io.EOF. If ReadMatches is called after Read, the matches don't
+// include the data that Read returned.
+func (r *Reader) ReadMatches(data []byte, matches []matchfinder.Match) ([]byte, []matchfinder.Match, error) {
+	if !r.recordCopies {
+		r.recordCopies = true
+		r.reportedPos = r.outputPos()
+	}
+
+	start := len(data)
+	data = slices.Grow(data, readBufSize)
+	n, err := r.read(data[start : start+readBufSize])
+	data = data[:start+n]
+	matches = r.reportMatches(matches, r.outputPos())
+	return data, matches, err
+}
+
+// outputPos returns the number of bytes of decompressed data that Read has
+// returned.
+func (r *Reader) outputPos() uint {
+	return r.partial_pos_out - uint(len(r.pending))
+}
+
+// reportMatches appends matches describing the data from r.reportedPos to
+// end to dst, and removes the copies that it used from r.copies.
+func (r *Reader) reportMatches(dst []matchfinder.Match, end uint) []matchfinder.Match {
+	pos := r.reportedPos
+	i := 0
+	for ; i < len(r.copies); i++ {
+		c := &r.copies[i]
+		if c.pos >= end {
+			break
+		}
+		length := min(c.length, int(end-c.pos))
+		dst = append(dst, matchfinder.Match{Unmatched: int(c.pos - pos), Length: length, Distance: c.distance})
+		pos = c.pos + uint(length)
+		if length < c.length {
+			// The rest of the copy hasn't been returned yet.
+			c.pos = pos
+			c.length -= length
+			break
+		}
+	}
+	if pos < end {
+		dst = append(dst, matchfinder.Match{Unmatched: int(end - pos)})
+	}
+	n := copy(r.copies, r.copies[i:])
+	r.copies = r.copies[:n]
+	r.reportedPos = end
+	return dst
+}
@@ -0,0 +1,166 @@
+// Package snappy implements a matchfinder.Encoder for the snappy framing
+// format (https://github.com/google/snappy/blob/main/framing_format.txt).
+package snappy
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+
+	"github.com/andybalholm/brotli/matchfinder"
+)
+
+const (
+	chunkTypeCompressed   = 0x00
+	chunkTypeUncompressed = 0x01
+
+	// maxChunkSize is the maximum amount of uncompressed data in a chunk.
+	maxChunkSize = 65536
+
+	tagLiteral = 0x00
+	tagCopy1   = 0x01
+	tagCopy2   = 0x02
+)
+
+// streamIdentifier is the chunk that starts every framed snappy stream.
+var streamIdentifier = []byte("\xff\x06\x00\x00sNaPpY")
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// NewEncoder returns a matchfinder.Encoder that produces the snappy framing
+// format.
+//
+// Each chunk of the snappy format is compressed independently, and holds up
+// to 64 KB. The parts of matches that refer to data in previous chunks are
+// stored as literals, so the MatchFinder should be wrapped with
+// matchfinder.AutoReset and used with a BlockSize of 65536.
+func NewEncoder() matchfinder.Encoder {
+	return new(encoder)
+}
+
+type encoder struct {
+	wroteHeader bool
+
+	// copies and chunk are buffers to reduce allocations.
+	copies []absoluteCopy
+	chunk  []byte
+}
+
+// An absoluteCopy is a match, with its position in the block.
+type absoluteCopy struct {
+	start, end, distance int
+}
+
+func (e *encoder) Reset() {
+	e.wroteHeader = false
+}
+
+func (e *encoder) Encode(dst []byte, src []byte, matches []matchfinder.Match, lastBlock bool) []byte {
+	if !e.wroteHeader {
+		dst = append(dst, streamIdentifier...)
+		e.wroteHeader = true
+	}
+
+	copies := e.copies[:0]
+	pos := 0
+	for _, m := range matches {
+		pos += m.Unmatched
+		if m.Length > 0 {
+			copies = append(copies, absoluteCopy{start: pos, end: pos + m.Length, distance: m.Distance})
+			pos += m.Length
+		}
+	}
+	e.copies = copies
+
+	for chunkStart := 0; chunkStart < len(src); chunkStart += maxChunkSize {
+		chunkEnd := min(chunkStart+maxChunkSize, len(src))
+		chunk := binary.AppendUvarint(e.chunk[:0], uint64(chunkEnd-chunkStart))
+
+		nextEmit := chunkStart
+		for len(copies) > 0 && copies[0].start < chunkEnd {
+			c := copies[0]
+			if c.end > chunkEnd {
+				// Leave the rest of the match for the next chunk.
+				copies[0].start = chunkEnd
+			} else {
+				copies = copies[1:]
+			}
+			// If the match refers to data before the start of the chunk, store
+			// the beginning of it as literals, and use the rest of it.
+			start := max(c.start, chunkStart+c.distance)
+			end := min(c.end, chunkEnd)
+			if end-start < 4 {
+				continue
+			}
+			if start > nextEmit {
+				chunk = emitLiteral(chunk, src[nextEmit:start])
+			}
+			chunk = emitCopy(chunk, c.distance, end-start)
+			nextEmit = end
+		}
+		if nextEmit < chunkEnd {
+			chunk = emitLiteral(chunk, src[nextEmit:chunkEnd])
+		}
+		e.chunk = chunk
+
+		dst = appendChunk(dst, src[chunkStart:chunkEnd], chunk)
+	}
+
+	return dst
+}
+
+// appendChunk appends a chunk containing data to dst, using compressed if it
+// is enough smaller than data.
+func appendChunk(dst, data, compressed []byte) []byte {
+	chunkType := byte(chunkTypeCompressed)
+	body := compressed
+	if len(compressed) >= len(data)-len(data)/8 {
+		chunkType = chunkTypeUncompressed
+		body = data
+	}
+
+	chunkLen := len(body) + 4
+	dst = append(dst, chunkType, byte(chunkLen), byte(chunkLen>>8), byte(chunkLen>>16))
+	dst = binary.LittleEndian.AppendUint32(dst, maskedCRC(data))
+	return append(dst, body...)
+}
+
+// maskedCRC returns the CRC-32C checksum of b, masked as required by the
+// framing format.
+func maskedCRC(b []byte) uint32 {
+	c := crc32.Checksum(b, crcTable)
+	return (c>>15 | c<<17) + 0xa282ead8
+}
+
+// emitLiteral appends a literal element containing lit to dst.
+// lit must not be longer than 65536 bytes.
+func emitLiteral(dst, lit []byte) []byte {
+	n := len(lit) - 1
+	switch {
+	case n < 60:
+		dst = append(dst, byte(n)<<2|tagLiteral)
+	case n < 1<<8:
+		dst = append(dst, 60<<2|tagLiteral, byte(n))
+	default:
+		dst = append(dst, 61<<2|tagLiteral, byte(n), byte(n>>8))
+	}
+	return append(dst, lit...)
+}
+
+// emitCopy appends copy elements for a match to dst. The offset must be less
+// than 65536, and the length must be at least 4.
+func emitCopy(dst []byte, offset, length int) []byte {
+	// Copies are limited to 64 bytes, so longer matches need several of them.
+	// The last one needs to be at least 4 bytes long.
+	for length >= 68 {
+		dst = append(dst, 63<<2|tagCopy2, byte(offset), byte(offset>>8))
+		length -= 64
+	}
+	if length > 64 {
+		dst = append(dst, 59<<2|tagCopy2, byte(offset), byte(offset>>8))
+		length -= 60
+	}
+	if length >= 12 || offset >= 2048 {
+		return append(dst, byte(length-1)<<2|tagCopy2, byte(offset), byte(offset>>8))
+	}
+	return append(dst, byte(offset>>8)<<5|byte(length-4)<<2|tagCopy1, byte(offset))
+}
@@ -0,0 +1,211 @@
+package snappy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/rand"
+	"os"
+	"testing"
+
+	"github.com/andybalholm/brotli/matchfinder"
+	"github.com/klauspost/compress/s2"
+)
+
+// decode decompresses a framed snappy stream, as produced by the Encoder,
+// with s2.Reader from klauspost/compress. Since S2 is an extension of the
+// snappy format, it also checks that decodeStrict gets the same result.
+func decode(stream []byte) ([]byte, error) {
+	out, err := io.ReadAll(s2.NewReader(bytes.NewReader(stream), s2.ReaderMaxBlockSize(maxChunkSize)))
+	if err != nil {
+		return nil, err
+	}
+	strict, err := decodeStrict(stream)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(out, strict) {
+		return nil, errors.New("s2.Reader and decodeStrict returned different data")
+	}
+	return out, nil
+}
+
+// decodeStrict decompresses a framed snappy stream, rejecting the S2
+// extensions that s2.Reader accepts.
+func decodeStrict(stream []byte) ([]byte, error) {
+	errCorrupt := errors.New("corrupt stream")
+	if !bytes.HasPrefix(stream, streamIdentifier) {
+		return nil, errCorrupt
+	}
+	stream = stream[len(streamIdentifier):]
+
+	var out []byte
+	for len(stream) > 0 {
+		if len(stream) < 8 {
+			return nil, errCorrupt
+		}
+		chunkType := stream[0]
+		chunkLen := int(stream[1]) | int(stream[2])<<8 | int(stream[3])<<16
+		if chunkLen < 4 || len(stream) < 4+chunkLen {
+			return nil, errCorrupt
+		}
+		checksum := binary.LittleEndian.Uint32(stream[4:])
+		body := stream[8 : 4+chunkLen]
+		stream = stream[4+chunkLen:]
+
+		chunkStart := len(out)
+		switch chunkType {
+		case chunkTypeUncompressed:
+			out = append(out, body...)
+
+		case chunkTypeCompressed:
+			n, k := binary.Uvarint(body)
+			if k <= 0 || n > maxChunkSize {
+				return nil, errCorrupt
+			}
+			body = body[k:]
+			for len(body) > 0 {
+				tag := body[0]
+				var length, offset int
+				switch tag & 3 {
+				case tagLiteral:
+					length = int(tag >> 2)
+					body = body[1:]
+					switch length {
+					case 60:
+						if len(body) < 1 {
+							return nil, errCorrupt
+						}
+						length = int(body[0])
+						body = body[1:]
+					case 61:
+						if len(body) < 2 {
+							return nil, errCorrupt
+						}
+						length = int(binary.LittleEndian.Uint16(body))
+						body = body[2:]
+					}
+					length++
+					if len(body) < length {
+						return nil, errCorrupt
+					}
+					out = append(out, body[:length]...)
+					body = body[length:]
+					continue
+
+				case tagCopy1:
+					if len(body) < 2 {
+						return nil, errCorrupt
+					}
+					length = int(tag>>2&7) + 4
+					offset = int(tag>>5)<<8 | int(body[1])
+					body = body[2:]
+
+				case tagCopy2:
+					if len(body) < 3 {
+						return nil, errCorrupt
+					}
+					length = int(tag>>2) + 1
+					offset = int(binary.LittleEndian.Uint16(body[1:]))
+					body = body[3:]
+
+				default:
+					return nil, errCorrupt
+				}
+				if offset == 0 || offset > len(out)-chunkStart {
+					return nil, errCorrupt
+				}
+				for range length {
+					out = append(out, out[len(out)-offset])
+				}
+			}
+			if len(out)-chunkStart != int(n) {
+				return nil, errCorrupt
+			}
+
+		default:
+			return nil, errCorrupt
+		}
+
+		if maskedCRC(out[chunkStart:]) != checksum {
+			return nil, errors.New("bad checksum")
+		}
+	}
+	return out, nil
+}
+
+func testData(t *testing.T) [][]byte {
+	text, err := os.ReadFile("../testdata/Isaac.Newton-Opticks.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	random := make([]byte, 100000)
+	rand.New(rand.NewSource(1)).Read(random)
+	return [][]byte{text, random, make([]byte, 100000), nil, []byte("a"), bytes.Repeat([]byte("ab"), 20)}
+}
+
+func TestWriterLevels(t *testing.T) {
+	for _, data := range testData(t) {
+		for level := 1; level < 4; level++ {
+			b := new(bytes.Buffer)
+			w := NewWriter(b, level)
+			w.Write(data)
+			w.Close()
+			decompressed, err := decode(b.Bytes())
+			if err != nil {
+				t.Fatalf("error decompressing level %d: %v", level, err)
+			}
+			if !bytes.Equal(decompressed, data) {
+				t.Fatalf("decompressed output doesn't match on level %d", level)
+			}
+		}
+	}
+}
+
+func TestMatchesAcrossChunks(t *testing.T) {
+	// With a BlockSize larger than a chunk, and no AutoReset, some matches
+	// refer to data in previous chunks, so they must be stored as literals.
+	data, err := os.ReadFile("../testdata/Isaac.Newton-Opticks.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := new(bytes.Buffer)
+	w := &matchfinder.Writer{
+		Dest:        b,
+		MatchFinder: &matchfinder.Bargain3{MaxDistance: 1 << 18},
+		Encoder:     NewEncoder(),
+		BlockSize:   1 << 18,
+	}
+	w.Write(data)
+	w.Close()
+	decompressed, err := decode(b.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decompressed, data) {
+		t.Fatal("decompressed output doesn't match")
+	}
+}
+
+func BenchmarkWriterLevels(b *testing.B) {
+	data, err := os.ReadFile("../testdata/Isaac.Newton-Opticks.txt")
+	if err != nil {
+		b.Fatal(err)
+	}
+	for level := 1; level < 4; level++ {
+		b.Run(string(rune('0'+level)), func(b *testing.B) {
+			buf := new(bytes.Buffer)
+			w := NewWriter(buf, level)
+			b.SetBytes(int64(len(data)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				buf.Reset()
+				w.Reset(buf)
+				w.Write(data)
+				w.Close()
+			}
+			b.ReportMetric(float64(len(data))/float64(buf.Len()), "ratio")
+		})
+	}
+}
@@ -0,0 +1,34 @@
+package snappy
+
+import (
+	"io"
+
+	"github.com/andybalholm/brotli/matchfinder"
+)
+
+// NewWriter returns a new matchfinder.Writer that compresses data at the
+// given level, in the snappy framing format. Levels 1–3 are available;
+// levels outside this range will be replaced with the closest level
+// available. Level 1 is comparable to other snappy implementations.
+func NewWriter(w io.Writer, level int) *matchfinder.Writer {
+	var mf matchfinder.MatchFinder
+	switch {
+	case level < 2:
+		mf = matchfinder.M0{}
+	case level == 2:
+		mf = matchfinder.M0{Lazy: true}
+	default:
+		mf = matchfinder.AutoReset{MatchFinder: &matchfinder.M4{
+			MaxDistance: maxChunkSize - 1,
+			ChainLength: 16,
+			HashLen:     5,
+		}}
+	}
+
+	return &matchfinder.Writer{
+		Dest:        w,
+		MatchFinder: mf,
+		Encoder:     NewEncoder(),
+		BlockSize:   maxChunkSize,
+	}
+}
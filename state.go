@@ -87,6 +87,8 @@ const (
 )
 
 type Reader struct {
+	options ReaderOptions
+
 	src io.Reader
 	buf []byte // scratch space for reading from src
 	in  []byte // current chunk to decode; usually aliases buf
@@ -176,8 +178,44 @@ type Reader struct {
 	dictionary                  *dictionary
 	transforms                  *transforms
 	trivial_literal_contexts    [8]uint32
+
+	// If saveMetadata is set, the contents of the first metadata block at
+	// the start of the stream (before any data) that starts with
+	// filterMagic are saved in metadata, unless it is longer than
+	// maxSavedMetadata. Other metadata blocks are skipped, so that a
+	// stream full of them can't use up memory. keepMetadata is set while
+	// a block that may be saved is being read.
+	saveMetadata bool
+	keepMetadata bool
+	metadata     []byte
+
+	// If recordCopies is set, the LZ77 copies are recorded in copies, for
+	// ReadMatches. reportedPos is the stream position up to which
+	// ReadMatches has reported the matches.
+	recordCopies bool
+	copies       []decodedCopy
+	reportedPos  uint
+
+	// inputRead is the number of bytes that have been read from src.
+	inputRead int64
+
+	// If checkpointRequested is set, the decoder stops at the next point
+	// where its state can be saved (see Reader.Checkpoint). pending holds
+	// output that it produced along the way, which has not been returned
+	// by Read yet.
+	checkpointRequested bool
+	pending             []byte
 }
 
+// A decodedCopy is an LZ77 copy that the decoder has performed.
+type decodedCopy struct {
+	pos      uint // the stream position of the first byte copied to
+	length   int
+	distance int
+}
+
+const maxSavedMetadata = 1 << 10
+
 func decoderStateInit(s *Reader) bool {
 	s.error_code = 0 /* BROTLI_DECODER_NO_ERROR */
 
@@ -220,6 +258,10 @@ func decoderStateInit(s *Reader) bool {
 	s.is_metadata = 0
 	s.should_wrap_ringbuffer = 0
 	s.canny_ringbuffer_allocation = 1
+	s.keepMetadata = false
+	s.metadata = nil
+	s.copies = s.copies[:0]
+	s.reportedPos = 0
 
 	s.window_bits = 0
 	s.max_distance = 0
@@ -0,0 +1,51 @@
+package brotli
+
+import (
+	"io"
+
+	"github.com/andybalholm/brotli/matchfinder"
+)
+
+// TranscodeOptions configures Transcode.
+type TranscodeOptions struct {
+	// WindowBits is the base-2 logarithm of the window size, as in
+	// WriterV2Options. Values less than 16 are treated as 16, since that is
+	// the smallest window that can hold the 32 KB window of deflate. If it
+	// is zero, 20 is used.
+	WindowBits int
+
+	// Refine makes Transcode look for matches in the parts of the data
+	// that the original stream stored as literals (including matches that
+	// are too far back for the original format). It is somewhat slower,
+	// but it can make the output much smaller for data with repetition at
+	// long distances.
+	Refine bool
+}
+
+// Transcode converts compressed data to brotli format, writing it to dst.
+// Instead of decompressing the data and compressing it again, it reuses
+// the matches from the original stream. This is several times faster than
+// recompressing at a level that gives similar compression. The source is
+// usually a flate.GZIPReader or flate.Reader.
+func Transcode(dst io.Writer, src matchfinder.MatchReader, options TranscodeOptions) error {
+	windowBits := options.WindowBits
+	switch {
+	case windowBits == 0:
+		windowBits = 20
+	case windowBits < 16:
+		windowBits = 16
+	case windowBits > largeMaxWindowBits:
+		windowBits = largeMaxWindowBits
+	}
+	maxDistance := 1<<windowBits - windowGap
+
+	t := &matchfinder.Transcoder{
+		Dest:        dst,
+		Encoder:     &Encoder{WindowBits: windowBits},
+		MaxDistance: maxDistance,
+	}
+	if options.Refine {
+		t.MatchFinder = &matchfinder.ZFast{MaxDistance: maxDistance}
+	}
+	return t.Transcode(src)
+}
@@ -0,0 +1,159 @@
+package brotli
+
+import (
+	"io"
+
+	"golang.org/x/text/transform"
+)
+
+// A Decompressor decompresses brotli data from buffers supplied by the
+// caller, instead of reading it from an io.Reader. It implements
+// transform.Transformer, so it can be used with transform.NewReader,
+// transform.Bytes, and so on, but it is also useful by itself in programs
+// that can't block waiting for input.
+type Decompressor struct {
+	r Reader
+}
+
+// NewDecompressor returns a Decompressor that is ready to decompress a
+// stream.
+func NewDecompressor(options ReaderOptions) *Decompressor {
+	d := new(Decompressor)
+	d.r.options = options
+	d.r.resetState()
+	return d
+}
+
+// Reset discards the Decompressor's state, so that it can decompress a new
+// stream.
+func (d *Decompressor) Reset() {
+	d.r.resetState()
+}
+
+// Transform decompresses src into dst, and returns the number of bytes
+// written to dst and consumed from src. Input that isn't enough to
+// decode anything yet is saved internally, so all of src is consumed
+// unless dst fills up (in which case the error is transform.ErrShortDst)
+// or the stream ends. If atEOF is true and the stream is incomplete, the
+// error is io.ErrUnexpectedEOF.
+func (d *Decompressor) Transform(dst, src []byte, atEOF bool) (nDst, nSrc int, err error) {
+	in := src
+	out := dst
+	inRemaining := uint(len(in))
+	outRemaining := uint(len(out))
+	result := decoderDecompressStream(&d.r, &inRemaining, &in, &outRemaining, &out)
+	nDst = len(dst) - int(outRemaining)
+	nSrc = len(src) - int(inRemaining)
+
+	switch result {
+	case decoderResultSuccess:
+		if nSrc < len(src) {
+			return nDst, nSrc, errExcessiveInput
+		}
+		return nDst, nSrc, nil
+	case decoderResultError:
+		return nDst, nSrc, decodeError(decoderGetErrorCode(&d.r))
+	case decoderResultNeedsMoreOutput:
+		return nDst, nSrc, transform.ErrShortDst
+	}
+
+	if nSrc != len(src) {
+		return nDst, nSrc, errInvalidState
+	}
+	if atEOF {
+		return nDst, nSrc, io.ErrUnexpectedEOF
+	}
+	return nDst, nSrc, nil
+}
+
+// A Compressor compresses data from buffers supplied by the caller,
+// instead of writing it to an io.Writer. It implements
+// transform.Transformer. It uses the same encoder as Writer.
+type Compressor struct {
+	w       Writer
+	out     appendWriter
+	outPos  int // the amount of out.buf that has been returned
+	options WriterOptions
+
+	// finished is set when the end of the stream has been encoded.
+	finished bool
+}
+
+// NewCompressor returns a Compressor that is ready to compress a stream
+// with the given options.
+func NewCompressor(options WriterOptions) *Compressor {
+	c := &Compressor{options: options}
+	c.Reset()
+	return c
+}
+
+// Reset discards the Compressor's state, so that it can compress a new
+// stream.
+func (c *Compressor) Reset() {
+	c.w.options = c.options
+	c.w.Reset(&c.out)
+	c.out.buf = c.out.buf[:0]
+	c.outPos = 0
+	c.finished = false
+}
+
+// compressorChunkSize is the most input that a Compressor passes to the
+// encoder at a time, to limit how much output it needs to buffer.
+const compressorChunkSize = 1 << 16
+
+// Transform compresses src into dst, and returns the number of bytes
+// written to dst and consumed from src. The Compressor keeps the input
+// that it needs for its window, so all of src is consumed unless dst fills
+// up, in which case the error is transform.ErrShortDst. The stream is
+// finished when Transform is called with atEOF set to true; after that,
+// it must be Reset before it is used again.
+func (c *Compressor) Transform(dst, src []byte, atEOF bool) (nDst, nSrc int, err error) {
+	for {
+		if c.outPos < len(c.out.buf) {
+			n := copy(dst[nDst:], c.out.buf[c.outPos:])
+			nDst += n
+			c.outPos += n
+			if c.outPos < len(c.out.buf) {
+				return nDst, nSrc, transform.ErrShortDst
+			}
+			c.out.buf = c.out.buf[:0]
+			c.outPos = 0
+		}
+
+		if c.finished {
+			if nSrc < len(src) {
+				return nDst, nSrc, errWriterClosed
+			}
+			return nDst, nSrc, nil
+		}
+
+		if nSrc == len(src) {
+			if !atEOF {
+				return nDst, nSrc, nil
+			}
+			if _, err := c.w.writeChunk(nil, operationFinish); err != nil {
+				return nDst, nSrc, err
+			}
+			c.finished = true
+			continue
+		}
+
+		chunk := src[nSrc:min(len(src), nSrc+compressorChunkSize)]
+		n, err := c.w.writeChunk(chunk, operationProcess)
+		nSrc += n
+		if err != nil {
+			return nDst, nSrc, err
+		}
+	}
+}
+
+// An appendWriter is an io.Writer that collects the data written to it in
+// buf.
+type appendWriter struct {
+	buf []byte
+}
+
+func (a *appendWriter) Write(p []byte) (int, error) {
+	a.buf = append(a.buf, p...)
+	return len(p), nil
+}
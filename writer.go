@@ -1,6 +1,7 @@
 package brotli
 
 import (
+	"context"
 	"errors"
 	"io"
 
@@ -19,8 +20,23 @@ type WriterOptions struct {
 	// The higher the quality, the slower the compression. Range is 0 to 11.
 	Quality int
 	// LGWin is the base 2 logarithm of the sliding window size.
-	// Range is 10 to 24. 0 indicates automatic configuration based on Quality.
+	// Range is 10 to 24 (or 30 with LargeWindow). 0 indicates automatic
+	// configuration based on Quality.
 	LGWin int
+	// LargeWindow enables "Large Window Brotli", which allows LGWin to be
+	// up to 30 (a 1 GB window). This format is an extension to RFC 7932;
+	// decoding it requires a Reader with ReaderOptions.LargeWindow set.
+	LargeWindow bool
+	// Progress, if non-nil, is called each time a block of compressed data
+	// has been produced, with the number of uncompressed bytes processed so
+	// far and the number of compressed bytes written to the underlying
+	// Writer so far.
+	Progress func(processed, written int64)
+	// MaxMemory, if positive, is the approximate maximum number of bytes of
+	// memory the Writer should use. To stay within it, the Writer uses
+	// smaller blocks, hash tables, and window, and if necessary a lower
+	// quality than the options specify. See EstimateMemory.
+	MaxMemory int
 }
 
 var (
@@ -53,17 +69,55 @@ func NewWriterOptions(dst io.Writer, options WriterOptions) *Writer {
 	return w
 }
 
+// NewWriterContext is like NewWriterOptions, but compression stops when ctx
+// is canceled. Once ctx is done, Write, Flush, and Close return ctx.Err(),
+// and the compressed stream is left incomplete. Cancellation is checked
+// between blocks and periodically during the slower stages of compression,
+// so even a single large Write at a high quality level returns promptly.
+func NewWriterContext(ctx context.Context, dst io.Writer, options WriterOptions) *Writer {
+	w := new(Writer)
+	w.options = options
+	w.ctx = ctx
+	w.Reset(dst)
+	return w
+}
+
 // Reset discards the Writer's state and makes it equivalent to the result of
 // its original state from NewWriter or NewWriterLevel, but writing to dst
 // instead. This permits reusing a Writer rather than allocating a new one.
 func (w *Writer) Reset(dst io.Writer) {
 	encoderInitState(w)
-	w.params.quality = w.options.Quality
-	if w.options.LGWin > 0 {
-		w.params.lgwin = uint(w.options.LGWin)
-	}
+	applyWriterOptions(&w.params, w.options)
+	w.params.ctx = w.ctx
 	w.dst = dst
 	w.err = nil
+	w.stats = matchfinder.Stats{}
+}
+
+// canceled reports whether params.ctx is done. The slow stages of
+// compression check it periodically and stop early if it is; encodeData
+// then discards their results.
+func canceled(params *encoderParams) bool {
+	return params.ctx != nil && params.ctx.Err() != nil
+}
+
+// checkCanceled reports whether w's context is done, and if so, sets w.err
+// to the context's error.
+func (w *Writer) checkCanceled() bool {
+	if !canceled(&w.params) {
+		return false
+	}
+	if w.err == nil {
+		w.err = w.params.ctx.Err()
+	}
+	return true
+}
+
+// reportProgress calls the Progress callback, if there is one.
+func (w *Writer) reportProgress(processed int64) {
+	if w.options.Progress != nil && w.err == nil {
+		w.options.Progress(processed, w.stats.BytesOut)
+	}
 }
 
 func (w *Writer) writeChunk(p []byte, op int) (n int, err error) {
@@ -73,6 +127,9 @@ func (w *Writer) writeChunk(p []byte, op int) (n int, err error) {
 	if w.err != nil {
 		return 0, w.err
 	}
+	if w.checkCanceled() {
+		return 0, w.err
+	}
 
 	for {
 		availableIn := uint(len(p))
@@ -81,7 +138,11 @@ func (w *Writer) writeChunk(p []byte, op int) (n int, err error) {
 		bytesConsumed := len(p) - int(availableIn)
 		p = p[bytesConsumed:]
 		n += bytesConsumed
+		w.stats.BytesIn += int64(bytesConsumed)
 		if !success {
+			if w.err != nil {
+				return n, w.err
+			}
 			return n, errEncode
 		}
 
@@ -114,6 +175,52 @@ func (w *Writer) Write(p []byte) (n int, err error) {
 	return w.writeChunk(p, operationProcess)
 }
 
+// Stats returns statistics about the data w has compressed since it was
+// created or last Reset.
+//
+// At quality levels 0 and 1, matches are found and encoded in a single pass,
+// so the time is all counted as EncodingTime, and the counts of literals,
+// commands, and matches are not available.
+func (w *Writer) Stats() matchfinder.Stats {
+	return w.stats
+}
+
+// recordCommands adds the commands for the meta-block that is about to be
+// written to w.stats.
+func (w *Writer) recordCommands() {
+	dist := w.saved_dist_cache_
+	pos := uint(w.last_flush_pos_)
+	maxBackward := maxBackwardLimit(w.params.lgwin)
+
+	for i := range w.commands {
+		cmd := &w.commands[i]
+		w.stats.Literals += int64(cmd.insert_len_)
+		pos += uint(cmd.insert_len_)
+
+		length := commandCopyLen(cmd)
+		if length == 0 {
+			continue
+		}
+		code := commandRestoreDistanceCode(cmd, &w.params.dist)
+		var distance int
+		if code < numDistanceShortCodes {
+			distance = dist[kDistanceCacheIndex[code]] + kDistanceCacheOffset[code]
+		} else {
+			distance = int(code) - numDistanceShortCodes + 1
+		}
+		// References to the static dictionary don't go into the distance cache.
+		if code != 0 && uint(distance) <= min(pos, maxBackward) {
+			dist[0], dist[1], dist[2], dist[3] = distance, dist[0], dist[1], dist[2]
+		}
+
+		w.stats.Matches++
+		w.stats.MatchLength += int64(length)
+		w.stats.MatchDistance += int64(distance)
+		pos += uint(length)
+	}
+	w.stats.Commands += int64(len(w.commands))
+}
+
 type nopCloser struct {
 	io.Writer
 }
@@ -124,39 +231,123 @@ func (nopCloser) Close() error { return nil }
 // based on the matchfinder package. It currently supports up to level 9;
 // if a higher level is specified, level 9 will be used.
 func NewWriterV2(dst io.Writer, level int) *matchfinder.Writer {
-	if level < 0 {
-		level = 0
-	} else if level > 9 {
-		level = 9
+	return NewWriterV2Options(dst, WriterV2Options{Level: level})
+}
+
+// WriterV2Options configures the Writer returned by NewWriterV2Options.
+type WriterV2Options struct {
+	// Level is the compression level, from 0 to 9, as in NewWriterV2.
+	Level int
+
+	// WindowBits is the base-2 logarithm of the window size. Matches are
+	// limited to distances of (1 << WindowBits) - 16, and the window size is
+	// declared in the stream header. The range is 10 to 24 (or 30 for a
+	// "Large Window Brotli" stream, which requires a Reader with
+	// ReaderOptions.LargeWindow set). At level 0, values over 24 are
+	// treated as 24. If it is zero, 20 is used.
+	WindowBits int
+
+	// BlockSize is the number of bytes to compress at a time. It can be up
+	// to 16 MB. If it is zero, 64 KB is used.
+	BlockSize int
+
+	// Encoder, if non-nil, is used instead of the Encoder or FastEncoder
+	// that Level would choose. It is responsible for writing a stream
+	// header that declares a large enough window for WindowBits.
+	Encoder matchfinder.Encoder
+
+	// AdaptiveBlocks makes the Writer look for places where the statistics
+	// of the data change (as with text followed by binary data), and end
+	// blocks there, instead of always using blocks of BlockSize bytes.
+	// This can help with mixed content, at a small cost in speed.
+	AdaptiveBlocks bool
+
+	// Filter, if it is not nil, is applied to the data before compression
+	// (see matchfinder.Filter), and recorded in a metadata block at the
+	// start of the stream. Use a FilterReader to decompress the stream and
+	// reverse the filter. A FilterReader can only reverse
+	// *matchfinder.DeltaFilter, *matchfinder.StrideFilter, and
+	// *matchfinder.X86Filter; with other filters, it returns an error, and
+	// the caller must decompress the stream with a Reader and reverse the
+	// filter itself. If Encoder is set, it is responsible for recording
+	// the filter.
+	Filter matchfinder.Filter
+
+	// MaxMemory, if positive, is the approximate maximum number of bytes of
+	// memory the Writer should use. To stay within it, the Writer uses a
+	// smaller block size and window, and if necessary a lower level than
+	// the options specify. See EstimateMemoryV2.
+	MaxMemory int
+}
+
+// normalizeV2Options replaces the zero and out-of-range values in options
+// with the ones that NewWriterV2Options uses, and applies MaxMemory.
+func normalizeV2Options(options *WriterV2Options) {
+	options.Level = min(max(options.Level, 0), 9)
+	switch {
+	case options.WindowBits == 0:
+		options.WindowBits = 20
+	case options.WindowBits < minWindowBits:
+		options.WindowBits = minWindowBits
+	case options.WindowBits > largeMaxWindowBits:
+		options.WindowBits = largeMaxWindowBits
+	}
+	if options.Level == 0 {
+		options.WindowBits = min(options.WindowBits, maxWindowBits)
+	}
+	if options.BlockSize <= 0 {
+		options.BlockSize = 1 << 16
 	}
+	options.BlockSize = min(options.BlockSize, 1<<24)
+	if options.MaxMemory > 0 {
+		fitMemoryBudgetV2(options, uint(options.MaxMemory))
+	}
+}
+
+// NewWriterV2Options is like NewWriterV2, but it allows more control over
+// the compression settings.
+func NewWriterV2Options(dst io.Writer, options WriterV2Options) *matchfinder.Writer {
+	normalizeV2Options(&options)
+	level := options.Level
+	windowBits := options.WindowBits
+	blockSize := options.BlockSize
+
+	maxDistance := 1<<windowBits - windowGap
 	var mf matchfinder.MatchFinder
 	switch level {
 	case 0, 1:
-		mf = &matchfinder.ZFast{MaxDistance: 1 << 20}
+		mf = &matchfinder.ZFast{MaxDistance: maxDistance}
 	case 2:
-		mf = &matchfinder.ZDFast{MaxDistance: 1 << 20}
+		mf = &matchfinder.ZDFast{MaxDistance: maxDistance}
 	case 3:
-		mf = &matchfinder.ZM{MaxDistance: 1 << 20}
+		mf = &matchfinder.ZM{MaxDistance: maxDistance}
 	case 4:
-		mf = &matchfinder.Trio{MaxDistance: 1 << 20}
+		mf = &matchfinder.Trio{MaxDistance: maxDistance}
 	case 5, 6:
-		mf = &matchfinder.Bargain1{MaxDistance: 1 << 20}
+		mf = &matchfinder.Bargain1{MaxDistance: maxDistance, CostModel: CostModel{}}
 	case 7:
-		mf = &matchfinder.Bargain2{MaxDistance: 1 << 20, Skip: true}
+		mf = &matchfinder.Bargain2{MaxDistance: maxDistance, Skip: true, CostModel: CostModel{}}
 	case 8:
-		mf = &matchfinder.Bargain2{MaxDistance: 1 << 20}
+		mf = &matchfinder.Bargain2{MaxDistance: maxDistance, CostModel: CostModel{}}
 	case 9:
-		mf = &matchfinder.Bargain3{MaxDistance: 1 << 20}
+		mf = &matchfinder.Bargain3{MaxDistance: maxDistance, CostModel: CostModel{}}
 	}
 
 	w := &matchfinder.Writer{
 		Dest:        dst,
 		MatchFinder: mf,
-		Encoder:     &Encoder{},
-		BlockSize:   1 << 16,
+		Encoder:     &Encoder{WindowBits: windowBits, Filter: options.Filter},
+		BlockSize:   blockSize,
+		Filter:      options.Filter,
+	}
+	if options.AdaptiveBlocks {
+		w.BlockSplitter = &matchfinder.EntropySplitter{}
 	}
-	if level < 1 {
-		w.Encoder = &FastEncoder{}
+	switch {
+	case options.Encoder != nil:
+		w.Encoder = options.Encoder
+	case level < 1:
+		w.Encoder = &FastEncoder{WindowBits: windowBits, Filter: options.Filter}
 	}
 	return w
 }
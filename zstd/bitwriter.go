@@ -0,0 +1,37 @@
+package zstd
+
+import "encoding/binary"
+
+// A bitWriter writes the bitstreams used for FSE and Huffman coding. The
+// bits are packed starting with the least-significant bit of each byte,
+// and the stream is meant to be read backward, starting with the last bit
+// written. To mark where the stream ends, close adds a 1 bit after the
+// data.
+type bitWriter struct {
+	dst   []byte
+	bits  uint64
+	nbits uint
+}
+
+// addBits writes the low n bits of value (n <= 32).
+func (b *bitWriter) addBits(value uint64, n uint) {
+	b.bits |= (value & (1<<n - 1)) << b.nbits
+	b.nbits += n
+	if b.nbits >= 32 {
+		b.dst = binary.LittleEndian.AppendUint32(b.dst, uint32(b.bits))
+		b.bits >>= 32
+		b.nbits -= 32
+	}
+}
+
+// close writes the end mark and any remaining bits, and returns the
+// stream.
+func (b *bitWriter) close() []byte {
+	b.addBits(1, 1)
+	for b.nbits > 0 {
+		b.dst = append(b.dst, byte(b.bits))
+		b.bits >>= 8
+		b.nbits -= min(b.nbits, 8)
+	}
+	return b.dst
+}
@@ -0,0 +1,38 @@
+package zstd
+
+import (
+	"math/bits"
+
+	"github.com/andybalholm/brotli/matchfinder"
+)
+
+// CostModel is a matchfinder.CostModel for Encoder. It estimates the costs
+// of literal lengths, match lengths, and offsets from the extra bits of
+// their codes.
+type CostModel struct{}
+
+func (CostModel) LiteralCosts(costs *[256]float32, src []byte) {
+	matchfinder.LiteralCosts(costs, src)
+}
+
+func (CostModel) InsertCost(n int) float32 {
+	const llSymbolCost = 2
+	return llSymbolCost + float32(llBits[llCode(uint32(n))])
+}
+
+func (CostModel) LengthCost(length int) float32 {
+	const mlSymbolCost = 3
+	if length > maxBlockSize {
+		// Longer matches will be split at a block boundary.
+		return mlSymbolCost + 16 + CostModel{}.LengthCost(length-maxBlockSize)
+	}
+	return mlSymbolCost + float32(mlBits[mlCode(uint32(length-minMatch))])
+}
+
+func (CostModel) DistanceCost(distance int, repeat bool) float32 {
+	const ofSymbolCost = 3
+	if repeat {
+		return ofSymbolCost - 1
+	}
+	return ofSymbolCost + float32(bits.Len(uint(distance+3))-1)
+}
@@ -0,0 +1,200 @@
+// Package zstd implements a matchfinder.Encoder for the Zstandard format
+// (RFC 8878).
+package zstd
+
+import (
+	"encoding/binary"
+
+	"github.com/andybalholm/brotli/matchfinder"
+)
+
+const (
+	frameMagic = 0xFD2FB528
+
+	// frameHeaderDescriptor has only the Content_Checksum_flag set: the
+	// frame has a window descriptor, no dictionary ID, and no content size.
+	frameHeaderDescriptor = 1 << 2
+
+	maxBlockSize = 128 << 10
+
+	blockTypeRaw        = 0
+	blockTypeCompressed = 2
+
+	defaultWindowLog = 23
+)
+
+// An Encoder implements the matchfinder.Encoder interface, writing in
+// Zstandard format.
+//
+// Each call to Encode writes one or more blocks (of up to 128 KB each).
+// Matches may refer to data in previous blocks, as long as they are within
+// the window. Matches that are shorter than 3 bytes or farther back than
+// the window size are stored as literals.
+type Encoder struct {
+	// WindowLog is the base-2 logarithm of the window size declared in the
+	// frame header; matches must not have distances longer than
+	// 1 << WindowLog. It must be between 10 and 31. If it is zero, 23 is
+	// used (8 MB, the largest window that HTTP clients are required to
+	// support).
+	WindowLog int
+
+	wroteHeader bool
+	checksum    xxh64
+
+	// rep holds the repeated offsets.
+	rep [3]uint32
+
+	// buffers to reduce allocations
+	copies      []absoluteCopy
+	seqs        []sequence
+	lits        []byte
+	block       []byte
+	ll, of, ml  sequenceField
+	weights     []byte
+	weightsNorm []int16
+
+	weightsEncoder fseEncoder
+	huffNodes      []huffmanNode
+}
+
+// An absoluteCopy is a match, with its position in the data being encoded.
+type absoluteCopy struct {
+	start, end, distance int
+}
+
+func (e *Encoder) Reset() {
+	e.wroteHeader = false
+}
+
+func (e *Encoder) windowLog() int {
+	if e.WindowLog == 0 {
+		return defaultWindowLog
+	}
+	return e.WindowLog
+}
+
+func (e *Encoder) Encode(dst []byte, src []byte, matches []matchfinder.Match, lastBlock bool) []byte {
+	if !e.wroteHeader {
+		dst = binary.LittleEndian.AppendUint32(dst, frameMagic)
+		dst = append(dst, frameHeaderDescriptor, byte(e.windowLog()-10)<<3)
+		e.checksum.reset()
+		e.rep = [3]uint32{1, 4, 8}
+		e.wroteHeader = true
+	}
+	e.checksum.write(src)
+
+	windowSize := 1 << e.windowLog()
+	blockSize := min(maxBlockSize, windowSize)
+
+	copies := e.copies[:0]
+	pos := 0
+	for _, m := range matches {
+		pos += m.Unmatched
+		if m.Length > 0 {
+			if m.Distance > 0 && m.Distance <= windowSize {
+				copies = append(copies, absoluteCopy{start: pos, end: pos + m.Length, distance: m.Distance})
+			}
+			pos += m.Length
+		}
+	}
+	e.copies = copies
+
+	if len(src) == 0 && lastBlock {
+		dst = appendBlockHeader(dst, true, blockTypeRaw, 0)
+	}
+
+	for blockStart := 0; blockStart < len(src); blockStart += blockSize {
+		blockEnd := min(blockStart+blockSize, len(src))
+		last := lastBlock && blockEnd == len(src)
+
+		seqs := e.seqs[:0]
+		lits := e.lits[:0]
+		savedRep := e.rep
+		nextEmit := blockStart
+		for len(copies) > 0 && copies[0].start < blockEnd {
+			c := copies[0]
+			if c.end > blockEnd {
+				// Leave the rest of the match for the next block.
+				copies[0].start = blockEnd
+			} else {
+				copies = copies[1:]
+			}
+			start := max(c.start, blockStart)
+			end := min(c.end, blockEnd)
+			if end-start < minMatch {
+				continue
+			}
+			lits = append(lits, src[nextEmit:start]...)
+			seqs = append(seqs, sequence{
+				litLen:   uint32(start - nextEmit),
+				matchLen: uint32(end - start),
+				offset:   e.offsetValue(uint32(c.distance), start == nextEmit),
+			})
+			nextEmit = end
+		}
+		lits = append(lits, src[nextEmit:blockEnd]...)
+		e.seqs = seqs
+		e.lits = lits
+
+		block := e.appendLiterals(e.block[:0], lits)
+		block = e.appendSequences(block, seqs)
+		e.block = block
+
+		if len(block) < blockEnd-blockStart {
+			dst = appendBlockHeader(dst, last, blockTypeCompressed, len(block))
+			dst = append(dst, block...)
+		} else {
+			// The decoder won't see the sequences, so its repeated offsets
+			// won't change.
+			e.rep = savedRep
+			dst = appendBlockHeader(dst, last, blockTypeRaw, blockEnd-blockStart)
+			dst = append(dst, src[blockStart:blockEnd]...)
+		}
+	}
+
+	if lastBlock {
+		dst = binary.LittleEndian.AppendUint32(dst, uint32(e.checksum.sum()))
+	}
+	return dst
+}
+
+func appendBlockHeader(dst []byte, last bool, blockType byte, size int) []byte {
+	header := uint32(blockType)<<1 | uint32(size)<<3
+	if last {
+		header |= 1
+	}
+	return append(dst, byte(header), byte(header>>8), byte(header>>16))
+}
+
+// offsetValue returns the Offset_Value for a match at distance, and updates
+// the repeated offsets. noLiterals is whether the sequence's literal length
+// is zero, which changes the meaning of the repeat codes.
+func (e *Encoder) offsetValue(distance uint32, noLiterals bool) uint32 {
+	r := &e.rep
+	if !noLiterals {
+		switch distance {
+		case r[0]:
+			return 1
+		case r[1]:
+			r[0], r[1] = r[1], r[0]
+			return 2
+		case r[2]:
+			r[0], r[1], r[2] = r[2], r[0], r[1]
+			return 3
+		}
+	} else {
+		switch distance {
+		case r[1]:
+			r[0], r[1] = r[1], r[0]
+			return 1
+		case r[2]:
+			r[0], r[1], r[2] = r[2], r[0], r[1]
+			return 2
+		case r[0] - 1:
+			r[0], r[1], r[2] = r[0]-1, r[0], r[1]
+			return 3
+		}
+	}
+	r[0], r[1], r[2] = distance, r[0], r[1]
+	return distance + 3
+}
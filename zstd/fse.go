@@ -0,0 +1,269 @@
+package zstd
+
+import (
+	"math/bits"
+
+	"github.com/andybalholm/brotli/matchfinder"
+)
+
+const minTableLog = 5
+
+// An fseEncoder holds an FSE (finite state entropy) table, in the form that
+// is used for encoding.
+type fseEncoder struct {
+	tableLog uint
+
+	// norm holds the normalized counts of the symbols. The counts add up to
+	// 1 << tableLog, except that -1 is a "less than one" count that uses one
+	// slot.
+	norm []int16
+
+	stateTable  []uint16
+	symbolTT    []symbolTransform
+	tableSymbol []byte
+	cumul       []int
+}
+
+type symbolTransform struct {
+	deltaNbBits    uint32
+	deltaFindState int32
+}
+
+// build prepares e to encode with the given normalized counts.
+func (e *fseEncoder) build(norm []int16, tableLog uint) {
+	tableSize := 1 << tableLog
+	e.tableLog = tableLog
+	e.norm = norm
+	e.stateTable = resize(e.stateTable, tableSize)
+	e.symbolTT = resize(e.symbolTT, len(norm))
+	e.tableSymbol = resize(e.tableSymbol, tableSize)
+	e.cumul = resize(e.cumul, len(norm)+1)
+
+	// Symbols with "less than one" counts go at the end of the table.
+	highThreshold := tableSize - 1
+	e.cumul[0] = 0
+	for s, n := range norm {
+		if n == -1 {
+			e.cumul[s+1] = e.cumul[s] + 1
+			e.tableSymbol[highThreshold] = byte(s)
+			highThreshold--
+		} else {
+			e.cumul[s+1] = e.cumul[s] + int(n)
+		}
+	}
+
+	// Spread the other symbols through the table.
+	step := tableSize>>1 + tableSize>>3 + 3
+	mask := tableSize - 1
+	pos := 0
+	for s, n := range norm {
+		for i := 0; i < int(n); i++ {
+			e.tableSymbol[pos] = byte(s)
+			pos = (pos + step) & mask
+			for pos > highThreshold {
+				pos = (pos + step) & mask
+			}
+		}
+	}
+
+	for u := range tableSize {
+		s := e.tableSymbol[u]
+		e.stateTable[e.cumul[s]] = uint16(tableSize + u)
+		e.cumul[s]++
+	}
+
+	total := int32(0)
+	for s, n := range norm {
+		switch n {
+		case 0:
+			e.symbolTT[s] = symbolTransform{deltaNbBits: uint32(tableLog+1)<<16 - uint32(tableSize)}
+		case -1, 1:
+			e.symbolTT[s] = symbolTransform{
+				deltaNbBits:    uint32(tableLog)<<16 - uint32(tableSize),
+				deltaFindState: total - 1,
+			}
+			total++
+		default:
+			maxBitsOut := uint32(tableLog) - uint32(bits.Len16(uint16(n-1))-1)
+			minStatePlus := uint32(n) << maxBitsOut
+			e.symbolTT[s] = symbolTransform{
+				deltaNbBits:    maxBitsOut<<16 - minStatePlus,
+				deltaFindState: total - int32(n),
+			}
+			total += int32(n)
+		}
+	}
+}
+
+// cost estimates the number of bits needed to encode the symbols counted
+// in counts with e.
+func (e *fseEncoder) cost(counts []int) float64 {
+	var c float64
+	for s, n := range counts {
+		if n == 0 {
+			continue
+		}
+		p := uint(max(e.norm[s], 1))
+		// The conversion keeps the multiplication and addition from being
+		// fused, which would make the result platform-dependent.
+		c += float64(float64(n) * (float64(e.tableLog) - matchfinder.Log2(p)))
+	}
+	return c
+}
+
+// An fseState is the state of an FSE encoder.
+type fseState struct {
+	value uint32
+	e     *fseEncoder
+}
+
+// init sets the initial state, which encodes symbol without writing any
+// bits. (The symbols are encoded in reverse order, so this will be the last
+// symbol decoded.)
+func (s *fseState) init(e *fseEncoder, symbol byte) {
+	s.e = e
+	tt := e.symbolTT[symbol]
+	nbBitsOut := (tt.deltaNbBits + 1<<15) >> 16
+	value := nbBitsOut<<16 - tt.deltaNbBits
+	s.value = uint32(e.stateTable[int32(value>>nbBitsOut)+tt.deltaFindState])
+}
+
+func (s *fseState) encode(b *bitWriter, symbol byte) {
+	tt := s.e.symbolTT[symbol]
+	nbBitsOut := (s.value + tt.deltaNbBits) >> 16
+	b.addBits(uint64(s.value), uint(nbBitsOut))
+	s.value = uint32(s.e.stateTable[int32(s.value>>nbBitsOut)+tt.deltaFindState])
+}
+
+// flush writes the final state.
+func (s *fseState) flush(b *bitWriter) {
+	b.addBits(uint64(s.value), s.e.tableLog)
+}
+
+// optimalTableLog chooses a table log for n symbols whose largest value is
+// maxSymbol.
+func optimalTableLog(maxLog uint, n, maxSymbol int) uint {
+	srcLog := bits.Len(uint(n-1)) - 1
+	tableLog := min(int(maxLog), srcLog-2)
+	tableLog = max(tableLog, min(srcLog+1, bits.Len(uint(maxSymbol))+1))
+	return uint(max(min(tableLog, int(maxLog)), minTableLog))
+}
+
+// normalizeCounts scales counts (whose sum is total) so that they add up to
+// 1 << tableLog, and stores the result in norm. Every symbol that occurs
+// gets a count of at least 1.
+func normalizeCounts(norm []int16, counts []int, total int, tableLog uint) []int16 {
+	tableSize := 1 << tableLog
+	norm = resize(norm, len(counts))
+	sum := 0
+	largest := 0
+	for s, n := range counts {
+		if n == 0 {
+			norm[s] = 0
+			continue
+		}
+		v := max((n*tableSize+total/2)/total, 1)
+		norm[s] = int16(v)
+		sum += v
+		if n > counts[largest] {
+			largest = s
+		}
+	}
+
+	if sum < tableSize {
+		norm[largest] += int16(tableSize - sum)
+	}
+	for ; sum > tableSize; sum-- {
+		// Take a slot away from the symbol that has the most.
+		biggest := 0
+		for s, v := range norm {
+			if v > norm[biggest] {
+				biggest = s
+			}
+		}
+		norm[biggest]--
+	}
+	return norm
+}
+
+// appendNormalizedCounts appends the FSE table description for norm to dst.
+func appendNormalizedCounts(dst []byte, norm []int16, tableLog uint) []byte {
+	tableSize := 1 << tableLog
+	remaining := tableSize + 1
+	threshold := tableSize
+	nbBits := int(tableLog) + 1
+
+	bitStream := uint32(tableLog - minTableLog)
+	bitCount := 4
+	previousIs0 := false
+
+	for symbol := 0; symbol < len(norm) && remaining > 1; {
+		if previousIs0 {
+			// Encode the number of symbols with zero counts.
+			start := symbol
+			for norm[symbol] == 0 {
+				symbol++
+			}
+			for symbol >= start+24 {
+				start += 24
+				bitStream += 0xffff << bitCount
+				dst = append(dst, byte(bitStream), byte(bitStream>>8))
+				bitStream >>= 16
+			}
+			for symbol >= start+3 {
+				start += 3
+				bitStream += 3 << bitCount
+				bitCount += 2
+			}
+			bitStream += uint32(symbol-start) << bitCount
+			bitCount += 2
+			if bitCount > 16 {
+				dst = append(dst, byte(bitStream), byte(bitStream>>8))
+				bitStream >>= 16
+				bitCount -= 16
+			}
+		}
+
+		count := int(norm[symbol])
+		symbol++
+		maxValue := 2*threshold - 1 - remaining
+		if count < 0 {
+			remaining += count
+		} else {
+			remaining -= count
+		}
+		count++ // +1 for extra accuracy
+		if count >= threshold {
+			count += maxValue
+		}
+		bitStream += uint32(count) << bitCount
+		bitCount += nbBits
+		if count < maxValue {
+			bitCount--
+		}
+		previousIs0 = count == 1
+		for remaining < threshold {
+			nbBits--
+			threshold >>= 1
+		}
+		if bitCount > 16 {
+			dst = append(dst, byte(bitStream), byte(bitStream>>8))
+			bitStream >>= 16
+			bitCount -= 16
+		}
+	}
+
+	for ; bitCount > 0; bitCount -= 8 {
+		dst = append(dst, byte(bitStream))
+		bitStream >>= 8
+	}
+	return dst
+}
+
+// resize returns s with length n, reusing its storage if possible.
+func resize[T any](s []T, n int) []T {
+	if cap(s) < n {
+		return make([]T, n)
+	}
+	return s[:n]
+}
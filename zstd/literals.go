@@ -0,0 +1,319 @@
+package zstd
+
+import (
+	"encoding/binary"
+	"slices"
+)
+
+// Literals block types.
+const (
+	literalsRaw        = 0
+	literalsRLE        = 1
+	literalsCompressed = 2
+)
+
+const (
+	maxHuffmanBits = 11
+
+	// minHuffmanLiterals is the smallest number of literals that is worth
+	// compressing with Huffman coding.
+	minHuffmanLiterals = 64
+
+	// maxWeightsLog is the largest table log for the FSE compression of
+	// Huffman weights.
+	maxWeightsLog = 6
+)
+
+// appendLiterals appends the literals section of a block to dst.
+func (e *Encoder) appendLiterals(dst []byte, lits []byte) []byte {
+	if len(lits) == 0 {
+		return appendLiteralsHeader(dst, literalsRaw, 0)
+	}
+
+	var counts [256]int
+	for _, c := range lits {
+		counts[c]++
+	}
+	if counts[lits[0]] == len(lits) {
+		dst = appendLiteralsHeader(dst, literalsRLE, len(lits))
+		return append(dst, lits[0])
+	}
+
+	if len(lits) >= minHuffmanLiterals {
+		if compressed, ok := e.appendHuffmanLiterals(dst, lits, &counts); ok {
+			return compressed
+		}
+	}
+
+	dst = appendLiteralsHeader(dst, literalsRaw, len(lits))
+	return append(dst, lits...)
+}
+
+// appendLiteralsHeader appends the header for a raw or RLE literals block.
+func appendLiteralsHeader(dst []byte, blockType byte, size int) []byte {
+	switch {
+	case size < 32:
+		return append(dst, blockType|byte(size)<<3)
+	case size < 4096:
+		return append(dst, blockType|1<<2|byte(size)<<4, byte(size>>4))
+	default:
+		return append(dst, blockType|3<<2|byte(size)<<4, byte(size>>4), byte(size>>12))
+	}
+}
+
+// appendHuffmanLiterals appends a Huffman-compressed literals block to dst.
+// If it isn't smaller than the raw literals, it returns false.
+func (e *Encoder) appendHuffmanLiterals(dst []byte, lits []byte, counts *[256]int) ([]byte, bool) {
+	var lengths [256]uint8
+	buildHuffmanLengths(&lengths, counts, maxHuffmanBits, &e.huffNodes)
+	maxBits := uint8(0)
+	lastSymbol := 0
+	for s, n := range lengths {
+		if n > 0 {
+			lastSymbol = s
+			maxBits = max(maxBits, n)
+		}
+	}
+
+	// Assign the codes. Longer codes get smaller values, and symbols with
+	// the same length are in order by symbol value.
+	var codes [256]uint16
+	code := uint16(0)
+	for n := maxBits; n > 0; n-- {
+		for s, l := range lengths {
+			if l == n {
+				codes[s] = code
+				code++
+			}
+		}
+		code >>= 1
+	}
+
+	// The Huffman tree description is a list of weights, where the weight of
+	// a symbol with a code of length n is maxBits + 1 - n. The weight of the
+	// last symbol is left out, since it can be calculated from the others.
+	weights := e.weights[:0]
+	for _, n := range lengths[:lastSymbol] {
+		if n == 0 {
+			weights = append(weights, 0)
+		} else {
+			weights = append(weights, maxBits+1-n)
+		}
+	}
+	e.weights = weights
+
+	headerLen := 3
+	if len(lits) > 1023 {
+		headerLen = 4
+		if len(lits) > 16383 {
+			headerLen = 5
+		}
+	}
+	start := len(dst)
+	dst = append(dst, make([]byte, headerLen)...)
+	dst, ok := e.appendHuffmanWeights(dst, weights)
+	if !ok {
+		return dst[:start], false
+	}
+
+	bw := bitWriter{dst: dst}
+	encodeStream := func(lits []byte) {
+		for i := len(lits) - 1; i >= 0; i-- {
+			c := lits[i]
+			bw.addBits(uint64(codes[c]), uint(lengths[c]))
+		}
+		bw.dst = bw.close()
+	}
+
+	sizeFormat := 0
+	if len(lits) <= 1023 {
+		encodeStream(lits)
+	} else {
+		// Four streams, with a jump table giving the sizes of the first
+		// three.
+		sizeFormat = headerLen - 2
+		segment := (len(lits) + 3) / 4
+		jumpTable := len(bw.dst)
+		bw.dst = append(bw.dst, make([]byte, 6)...)
+		for i := range 4 {
+			streamStart := len(bw.dst)
+			encodeStream(lits[i*segment : min((i+1)*segment, len(lits))])
+			if i < 3 {
+				size := len(bw.dst) - streamStart
+				if size > 0xffff {
+					return dst[:start], false
+				}
+				binary.LittleEndian.PutUint16(bw.dst[jumpTable+2*i:], uint16(size))
+			}
+		}
+	}
+	dst = bw.dst
+
+	compressedSize := len(dst) - start - headerLen
+	if compressedSize >= len(lits) {
+		return dst[:start], false
+	}
+
+	sizeBits := 10
+	switch headerLen {
+	case 4:
+		sizeBits = 14
+	case 5:
+		sizeBits = 18
+	}
+	header := uint64(literalsCompressed) | uint64(sizeFormat)<<2 | uint64(len(lits))<<4 | uint64(compressedSize)<<(4+sizeBits)
+	for i := range headerLen {
+		dst[start+i] = byte(header >> (8 * i))
+	}
+	return dst, true
+}
+
+// appendHuffmanWeights appends the Huffman tree description for weights to
+// dst, compressing it with FSE if that makes it smaller.
+func (e *Encoder) appendHuffmanWeights(dst []byte, weights []byte) ([]byte, bool) {
+	start := len(dst)
+	if compressed, ok := e.appendFSEWeights(append(dst, 0), weights); ok {
+		size := len(compressed) - start - 1
+		if size < 128 && (len(weights) > 128 || size < (len(weights)+1)/2) {
+			compressed[start] = byte(size)
+			return compressed, true
+		}
+	}
+	dst = dst[:start]
+
+	if len(weights) > 128 {
+		return dst, false
+	}
+	dst = append(dst, byte(127+len(weights)))
+	for i := 0; i < len(weights); i += 2 {
+		b := weights[i] << 4
+		if i+1 < len(weights) {
+			b |= weights[i+1]
+		}
+		dst = append(dst, b)
+	}
+	return dst, true
+}
+
+// appendFSEWeights appends the Huffman weights, compressed with FSE, to
+// dst. The weights are encoded with two interleaved FSE states: state 1
+// for the even-numbered weights, and state 2 for the odd-numbered ones.
+func (e *Encoder) appendFSEWeights(dst []byte, weights []byte) ([]byte, bool) {
+	var counts [maxHuffmanBits + 1]int
+	maxSymbol := 0
+	distinct := 0
+	for _, w := range weights {
+		if counts[w] == 0 {
+			distinct++
+		}
+		counts[w]++
+		maxSymbol = max(maxSymbol, int(w))
+	}
+	if distinct < 2 || len(weights) < 2 {
+		return dst, false
+	}
+
+	tableLog := optimalTableLog(maxWeightsLog, len(weights), maxSymbol)
+	e.weightsNorm = normalizeCounts(e.weightsNorm, counts[:maxSymbol+1], len(weights), tableLog)
+	e.weightsEncoder.build(e.weightsNorm, tableLog)
+	dst = appendNormalizedCounts(dst, e.weightsNorm, tableLog)
+
+	bw := bitWriter{dst: dst}
+	var states [2]fseState
+	initialized := [2]bool{}
+	for i := len(weights) - 1; i >= 0; i-- {
+		s := &states[i&1]
+		if !initialized[i&1] {
+			s.init(&e.weightsEncoder, weights[i])
+			initialized[i&1] = true
+		} else {
+			s.encode(&bw, weights[i])
+		}
+	}
+	states[1].flush(&bw)
+	states[0].flush(&bw)
+	return bw.close(), true
+}
+
+// A huffmanNode is a node in the tree built by buildHuffmanLengths.
+type huffmanNode struct {
+	count  int
+	symbol int // the symbol, or -1 for an internal node
+	parent int
+	depth  uint8
+}
+
+// buildHuffmanLengths sets lengths to the code lengths of a Huffman code
+// for counts, limited to maxBits. There must be at least two symbols with
+// nonzero counts.
+func buildHuffmanLengths(lengths *[256]uint8, counts *[256]int, maxBits uint8, buf *[]huffmanNode) {
+	nodes := (*buf)[:0]
+	for s, n := range counts {
+		if n > 0 {
+			nodes = append(nodes, huffmanNode{count: n, symbol: s})
+		}
+	}
+	slices.SortStableFunc(nodes, func(a, b huffmanNode) int {
+		return a.count - b.count
+	})
+	numLeaves := len(nodes)
+
+	// Build the tree with the two-queue method: the leaves are sorted by
+	// count, and the internal nodes are created in order of count.
+	leaf, internal := 0, numLeaves
+	next := func() int {
+		if leaf < numLeaves && (internal == len(nodes) || nodes[leaf].count <= nodes[internal].count) {
+			leaf++
+			return leaf - 1
+		}
+		internal++
+		return internal - 1
+	}
+	for range numLeaves - 1 {
+		a := next()
+		b := next()
+		nodes = append(nodes, huffmanNode{count: nodes[a].count + nodes[b].count, symbol: -1, parent: -1})
+		nodes[a].parent = len(nodes) - 1
+		nodes[b].parent = len(nodes) - 1
+	}
+	*buf = nodes
+
+	// Find the depths, and count the leaves at each depth, putting the
+	// ones that are too deep at maxBits.
+	var numCodes [64]int
+	for i := len(nodes) - 2; i >= 0; i-- {
+		nodes[i].depth = nodes[nodes[i].parent].depth + 1
+	}
+	for _, n := range nodes[:numLeaves] {
+		numCodes[min(n.depth, maxBits)]++
+	}
+
+	// If some codes were shortened, the code is over-subscribed. Fix it by
+	// lengthening other codes (as in zlib and miniz).
+	total := 0
+	for i := uint8(1); i <= maxBits; i++ {
+		total += numCodes[i] << (maxBits - i)
+	}
+	for total > 1<<maxBits {
+		numCodes[maxBits]--
+		for i := maxBits - 1; i > 0; i-- {
+			if numCodes[i] > 0 {
+				numCodes[i]--
+				numCodes[i+1] += 2
+				break
+			}
+		}
+		total--
+	}
+
+	// Assign the lengths, with the shortest codes going to the most
+	// frequent symbols.
+	*lengths = [256]uint8{}
+	i := numLeaves - 1
+	for n := uint8(1); n <= maxBits; n++ {
+		for range numCodes[n] {
+			lengths[nodes[i].symbol] = n
+			i--
+		}
+	}
+}
@@ -0,0 +1,256 @@
+package zstd
+
+import "math/bits"
+
+// A sequence is the zstd form of a match: a number of literals, followed by
+// a copy.
+type sequence struct {
+	litLen   uint32
+	matchLen uint32
+	// offset is the Offset_Value: 1–3 for repeated offsets, or the
+	// distance + 3.
+	offset uint32
+}
+
+const (
+	maxLLCode = 35
+	maxMLCode = 52
+	maxOFCode = 31
+
+	maxLLLog = 9
+	maxMLLog = 9
+	maxOFLog = 8
+
+	minMatch = 3
+)
+
+var llBase = [maxLLCode + 1]uint32{
+	0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15,
+	16, 18, 20, 22, 24, 28, 32, 40, 48, 64, 128, 256, 512, 1024, 2048, 4096,
+	8192, 16384, 32768, 65536,
+}
+
+var llBits = [maxLLCode + 1]uint8{
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	1, 1, 1, 1, 2, 2, 3, 3, 4, 6, 7, 8, 9, 10, 11, 12,
+	13, 14, 15, 16,
+}
+
+// mlBase holds the baselines for match lengths, minus minMatch.
+var mlBase = [maxMLCode + 1]uint32{
+	0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15,
+	16, 17, 18, 19, 20, 21, 22, 23, 24, 25, 26, 27, 28, 29, 30, 31,
+	32, 34, 36, 38, 40, 44, 48, 56, 64, 80, 96, 128, 256, 512, 1024, 2048,
+	4096, 8192, 16384, 32768, 65536,
+}
+
+var mlBits = [maxMLCode + 1]uint8{
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0,
+	1, 1, 1, 1, 2, 2, 3, 3, 4, 4, 5, 7, 8, 9, 10, 11,
+	12, 13, 14, 15, 16,
+}
+
+// The predefined distributions, from RFC 8878 section 3.1.1.3.2.2.
+var (
+	llPredefined = []int16{
+		4, 3, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 1, 1, 1,
+		2, 2, 2, 2, 2, 2, 2, 2, 2, 3, 2, 1, 1, 1, 1, 1,
+		-1, -1, -1, -1,
+	}
+	mlPredefined = []int16{
+		1, 4, 3, 2, 2, 2, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, 1, -1, -1,
+		-1, -1, -1, -1, -1,
+	}
+	ofPredefined = []int16{
+		1, 1, 1, 1, 1, 1, 2, 2, 2, 1, 1, 1, 1, 1, 1, 1,
+		1, 1, 1, 1, 1, 1, 1, 1, -1, -1, -1, -1, -1,
+	}
+
+	llPredefinedEncoder, mlPredefinedEncoder, ofPredefinedEncoder fseEncoder
+)
+
+func init() {
+	llPredefinedEncoder.build(llPredefined, 6)
+	mlPredefinedEncoder.build(mlPredefined, 6)
+	ofPredefinedEncoder.build(ofPredefined, 5)
+}
+
+func llCode(litLen uint32) byte {
+	if litLen < 16 {
+		return byte(litLen)
+	}
+	if litLen >= 64 {
+		return byte(bits.Len32(litLen) - 1 + 19)
+	}
+	code := byte(16)
+	for llBase[code+1] <= litLen {
+		code++
+	}
+	return code
+}
+
+// mlCode returns the code for a match length, minus minMatch.
+func mlCode(mlBaseValue uint32) byte {
+	if mlBaseValue < 32 {
+		return byte(mlBaseValue)
+	}
+	if mlBaseValue >= 128 {
+		return byte(bits.Len32(mlBaseValue) - 1 + 36)
+	}
+	code := byte(32)
+	for mlBase[code+1] <= mlBaseValue {
+		code++
+	}
+	return code
+}
+
+func ofCode(offset uint32) byte {
+	return byte(bits.Len32(offset) - 1)
+}
+
+// Symbol compression modes.
+const (
+	modePredefined = 0
+	modeRLE        = 1
+	modeFSE        = 2
+)
+
+// A sequenceField holds the codes for one of the three fields of the
+// sequences (literal length, offset, or match length), and the table
+// chosen to encode them.
+type sequenceField struct {
+	codes  []byte
+	counts []int
+	mode   byte
+	norm   []int16
+	custom fseEncoder
+	enc    *fseEncoder
+}
+
+// chooseTable sets f.mode and f.enc, and appends the table description (if
+// any) to dst.
+func (f *sequenceField) chooseTable(dst []byte, maxLog uint, predefined *fseEncoder) []byte {
+	maxSymbol := 0
+	distinct := 0
+	for s, n := range f.counts {
+		if n > 0 {
+			maxSymbol = s
+			distinct++
+		}
+	}
+	if distinct == 1 {
+		f.mode = modeRLE
+		f.enc = nil
+		return append(dst, byte(maxSymbol))
+	}
+
+	n := len(f.codes)
+	tableLog := optimalTableLog(maxLog, n, maxSymbol)
+	f.norm = normalizeCounts(f.norm, f.counts[:maxSymbol+1], n, tableLog)
+	f.custom.build(f.norm, tableLog)
+	description := appendNormalizedCounts(dst, f.norm, tableLog)
+	customCost := f.custom.cost(f.counts) + float64(8*(len(description)-len(dst)))
+
+	if maxSymbol < len(predefined.norm) && predefined.cost(f.counts) <= customCost {
+		f.mode = modePredefined
+		f.enc = predefined
+		return dst
+	}
+	f.mode = modeFSE
+	f.enc = &f.custom
+	return description
+}
+
+// appendSequences appends the sequences section of a block to dst.
+func (e *Encoder) appendSequences(dst []byte, seqs []sequence) []byte {
+	n := len(seqs)
+	switch {
+	case n < 128:
+		dst = append(dst, byte(n))
+	case n < 0x7f00:
+		dst = append(dst, byte(n>>8)+128, byte(n))
+	default:
+		dst = append(dst, 255, byte(n-0x7f00), byte((n-0x7f00)>>8))
+	}
+	if n == 0 {
+		return dst
+	}
+
+	ll, of, ml := &e.ll, &e.of, &e.ml
+	ll.codes = resize(ll.codes, n)
+	of.codes = resize(of.codes, n)
+	ml.codes = resize(ml.codes, n)
+	ll.counts = resize(ll.counts, maxLLCode+1)
+	of.counts = resize(of.counts, maxOFCode+1)
+	ml.counts = resize(ml.counts, maxMLCode+1)
+	clear(ll.counts)
+	clear(of.counts)
+	clear(ml.counts)
+	for i, s := range seqs {
+		c := llCode(s.litLen)
+		ll.codes[i] = c
+		ll.counts[c]++
+		c = ofCode(s.offset)
+		of.codes[i] = c
+		of.counts[c]++
+		c = mlCode(s.matchLen - minMatch)
+		ml.codes[i] = c
+		ml.counts[c]++
+	}
+
+	modesIndex := len(dst)
+	dst = append(dst, 0)
+	dst = ll.chooseTable(dst, maxLLLog, &llPredefinedEncoder)
+	dst = of.chooseTable(dst, maxOFLog, &ofPredefinedEncoder)
+	dst = ml.chooseTable(dst, maxMLLog, &mlPredefinedEncoder)
+	dst[modesIndex] = ll.mode<<6 | of.mode<<4 | ml.mode<<2
+
+	// The sequences are encoded in reverse order, so that the decoder can
+	// read them forward.
+	bw := bitWriter{dst: dst}
+	var llState, ofState, mlState fseState
+	last := n - 1
+	if ml.enc != nil {
+		mlState.init(ml.enc, ml.codes[last])
+	}
+	if of.enc != nil {
+		ofState.init(of.enc, of.codes[last])
+	}
+	if ll.enc != nil {
+		llState.init(ll.enc, ll.codes[last])
+	}
+	e.addExtraBits(&bw, seqs[last], ll.codes[last], of.codes[last], ml.codes[last])
+
+	for i := last - 1; i >= 0; i-- {
+		if of.enc != nil {
+			ofState.encode(&bw, of.codes[i])
+		}
+		if ml.enc != nil {
+			mlState.encode(&bw, ml.codes[i])
+		}
+		if ll.enc != nil {
+			llState.encode(&bw, ll.codes[i])
+		}
+		e.addExtraBits(&bw, seqs[i], ll.codes[i], of.codes[i], ml.codes[i])
+	}
+
+	if ml.enc != nil {
+		mlState.flush(&bw)
+	}
+	if of.enc != nil {
+		ofState.flush(&bw)
+	}
+	if ll.enc != nil {
+		llState.flush(&bw)
+	}
+	return bw.close()
+}
+
+func (e *Encoder) addExtraBits(bw *bitWriter, s sequence, llc, ofc, mlc byte) {
+	bw.addBits(uint64(s.litLen-llBase[llc]), uint(llBits[llc]))
+	bw.addBits(uint64(s.matchLen-minMatch-mlBase[mlc]), uint(mlBits[mlc]))
+	bw.addBits(uint64(s.offset), uint(ofc))
+}
@@ -0,0 +1,55 @@
+package zstd
+
+import (
+	"io"
+
+	"github.com/andybalholm/brotli/matchfinder"
+)
+
+// NewWriter returns a new matchfinder.Writer that compresses data at the
+// given level, in Zstandard format. Levels 1–9 are available; levels
+// outside this range will be replaced with the closest level available.
+func NewWriter(w io.Writer, level int) *matchfinder.Writer {
+	const maxDistance = 1 << 20
+
+	var mf matchfinder.MatchFinder
+	switch {
+	case level < 2:
+		mf = &matchfinder.ZFast{MaxDistance: maxDistance}
+	case level == 2:
+		mf = &matchfinder.ZDFast{MaxDistance: maxDistance}
+	case level == 3:
+		mf = &matchfinder.ZM{MaxDistance: maxDistance}
+	case level == 4:
+		mf = &matchfinder.Trio{MaxDistance: maxDistance}
+	case level < 8:
+		chainLen := 32
+		switch level {
+		case 5:
+			chainLen = 8
+		case 6:
+			chainLen = 16
+		}
+		mf = &matchfinder.M4{
+			MaxDistance:     maxDistance,
+			ChainLength:     chainLen,
+			HashLen:         5,
+			DistanceBitCost: 40,
+		}
+	case level == 8:
+		mf = &matchfinder.Bargain2{MaxDistance: maxDistance, CostModel: CostModel{}}
+	default:
+		mf = &matchfinder.BinaryTree{
+			MaxDistance: maxDistance,
+			MinLength:   4,
+			CostModel:   CostModel{},
+		}
+	}
+
+	return &matchfinder.Writer{
+		Dest:        w,
+		MatchFinder: mf,
+		Encoder:     &Encoder{WindowLog: 20},
+		BlockSize:   1 << 16,
+	}
+}
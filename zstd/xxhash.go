@@ -0,0 +1,112 @@
+package zstd
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+const (
+	prime1 uint64 = 11400714785074694791
+	prime2 uint64 = 14029467366897019727
+	prime3 uint64 = 1609587929392839161
+	prime4 uint64 = 9650029242287828579
+	prime5 uint64 = 2870177450012600261
+)
+
+// xxh64 computes the 64-bit xxHash of a stream of data, with a seed of 0.
+// The low 32 bits are used for the content checksum.
+type xxh64 struct {
+	v     [4]uint64
+	total uint64
+	buf   [32]byte
+	n     int
+}
+
+func (x *xxh64) reset() {
+	p1 := prime1 // a variable, so that the arithmetic wraps around
+	x.v = [4]uint64{p1 + prime2, prime2, 0, -p1}
+	x.total = 0
+	x.n = 0
+}
+
+func xxh64Round(acc, input uint64) uint64 {
+	return bits.RotateLeft64(acc+input*prime2, 31) * prime1
+}
+
+func xxh64Merge(acc, v uint64) uint64 {
+	acc ^= xxh64Round(0, v)
+	return acc*prime1 + prime4
+}
+
+func (x *xxh64) stripe(b []byte) {
+	x.v[0] = xxh64Round(x.v[0], binary.LittleEndian.Uint64(b[0:]))
+	x.v[1] = xxh64Round(x.v[1], binary.LittleEndian.Uint64(b[8:]))
+	x.v[2] = xxh64Round(x.v[2], binary.LittleEndian.Uint64(b[16:]))
+	x.v[3] = xxh64Round(x.v[3], binary.LittleEndian.Uint64(b[24:]))
+}
+
+func (x *xxh64) write(b []byte) {
+	x.total += uint64(len(b))
+
+	if x.n > 0 {
+		c := copy(x.buf[x.n:], b)
+		x.n += c
+		b = b[c:]
+		if x.n < len(x.buf) {
+			return
+		}
+		x.stripe(x.buf[:])
+		x.n = 0
+	}
+
+	for len(b) >= 32 {
+		x.stripe(b)
+		b = b[32:]
+	}
+	x.n = copy(x.buf[:], b)
+}
+
+func (x *xxh64) sum() uint64 {
+	var h uint64
+	if x.total >= 32 {
+		h = bits.RotateLeft64(x.v[0], 1) + bits.RotateLeft64(x.v[1], 7) +
+			bits.RotateLeft64(x.v[2], 12) + bits.RotateLeft64(x.v[3], 18)
+		for _, v := range x.v {
+			h = xxh64Merge(h, v)
+		}
+	} else {
+		h = prime5
+	}
+	h += x.total
+
+	b := x.buf[:x.n]
+	for len(b) >= 8 {
+		h ^= xxh64Round(0, binary.LittleEndian.Uint64(b))
+		h = bits.RotateLeft64(h, 27)*prime1 + prime4
+		b = b[8:]
+	}
+	if len(b) >= 4 {
+		h ^= uint64(binary.LittleEndian.Uint32(b)) * prime1
+		h = bits.RotateLeft64(h, 23)*prime2 + prime3
+		b = b[4:]
+	}
+	for _, c := range b {
+		h ^= uint64(c) * prime5
+		h = bits.RotateLeft64(h, 11) * prime1
+	}
+
+	h ^= h >> 33
+	h *= prime2
+	h ^= h >> 29
+	h *= prime3
+	h ^= h >> 32
+	return h
+}
+
+// xxh64Sum returns the 64-bit xxHash of b.
+func xxh64Sum(b []byte) uint64 {
+	var x xxh64
+	x.reset()
+	x.write(b)
+	return x.sum()
+}
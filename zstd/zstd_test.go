@@ -0,0 +1,146 @@
+package zstd
+
+import (
+	"bytes"
+	"math/rand"
+	"os"
+	"testing"
+
+	"github.com/andybalholm/brotli/matchfinder"
+	kzstd "github.com/klauspost/compress/zstd"
+)
+
+func decode(t *testing.T, stream []byte) []byte {
+	t.Helper()
+	d, err := kzstd.NewReader(nil, kzstd.WithDecoderMaxWindow(1<<31))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer d.Close()
+	out, err := d.DecodeAll(stream, nil)
+	if err != nil {
+		t.Fatalf("error decompressing: %v", err)
+	}
+	return out
+}
+
+func TestXXH64(t *testing.T) {
+	for _, c := range []struct {
+		data string
+		sum  uint64
+	}{
+		{"", 0xEF46DB3751D8E999},
+		{"a", 0xD24EC4F1A98C6E5B},
+		{"abc", 0x44BC2CF5AD770999},
+		{"Nobody inspects the spammish repetition", 0xFBCEA83C8A378BF1},
+	} {
+		if got := xxh64Sum([]byte(c.data)); got != c.sum {
+			t.Errorf("xxh64(%q) = %#x, want %#x", c.data, got, c.sum)
+		}
+	}
+
+	// Writing in pieces should give the same result as all at once.
+	data, err := os.ReadFile("../testdata/Isaac.Newton-Opticks.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var h xxh64
+	h.reset()
+	for rest := data; len(rest) > 0; {
+		n := min(len(rest), 1+len(rest)%77)
+		h.write(rest[:n])
+		rest = rest[n:]
+	}
+	if got, want := h.sum(), xxh64Sum(data); got != want {
+		t.Errorf("streaming xxh64 = %#x, want %#x", got, want)
+	}
+}
+
+func testData(t *testing.T) [][]byte {
+	text, err := os.ReadFile("../testdata/Isaac.Newton-Opticks.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	random := make([]byte, 100000)
+	rand.New(rand.NewSource(1)).Read(random)
+	return [][]byte{text, random, make([]byte, 300000), nil, []byte("a"), text[:500], bytes.Repeat([]byte("ab"), 20)}
+}
+
+func TestWriterLevels(t *testing.T) {
+	for _, data := range testData(t) {
+		for level := 1; level < 10; level++ {
+			b := new(bytes.Buffer)
+			w := NewWriter(b, level)
+			w.Write(data)
+			w.Close()
+			if !bytes.Equal(decode(t, b.Bytes()), data) {
+				t.Fatalf("decompressed output doesn't match on level %d", level)
+			}
+		}
+	}
+}
+
+func TestWindowLog(t *testing.T) {
+	// With a MaxDistance and BlockSize larger than the window, some matches
+	// must be stored as literals, and some must be split between blocks.
+	data, err := os.ReadFile("../testdata/Isaac.Newton-Opticks.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = bytes.Repeat(data, 2)
+	for _, windowLog := range []int{10, 17, 0} {
+		b := new(bytes.Buffer)
+		w := &matchfinder.Writer{
+			Dest:        b,
+			MatchFinder: &matchfinder.M4{MaxDistance: 1 << 20, ChainLength: 8, HashLen: 5},
+			Encoder:     &Encoder{WindowLog: windowLog},
+			BlockSize:   len(data),
+		}
+		w.Write(data)
+		w.Close()
+		if !bytes.Equal(decode(t, b.Bytes()), data) {
+			t.Fatalf("decompressed output doesn't match with WindowLog %d", windowLog)
+		}
+	}
+}
+
+func TestEncoderReset(t *testing.T) {
+	// An Encoder that is reused for several frames should produce the same
+	// output each time.
+	data := testData(t)[0]
+	b := new(bytes.Buffer)
+	w := NewWriter(b, 5)
+	w.Write(data)
+	w.Close()
+	first := bytes.Clone(b.Bytes())
+
+	b.Reset()
+	w.Reset(b)
+	w.Write(data)
+	w.Close()
+	if !bytes.Equal(b.Bytes(), first) {
+		t.Fatal("output changed after Reset")
+	}
+}
+
+func BenchmarkWriterLevels(b *testing.B) {
+	data, err := os.ReadFile("../testdata/Isaac.Newton-Opticks.txt")
+	if err != nil {
+		b.Fatal(err)
+	}
+	for level := 1; level < 10; level++ {
+		b.Run(string(rune('0'+level)), func(b *testing.B) {
+			buf := new(bytes.Buffer)
+			w := NewWriter(buf, level)
+			b.SetBytes(int64(len(data)))
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				buf.Reset()
+				w.Reset(buf)
+				w.Write(data)
+				w.Close()
+			}
+			b.ReportMetric(float64(len(data))/float64(buf.Len()), "ratio")
+		})
+	}
+}